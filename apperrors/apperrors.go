@@ -0,0 +1,203 @@
+// Package apperrors defines the error kinds shared by repositories, services
+// and handlers, so that failures can be reported with the right HTTP status
+// without leaking database messages to clients.
+package apperrors
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindForbidden
+	KindUnauthorized
+	KindTimeout
+	KindPreconditionFailed
+	KindPreconditionRequired
+	KindUnsupportedMediaType
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindBadRequest:
+		return "bad request"
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation"
+	case KindForbidden:
+		return "forbidden"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindTimeout:
+		return "timeout"
+	case KindPreconditionFailed:
+		return "precondition failed"
+	case KindPreconditionRequired:
+		return "precondition required"
+	case KindUnsupportedMediaType:
+		return "unsupported media type"
+	}
+	return "internal"
+}
+
+// Error is a failure of a known kind. Its message is safe to show to clients;
+// the underlying cause is kept for errors.Is/As but left out of the message.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+	// Fields lists the individual problems of an invalid request, if any.
+	Fields []FieldError
+}
+
+// FieldError is one invalid field of a request. Field is its path in the
+// request body, e.g. "budget" or "adverts[0].progress".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newError(kind Kind, format string, args ...any) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func BadRequest(format string, args ...any) *Error {
+	return newError(KindBadRequest, format, args...)
+}
+
+func NotFound(format string, args ...any) *Error {
+	return newError(KindNotFound, format, args...)
+}
+
+func Conflict(format string, args ...any) *Error {
+	return newError(KindConflict, format, args...)
+}
+
+func Validation(format string, args ...any) *Error {
+	return newError(KindValidation, format, args...)
+}
+
+// InvalidFields reports a request whose fields failed validation, listing
+// every failing field.
+func InvalidFields(fields []FieldError) *Error {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	e := newError(KindValidation, "invalid request: %s", strings.Join(parts, "; "))
+	e.Fields = fields
+	return e
+}
+
+func Forbidden(format string, args ...any) *Error {
+	return newError(KindForbidden, format, args...)
+}
+
+func Unauthorized(format string, args ...any) *Error {
+	return newError(KindUnauthorized, format, args...)
+}
+
+// PreconditionFailed reports a conditional write whose expected version is
+// no longer current.
+func PreconditionFailed(format string, args ...any) *Error {
+	return newError(KindPreconditionFailed, format, args...)
+}
+
+// PreconditionRequired reports a write that must be conditional but was not.
+func PreconditionRequired(format string, args ...any) *Error {
+	return newError(KindPreconditionRequired, format, args...)
+}
+
+// UnsupportedMediaType reports a request body sent in a format the endpoint
+// does not accept.
+func UnsupportedMediaType(format string, args ...any) *Error {
+	return newError(KindUnsupportedMediaType, format, args...)
+}
+
+// Wrap attaches a kind and a client-safe message to err.
+func Wrap(kind Kind, err error, format string, args ...any) *Error {
+	e := newError(kind, format, args...)
+	e.Err = err
+	return e
+}
+
+// KindOf returns the kind of the first *Error in err's chain. Errors caused
+// by a context deadline, such as a query cancelled by the request timeout,
+// are KindTimeout.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+	return KindInternal
+}
+
+// PostgreSQL error codes translated by FromDB.
+const (
+	pgForeignKeyViolation  = "23503"
+	pgUniqueViolation      = "23505"
+	pgCheckViolation       = "23514"
+	pgNotNullViolation     = "23502"
+	pgInvalidTextRepr      = "22P02"
+	pgStringTooLong        = "22001"
+	pgNumericOutOfRange    = "22003"
+	pgInvalidDatetimeValue = "22007"
+)
+
+// FromDB translates database errors into kinds. entity names the record the
+// query was working on, e.g. "campaign". Errors it does not recognise are
+// returned unchanged.
+func FromDB(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return Wrap(KindNotFound, err, "%s not found", entity)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgForeignKeyViolation:
+		if strings.Contains(pgErr.Detail, "is not present") {
+			return Wrap(KindValidation, err, "%s refers to a record that does not exist", entity)
+		}
+		return Wrap(KindConflict, err, "%s is still referenced by other records", entity)
+	case pgUniqueViolation:
+		return Wrap(KindConflict, err, "%s already exists", entity)
+	case pgCheckViolation, pgNotNullViolation:
+		return Wrap(KindValidation, err, "%s is missing a required value or has an invalid one", entity)
+	case pgInvalidTextRepr, pgStringTooLong, pgNumericOutOfRange, pgInvalidDatetimeValue:
+		return Wrap(KindValidation, err, "%s has a malformed value", entity)
+	}
+	return err
+}
@@ -1,23 +1,102 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"agate-project/config"
+	"agate-project/db"
 	"agate-project/server"
 )
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// SIGINT and SIGTERM cancel ctx: they abort connecting to the database
+	// and start a graceful shutdown once serving. Requests run on their own
+	// contexts, so those still draining can finish their queries.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	srv, err := server.NewServer(ctx)
+	// Global flags come before the command: agate [flags] [migrate|set-password|purge] [args]
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(ctx, cfg, args[1:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	conn, err := db.Open(ctx, cfg.DB)
 	if err != nil {
-		log.Fatalf("error initializing server: %v", err)
+		log.Fatalf("unable to connect to database: %v", err)
 	}
+	srv := server.NewServer(cfg, conn)
 	defer srv.Close()
 
-	if err := srv.Run("localhost:8000"); err != nil {
-		log.Fatalf("failed to start server: %v", err)
+	if len(args) > 0 && args[0] == "set-password" {
+		if err := setPassword(ctx, srv, args[1:]); err != nil {
+			log.Fatalf("set-password: %v", err)
+		}
+		return
+	}
+
+	// purge runs the retention purge once, for deployments that schedule it
+	// themselves with PURGE_INTERVAL=0.
+	if len(args) > 0 && args[0] == "purge" {
+		result, err := srv.PurgeService.Purge(ctx)
+		if err != nil {
+			log.Fatalf("purge: %v", err)
+		}
+		log.Printf("purged %d client(s), %d campaign(s) and %d advert(s)", result.Clients, result.Campaigns, result.Adverts)
+		return
+	}
+
+	log.Printf("listening on %s", cfg.ListenAddr)
+	if err := srv.Run(ctx); err != nil {
+		log.Printf("server stopped: %v", err)
+		os.Exit(1)
+	}
+	log.Println("server stopped")
+}
+
+// setPassword creates or replaces a staff member's login, reading the
+// password from standard input. It is how the first account is created.
+func setPassword(ctx context.Context, srv *server.Server, args []string) error {
+	flags := flag.NewFlagSet("set-password", flag.ContinueOnError)
+	staffID := flags.Int("staff", 0, "staff ID the account belongs to")
+	username := flags.String("username", "", "login name")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stderr, "password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+
+	account, err := srv.AuthService.SetCredentials(ctx, *staffID, *username, strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		return err
 	}
+	log.Printf("credentials set for staff ID %d (%s)", account.StaffID, account.Username)
+	return nil
 }
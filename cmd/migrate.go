@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"agate-project/config"
+	"agate-project/db"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// runMigrate brings the database schema up or down using the migrations
+// embedded in the db package.
+// Only the database settings need to be valid.
+func runMigrate(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	if err := cfg.DB.Validate(); err != nil {
+		return err
+	}
+	conn, err := db.Open(ctx, cfg.DB)
+	if err != nil {
+		return fmt.Errorf("unable to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx, conn.DB)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) applied", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+			steps = n
+		}
+		reverted, err := db.MigrateDown(ctx, conn.DB, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) reverted", len(reverted))
+	case "status":
+		states, err := db.MigrationStatus(ctx, conn.DB)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d  %-30s %s\n", state.Version, state.Name, applied)
+		}
+	default:
+		return fmt.Errorf(migrateUsage)
+	}
+	return nil
+}
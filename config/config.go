@@ -0,0 +1,289 @@
+// Package config loads the server's settings. Every setting has an
+// environment variable name and a command-line flag; a value given as a flag
+// wins over the environment, which wins over the config file, which wins over
+// the default.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"agate-project/db"
+	"agate-project/services"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultConfigFile is read when no -config flag or AGATE_CONFIG is given. It
+// is optional and resolved against the working directory.
+const DefaultConfigFile = ".env"
+
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+type Config struct {
+	ListenAddr string
+	DB         db.Config
+	// LogLevel sets how much the HTTP layer logs. Application messages are
+	// written whatever it is.
+	LogLevel    string
+	CORSOrigins []string
+	HTTP        HTTPTimeouts
+	Auth        services.AuthConfig
+	Budget      services.BudgetThresholds
+	Retention   services.RetentionConfig
+	// MaxConcurrentCampaigns is the number of open campaigns one staff member
+	// may be assigned to.
+	MaxConcurrentCampaigns int
+}
+
+// HTTPTimeouts bound how long a connection may spend reading a request,
+// writing a response and sitting idle between requests. Request is the
+// deadline for handling one request, queries included. Shutdown is how long
+// in-flight requests get to finish once the server is asked to stop.
+type HTTPTimeouts struct {
+	Read     time.Duration
+	Write    time.Duration
+	Idle     time.Duration
+	Request  time.Duration
+	Shutdown time.Duration
+}
+
+func Default() Config {
+	return Config{
+		ListenAddr: "localhost:8000",
+		DB:         db.DefaultConfig(),
+		LogLevel:   LogInfo,
+		HTTP: HTTPTimeouts{
+			Read:     15 * time.Second,
+			Write:    30 * time.Second,
+			Idle:     60 * time.Second,
+			Request:  10 * time.Second,
+			Shutdown: 20 * time.Second,
+		},
+		Auth:                   services.DefaultAuthConfig(),
+		Budget:                 services.DefaultBudgetThresholds(),
+		Retention:              services.DefaultRetentionConfig(),
+		MaxConcurrentCampaigns: services.DefaultMaxConcurrentCampaigns,
+	}
+}
+
+// setting is one configurable value. set parses the raw string into the
+// config; errors are reported against the environment variable name.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{"LISTEN_ADDR", "addr", "address to listen on", func(c *Config, v string) error {
+		c.ListenAddr = v
+		return nil
+	}},
+	{"DATABASE_URL", "database-url", "PostgreSQL connection URL", func(c *Config, v string) error {
+		c.DB.URL = v
+		return nil
+	}},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections", intSetting(func(c *Config) *int { return &c.DB.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", intSetting(func(c *Config) *int { return &c.DB.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "how long a database connection may be reused", durationSetting(func(c *Config) *time.Duration { return &c.DB.ConnMaxLifetime })},
+	{"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "how long a database connection may sit idle", durationSetting(func(c *Config) *time.Duration { return &c.DB.ConnMaxIdleTime })},
+	{"DB_STATEMENT_TIMEOUT", "db-statement-timeout", "longest a single statement may run, 0 for no limit", durationSetting(func(c *Config) *time.Duration { return &c.DB.StatementTimeout })},
+	{"DB_CONNECT_ATTEMPTS", "db-connect-attempts", "times to try reaching the database at startup", intSetting(func(c *Config) *int { return &c.DB.ConnectAttempts })},
+	{"DB_CONNECT_BACKOFF", "db-connect-backoff", "wait after the first failed connection attempt, doubled each retry", durationSetting(func(c *Config) *time.Duration { return &c.DB.ConnectBackoff })},
+	{"LOG_LEVEL", "log-level", "request logging: debug, info, warn or error", func(c *Config, v string) error {
+		c.LogLevel = strings.ToLower(strings.TrimSpace(v))
+		return nil
+	}},
+	{"CORS_ORIGINS", "cors-origins", "comma-separated origins allowed to call the API, or *", func(c *Config, v string) error {
+		c.CORSOrigins = nil
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.CORSOrigins = append(c.CORSOrigins, origin)
+			}
+		}
+		return nil
+	}},
+	{"HTTP_READ_TIMEOUT", "read-timeout", "time allowed to read a request", durationSetting(func(c *Config) *time.Duration { return &c.HTTP.Read })},
+	{"HTTP_WRITE_TIMEOUT", "write-timeout", "time allowed to write a response", durationSetting(func(c *Config) *time.Duration { return &c.HTTP.Write })},
+	{"HTTP_IDLE_TIMEOUT", "idle-timeout", "time a keep-alive connection may sit idle", durationSetting(func(c *Config) *time.Duration { return &c.HTTP.Idle })},
+	{"REQUEST_TIMEOUT", "request-timeout", "deadline for handling one request", durationSetting(func(c *Config) *time.Duration { return &c.HTTP.Request })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time in-flight requests get to finish on shutdown", durationSetting(func(c *Config) *time.Duration { return &c.HTTP.Shutdown })},
+	{"AUTH_SIGNING_KEY", "auth-signing-key", "HMAC key for access and refresh tokens, at least 32 bytes", func(c *Config, v string) error {
+		c.Auth.SigningKey = []byte(v)
+		return nil
+	}},
+	{"AUTH_ACCESS_TTL", "auth-access-ttl", "access token lifetime", durationSetting(func(c *Config) *time.Duration { return &c.Auth.AccessTTL })},
+	{"AUTH_REFRESH_TTL", "auth-refresh-ttl", "refresh token lifetime", durationSetting(func(c *Config) *time.Duration { return &c.Auth.RefreshTTL })},
+	{"BUDGET_WARNING_PERCENT", "budget-warning-percent", "budget use that triggers a warning", floatSetting(func(c *Config) *float64 { return &c.Budget.Warning })},
+	{"BUDGET_HARD_STOP_PERCENT", "budget-hard-stop-percent", "budget use beyond which costs are rejected", floatSetting(func(c *Config) *float64 { return &c.Budget.HardStop })},
+	{"MAX_CONCURRENT_CAMPAIGNS", "max-concurrent-campaigns", "open campaigns one staff member may be assigned to", intSetting(func(c *Config) *int { return &c.MaxConcurrentCampaigns })},
+	{"RETENTION_PERIOD", "retention-period", "how long deleted clients, campaigns and adverts are kept before being purged", durationSetting(func(c *Config) *time.Duration { return &c.Retention.Period })},
+	{"PURGE_INTERVAL", "purge-interval", "how often the server purges expired deleted records, 0 to turn it off", durationSetting(func(c *Config) *time.Duration { return &c.Retention.Interval })},
+}
+
+func intSetting(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func floatSetting(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func durationSetting(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 15m", v)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// Load builds the config from args (without the program name), the
+// environment and the config file. The config file holds KEY=VALUE lines
+// using the environment variable names; it is named by -config or
+// AGATE_CONFIG and otherwise DefaultConfigFile is used if it exists. Load
+// stops at the first argument that is not a flag and returns the rest.
+// Values are parsed but not checked; call Validate for that.
+func Load(args []string) (Config, []string, error) {
+	flags := flag.NewFlagSet("agate", flag.ContinueOnError)
+	configFile := flags.String("config", "", "config file of KEY=VALUE lines (env: AGATE_CONFIG)")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flag] = flags.String(s.flag, "", s.usage+" (env: "+s.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	fileValues, err := readConfigFile(*configFile)
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	cfg := Default()
+	var errs []error
+	for _, s := range settings {
+		v, ok := *flagValues[s.flag], given[s.flag]
+		if !ok {
+			v, ok = os.LookupEnv(s.env)
+		}
+		if !ok {
+			v, ok = fileValues[s.env]
+		}
+		if !ok {
+			continue
+		}
+		if err := s.set(&cfg, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+func readConfigFile(name string) (map[string]string, error) {
+	if name == "" {
+		name = os.Getenv("AGATE_CONFIG")
+	}
+	if name == "" {
+		if _, err := os.Stat(DefaultConfigFile); err != nil {
+			return nil, nil
+		}
+		name = DefaultConfigFile
+	}
+
+	values, err := godotenv.Read(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", name, err)
+	}
+	return values, nil
+}
+
+// Validate reports every invalid setting at once, each named by its
+// environment variable.
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(env, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", env, fmt.Sprintf(format, args...)))
+	}
+
+	if c.ListenAddr == "" {
+		fail("LISTEN_ADDR", "is required")
+	}
+	if err := c.DB.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.LogLevel {
+	case LogDebug, LogInfo, LogWarn, LogError:
+	default:
+		fail("LOG_LEVEL", "must be debug, info, warn or error, not %q", c.LogLevel)
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			fail("CORS_ORIGINS", "%q must be * or start with http:// or https://", origin)
+		}
+	}
+	if c.HTTP.Read <= 0 {
+		fail("HTTP_READ_TIMEOUT", "must be positive")
+	}
+	if c.HTTP.Write <= 0 {
+		fail("HTTP_WRITE_TIMEOUT", "must be positive")
+	}
+	if c.HTTP.Idle <= 0 {
+		fail("HTTP_IDLE_TIMEOUT", "must be positive")
+	}
+	if c.HTTP.Request <= 0 {
+		fail("REQUEST_TIMEOUT", "must be positive")
+	} else if c.HTTP.Request >= c.HTTP.Write {
+		fail("REQUEST_TIMEOUT", "must be shorter than HTTP_WRITE_TIMEOUT (%s) so the timeout response can be sent", c.HTTP.Write)
+	}
+	if c.HTTP.Shutdown <= 0 {
+		fail("SHUTDOWN_TIMEOUT", "must be positive")
+	}
+	if err := c.Auth.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("auth: %w", err))
+	}
+	if err := c.Budget.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("budget: %w", err))
+	}
+	if c.MaxConcurrentCampaigns <= 0 {
+		fail("MAX_CONCURRENT_CAMPAIGNS", "must be positive")
+	}
+	if err := c.Retention.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("retention: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -1,35 +1,156 @@
 package db
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"fmt"
 	"log"
-	"os"
+	"strconv"
+	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
 )
 
-var DB *sql.DB
+// driverName is the name sqlx uses to pick the $1 placeholder style.
+const driverName = "pgx"
 
-func OpenDatabase() error {
+// maxConnectBackoff caps the wait between connection attempts.
+const maxConnectBackoff = 30 * time.Second
 
-	var err error
+// Config describes how to connect and how to size the connection pool.
+type Config struct {
+	URL             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout makes PostgreSQL cancel any single statement running
+	// longer. Zero leaves the server default.
+	StatementTimeout time.Duration
+	// ConnectAttempts is how many times Open tries to reach the database,
+	// waiting ConnectBackoff after the first failure and doubling each time.
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxOpenConns:     25,
+		MaxIdleConns:     5,
+		ConnMaxLifetime:  30 * time.Minute,
+		ConnMaxIdleTime:  5 * time.Minute,
+		StatementTimeout: 30 * time.Second,
+		ConnectAttempts:  5,
+		ConnectBackoff:   time.Second,
+	}
+}
 
-	DB, err = sql.Open("pgx", os.Getenv("DATABASE_URL"))
+func (c Config) Validate() error {
+	var errs []error
+	if c.URL == "" {
+		errs = append(errs, fmt.Errorf("DATABASE_URL: is required"))
+	} else if _, err := pgx.ParseConfig(c.URL); err != nil {
+		errs = append(errs, fmt.Errorf("DATABASE_URL: %w", err))
+	}
+	if c.MaxOpenConns <= 0 {
+		errs = append(errs, fmt.Errorf("DB_MAX_OPEN_CONNS: must be positive"))
+	}
+	if c.MaxIdleConns < 0 || c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("DB_MAX_IDLE_CONNS: must be between 0 and DB_MAX_OPEN_CONNS (%d)", c.MaxOpenConns))
+	}
+	if c.ConnMaxLifetime < 0 {
+		errs = append(errs, fmt.Errorf("DB_CONN_MAX_LIFETIME: must not be negative"))
+	}
+	if c.ConnMaxIdleTime < 0 {
+		errs = append(errs, fmt.Errorf("DB_CONN_MAX_IDLE_TIME: must not be negative"))
+	}
+	if c.StatementTimeout < 0 {
+		errs = append(errs, fmt.Errorf("DB_STATEMENT_TIMEOUT: must not be negative"))
+	}
+	if c.ConnectAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("DB_CONNECT_ATTEMPTS: must be positive"))
+	}
+	if c.ConnectBackoff < 0 {
+		errs = append(errs, fmt.Errorf("DB_CONNECT_BACKOFF: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Open builds a connection pool from cfg and waits until the database answers,
+// retrying with backoff so the server can start alongside its database. The
+// caller owns the pool and must close it.
+func Open(ctx context.Context, cfg Config) (*sqlx.DB, error) {
+	connConfig, err := pgx.ParseConfig(cfg.URL)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid database URL: %w", err)
 	}
+	if cfg.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	conn := sqlx.NewDb(stdlib.OpenDB(*connConfig), driverName)
+	conn.SetMaxOpenConns(cfg.MaxOpenConns)
+	conn.SetMaxIdleConns(cfg.MaxIdleConns)
+	conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	if err = DB.Ping(); err != nil {
-		return err
+	if err := ping(ctx, conn, cfg); err != nil {
+		conn.Close()
+		return nil, err
 	}
+	log.Printf("connected to database %s/%s", connConfig.Host, connConfig.Database)
+	return conn, nil
+}
+
+func ping(ctx context.Context, conn *sqlx.DB, cfg Config) error {
+	backoff := cfg.ConnectBackoff
+	var err error
+	for attempt := 1; attempt <= cfg.ConnectAttempts; attempt++ {
+		if err = conn.PingContext(ctx); err == nil {
+			return nil
+		}
+		if attempt == cfg.ConnectAttempts {
+			break
+		}
+
+		log.Printf("ping: database not ready (attempt %d of %d), retrying in %s: %v", attempt, cfg.ConnectAttempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up connecting to database: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+	return fmt.Errorf("database unreachable after %d attempt(s): %w", cfg.ConnectAttempts, err)
+}
 
-	log.Println("connected to database")
-	return nil
+// PoolStats is a snapshot of the connection pool for health reporting.
+type PoolStats struct {
+	MaxOpen      int    `json:"max_open"`
+	Open         int    `json:"open"`
+	InUse        int    `json:"in_use"`
+	Idle         int    `json:"idle"`
+	WaitCount    int64  `json:"wait_count"`
+	WaitDuration string `json:"wait_duration"`
+	// Connections closed for hitting the idle limit, idle time or lifetime.
+	MaxIdleClosed     int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64 `json:"max_lifetime_closed"`
 }
 
-func CloseDatabase() {
-	if DB != nil {
-		_ = DB.Close() //veritabanını kapatırken olabilecek hataları _ : ignore
-		log.Println("database connection closed")
+func Stats(conn *sqlx.DB) PoolStats {
+	s := conn.Stats()
+	return PoolStats{
+		MaxOpen:           s.MaxOpenConnections,
+		Open:              s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitDuration:      s.WaitDuration.String(),
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
 	}
 }
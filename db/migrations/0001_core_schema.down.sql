@@ -0,0 +1,6 @@
+DROP TABLE adverts;
+DROP TABLE campaigns;
+DROP TABLE campaign_manager;
+DROP TABLE staff;
+DROP TABLE staff_grades;
+DROP TABLE clients;
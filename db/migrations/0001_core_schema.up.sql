@@ -0,0 +1,60 @@
+CREATE TABLE clients (
+    client_id       SERIAL PRIMARY KEY,
+    name            TEXT NOT NULL,
+    address         TEXT NOT NULL DEFAULT '',
+    contact_details TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE staff_grades (
+    grade_id   SERIAL PRIMARY KEY,
+    grade_name TEXT NOT NULL UNIQUE,
+    pay_rate   NUMERIC(14, 2) NOT NULL CHECK (pay_rate >= 0),
+    currency   CHAR(3) NOT NULL DEFAULT 'GBP'
+);
+
+CREATE TABLE staff (
+    staff_id       SERIAL PRIMARY KEY,
+    name           TEXT NOT NULL,
+    role           TEXT NOT NULL DEFAULT '',
+    grade_id       INT NOT NULL REFERENCES staff_grades (grade_id),
+    starting_grade INT REFERENCES staff_grades (grade_id),
+    start_date     DATE NOT NULL DEFAULT CURRENT_DATE,
+    active         BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX staff_grade_id_idx ON staff (grade_id);
+
+CREATE TABLE campaign_manager (
+    manager_id SERIAL PRIMARY KEY,
+    staff_id   INT NOT NULL UNIQUE REFERENCES staff (staff_id)
+);
+
+CREATE TABLE campaigns (
+    campaign_id       SERIAL PRIMARY KEY,
+    client_id         INT NOT NULL REFERENCES clients (client_id),
+    title             TEXT NOT NULL,
+    start_date        DATE NOT NULL,
+    end_date          DATE NOT NULL,
+    estimated_cost    NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK (estimated_cost >= 0),
+    actual_cost       NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK (actual_cost >= 0),
+    completion_status BOOLEAN NOT NULL DEFAULT FALSE,
+    current_state     TEXT NOT NULL DEFAULT 'not started'
+                      CHECK (current_state IN ('not started', 'in progress', 'completed', 'cancelled')),
+    manager_id        INT REFERENCES campaign_manager (manager_id),
+    budget            NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK (budget >= 0),
+    currency          CHAR(3) NOT NULL DEFAULT 'GBP'
+);
+
+CREATE INDEX campaigns_client_id_idx ON campaigns (client_id);
+CREATE INDEX campaigns_manager_id_idx ON campaigns (manager_id);
+CREATE INDEX campaigns_current_state_idx ON campaigns (current_state);
+
+CREATE TABLE adverts (
+    advert_id   SERIAL PRIMARY KEY,
+    campaign_id INT NOT NULL REFERENCES campaigns (campaign_id),
+    progress    TEXT NOT NULL DEFAULT '',
+    run_date    DATE NOT NULL
+);
+
+CREATE INDEX adverts_campaign_id_idx ON adverts (campaign_id);
+CREATE INDEX adverts_run_date_idx ON adverts (run_date);
@@ -0,0 +1 @@
+DROP TABLE campaign_state_history;
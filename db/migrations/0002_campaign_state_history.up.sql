@@ -0,0 +1,10 @@
+CREATE TABLE campaign_state_history (
+    history_id  SERIAL PRIMARY KEY,
+    campaign_id INT NOT NULL REFERENCES campaigns (campaign_id),
+    from_state  TEXT NOT NULL,
+    to_state    TEXT NOT NULL,
+    changed_by  INT NOT NULL REFERENCES staff (staff_id),
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX campaign_state_history_campaign_id_idx ON campaign_state_history (campaign_id);
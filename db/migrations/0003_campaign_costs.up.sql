@@ -0,0 +1,14 @@
+CREATE TABLE campaign_costs (
+    entry_id    SERIAL PRIMARY KEY,
+    campaign_id INT NOT NULL REFERENCES campaigns (campaign_id),
+    advert_id   INT REFERENCES adverts (advert_id),
+    amount      NUMERIC(14, 2) NOT NULL CHECK (amount >= 0),
+    currency    CHAR(3) NOT NULL DEFAULT 'GBP',
+    category    TEXT NOT NULL
+                CHECK (category IN ('media buy', 'staff time', 'production', 'third party')),
+    entry_date  DATE NOT NULL,
+    note        TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX campaign_costs_campaign_id_idx ON campaign_costs (campaign_id);
+CREATE INDEX campaign_costs_advert_id_idx ON campaign_costs (advert_id);
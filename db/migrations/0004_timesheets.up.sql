@@ -0,0 +1,16 @@
+CREATE TABLE timesheets (
+    timesheet_id SERIAL PRIMARY KEY,
+    staff_id     INT NOT NULL REFERENCES staff (staff_id),
+    campaign_id  INT NOT NULL REFERENCES campaigns (campaign_id),
+    work_date    DATE NOT NULL,
+    hours        NUMERIC(4, 2) NOT NULL CHECK (hours > 0 AND hours <= 24),
+    grade_id     INT NOT NULL REFERENCES staff_grades (grade_id),
+    hourly_rate  NUMERIC(14, 2) NOT NULL,
+    cost         NUMERIC(14, 2) NOT NULL,
+    currency     CHAR(3) NOT NULL DEFAULT 'GBP',
+    note         TEXT NOT NULL DEFAULT '',
+    UNIQUE (staff_id, campaign_id, work_date)
+);
+
+CREATE INDEX timesheets_campaign_id_idx ON timesheets (campaign_id);
+CREATE INDEX timesheets_staff_id_work_date_idx ON timesheets (staff_id, work_date);
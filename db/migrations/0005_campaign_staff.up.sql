@@ -0,0 +1,10 @@
+CREATE TABLE campaign_staff (
+    assignment_id SERIAL PRIMARY KEY,
+    campaign_id   INT NOT NULL REFERENCES campaigns (campaign_id),
+    staff_id      INT NOT NULL REFERENCES staff (staff_id),
+    role          TEXT NOT NULL CHECK (role IN ('creative', 'copywriter', 'account', 'manager')),
+    assigned_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (campaign_id, staff_id)
+);
+
+CREATE INDEX campaign_staff_staff_id_idx ON campaign_staff (staff_id);
@@ -0,0 +1,9 @@
+CREATE TABLE campaign_manager_history (
+    assignment_id       SERIAL PRIMARY KEY,
+    campaign_id         INT NOT NULL REFERENCES campaigns (campaign_id),
+    previous_manager_id INT REFERENCES campaign_manager (manager_id),
+    manager_id          INT NOT NULL REFERENCES campaign_manager (manager_id),
+    assigned_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX campaign_manager_history_campaign_id_idx ON campaign_manager_history (campaign_id);
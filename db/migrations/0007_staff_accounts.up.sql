@@ -0,0 +1,16 @@
+CREATE TABLE staff_accounts (
+    staff_id      INT PRIMARY KEY REFERENCES staff (staff_id) ON DELETE CASCADE,
+    username      TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE auth_sessions (
+    session_id TEXT PRIMARY KEY,
+    staff_id   INT NOT NULL REFERENCES staff (staff_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX auth_sessions_staff_id_idx ON auth_sessions (staff_id);
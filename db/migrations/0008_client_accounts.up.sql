@@ -0,0 +1,19 @@
+CREATE TABLE client_accounts (
+    client_user_id SERIAL PRIMARY KEY,
+    client_id      INT NOT NULL REFERENCES clients (client_id) ON DELETE CASCADE,
+    username       TEXT NOT NULL UNIQUE,
+    password_hash  TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX client_accounts_client_id_idx ON client_accounts (client_id);
+
+CREATE TABLE client_sessions (
+    session_id     TEXT PRIMARY KEY,
+    client_user_id INT NOT NULL REFERENCES client_accounts (client_user_id) ON DELETE CASCADE,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at     TIMESTAMPTZ NOT NULL,
+    revoked_at     TIMESTAMPTZ
+);
+
+CREATE INDEX client_sessions_client_user_id_idx ON client_sessions (client_user_id);
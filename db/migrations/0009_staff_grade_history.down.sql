@@ -0,0 +1,21 @@
+DROP VIEW staff_details;
+
+ALTER TABLE staff ADD COLUMN grade_id INT REFERENCES staff_grades (grade_id),
+                  ADD COLUMN starting_grade INT REFERENCES staff_grades (grade_id);
+
+UPDATE staff s
+SET starting_grade = (
+        SELECT h.grade_id FROM staff_grade_history h
+        WHERE h.staff_id = s.staff_id AND h.reason = 'starting'
+    ),
+    grade_id = (
+        SELECT h.grade_id FROM staff_grade_history h
+        WHERE h.staff_id = s.staff_id
+        ORDER BY h.effective_date DESC
+        LIMIT 1
+    );
+
+ALTER TABLE staff ALTER COLUMN grade_id SET NOT NULL;
+CREATE INDEX staff_grade_id_idx ON staff (grade_id);
+
+DROP TABLE staff_grade_history;
@@ -0,0 +1,44 @@
+CREATE TABLE staff_grade_history (
+    history_id     SERIAL PRIMARY KEY,
+    staff_id       INT NOT NULL REFERENCES staff (staff_id) ON DELETE CASCADE,
+    grade_id       INT NOT NULL REFERENCES staff_grades (grade_id),
+    effective_date DATE NOT NULL,
+    reason         TEXT NOT NULL CHECK (reason IN ('starting', 'promotion')),
+    recorded_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (staff_id, effective_date)
+);
+
+CREATE UNIQUE INDEX staff_grade_history_starting_idx ON staff_grade_history (staff_id) WHERE reason = 'starting';
+CREATE INDEX staff_grade_history_grade_id_idx ON staff_grade_history (grade_id);
+
+-- Existing staff start on their recorded starting grade, or their current
+-- one if none was recorded. A different current grade becomes a promotion
+-- dated today, since the real date was never kept.
+INSERT INTO staff_grade_history (staff_id, grade_id, effective_date, reason)
+SELECT staff_id, COALESCE(starting_grade, grade_id), start_date, 'starting'
+FROM staff;
+
+INSERT INTO staff_grade_history (staff_id, grade_id, effective_date, reason)
+SELECT staff_id, grade_id, GREATEST(start_date + 1, CURRENT_DATE), 'promotion'
+FROM staff
+WHERE starting_grade IS NOT NULL AND starting_grade <> grade_id;
+
+DROP INDEX staff_grade_id_idx;
+ALTER TABLE staff DROP COLUMN starting_grade, DROP COLUMN grade_id;
+
+-- staff_details is staff with the starting and current grade derived from
+-- the history. A grade only becomes current on its effective date; before
+-- someone's start date their starting grade is reported.
+CREATE VIEW staff_details AS
+SELECT s.staff_id, s.name, s.role, s.start_date, s.active,
+       starting.grade_id AS starting_grade,
+       COALESCE(current.grade_id, starting.grade_id) AS grade_id
+FROM staff s
+JOIN staff_grade_history starting ON starting.staff_id = s.staff_id AND starting.reason = 'starting'
+LEFT JOIN LATERAL (
+    SELECT h.grade_id
+    FROM staff_grade_history h
+    WHERE h.staff_id = s.staff_id AND h.effective_date <= CURRENT_DATE
+    ORDER BY h.effective_date DESC
+    LIMIT 1
+) current ON TRUE;
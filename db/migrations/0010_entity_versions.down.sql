@@ -0,0 +1,4 @@
+ALTER TABLE staff_grades DROP COLUMN version;
+ALTER TABLE adverts      DROP COLUMN version;
+ALTER TABLE clients      DROP COLUMN version;
+ALTER TABLE campaigns    DROP COLUMN version;
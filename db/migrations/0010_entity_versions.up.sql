@@ -0,0 +1,6 @@
+-- Every successful write bumps version; it is served as the ETag and
+-- updates must name the version they were made against.
+ALTER TABLE campaigns    ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE clients      ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE adverts      ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE staff_grades ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+-- Rows still soft-deleted become live again. Restore and purge entries stay
+-- in the audit log, which is append-only, so the old check is not validated
+-- against them.
+ALTER TABLE audit_log DROP CONSTRAINT audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN ('create', 'update', 'delete')) NOT VALID;
+
+DROP INDEX adverts_deleted_at_idx;
+DROP INDEX campaigns_deleted_at_idx;
+DROP INDEX clients_deleted_at_idx;
+
+ALTER TABLE adverts   DROP COLUMN deleted_at;
+ALTER TABLE campaigns DROP COLUMN deleted_at;
+ALTER TABLE clients   DROP COLUMN deleted_at;
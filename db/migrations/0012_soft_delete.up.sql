@@ -0,0 +1,14 @@
+-- Deleting a client, campaign or advert sets deleted_at and keeps the row
+-- until the retention purge removes it. Rows deleted together share the
+-- same deleted_at, which is how a restore finds the ones to bring back.
+ALTER TABLE clients   ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE campaigns ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE adverts   ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX clients_deleted_at_idx ON clients (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX campaigns_deleted_at_idx ON campaigns (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX adverts_deleted_at_idx ON adverts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE audit_log DROP CONSTRAINT audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore', 'purge'));
@@ -1,13 +1,13 @@
 package handlers
 
 import (
+	"agate-project/apperrors"
+	"agate-project/middleware"
 	"agate-project/models"
 	"agate-project/services"
-	"context"
 	"log"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,32 +17,40 @@ type AdvertHandlers interface {
 	GetAdvertByID(c *gin.Context)
 	CreateAdvert(c *gin.Context)
 	RemoveAdvert(c *gin.Context)
+	RestoreAdvert(c *gin.Context)
 	UpdateAdvert(c *gin.Context)
+	PatchAdvert(c *gin.Context)
 	GetAdvertsByCampaign(c *gin.Context)
 }
 
 type advertHandlers struct {
-	ctx           context.Context
 	advertService services.AdvertService
+	policy        services.Policy
 }
 
-func NewAdvertHandlers(ctx context.Context, service services.AdvertService) AdvertHandlers {
+func NewAdvertHandlers(service services.AdvertService, policy services.Policy) AdvertHandlers {
 	return &advertHandlers{
-		ctx:           ctx,
 		advertService: service,
+		policy:        policy,
 	}
 }
 
 func (h *advertHandlers) GetAllAdverts(c *gin.Context) {
-	log.Println("GetAllAdverts: Received request to fetch all adverts.")
-	adverts, err := h.advertService.FetchAllAdverts()
+	log.Println("GetAllAdverts: Received request to fetch adverts.")
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Printf("GetAllAdverts: Invalid list parameters: %v", err)
+		c.Error(err)
+		return
+	}
+	page, err := h.advertService.FetchAllAdverts(c.Request.Context(), opts)
 	if err != nil {
 		log.Printf("GetAllAdverts: Failed to fetch adverts: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch adverts"})
+		c.Error(err)
 		return
 	}
-	log.Printf("GetAllAdverts: Successfully fetched %d adverts.", len(adverts))
-	c.JSON(http.StatusOK, adverts)
+	log.Printf("GetAllAdverts: Successfully fetched %d adverts.", len(page.Items))
+	writePage(c, page)
 }
 
 func (h *advertHandlers) GetAdvertByID(c *gin.Context) {
@@ -50,18 +58,25 @@ func (h *advertHandlers) GetAdvertByID(c *gin.Context) {
 	advertID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("GetAdvertByID: Invalid advert ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid advert ID"})
+		c.Error(apperrors.BadRequest("invalid advert ID"))
 		return
 	}
 
-	advert, err := h.advertService.GetAdvertByID(advertID)
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	advert, err := h.advertService.GetAdvertByID(c.Request.Context(), advertID, withDeleted)
 	if err != nil {
 		log.Printf("GetAdvertByID: Failed to fetch advert with ID %d: %v", advertID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	log.Printf("GetAdvertByID: Successfully fetched advert with ID %d.", advertID)
+	setETag(c, advert.Version)
 	c.JSON(http.StatusOK, advert)
 }
 
@@ -69,15 +84,15 @@ func (h *advertHandlers) CreateAdvert(c *gin.Context) {
 	log.Println("CreateAdvert: Received request to create an advert.")
 	var advert models.Advert
 
-	if err := c.ShouldBindJSON(&advert); err != nil {
+	if err := bindJSON(c, &advert); err != nil {
 		log.Printf("CreateAdvert: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 
-	if err := h.advertService.AddAdvert(&advert); err != nil {
+	if err := h.advertService.AddAdvert(c.Request.Context(), &advert); err != nil {
 		log.Printf("CreateAdvert: Failed to create advert: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -90,13 +105,13 @@ func (h *advertHandlers) RemoveAdvert(c *gin.Context) {
 	advertID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("RemoveAdvert: Invalid advert ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid advert ID"})
+		c.Error(apperrors.BadRequest("invalid advert ID"))
 		return
 	}
 
-	if err := h.advertService.RemoveAdvert(advertID); err != nil {
+	if err := h.advertService.RemoveAdvert(c.Request.Context(), advertID); err != nil {
 		log.Printf("RemoveAdvert: Failed to remove advert with ID %d: %v", advertID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -104,56 +119,142 @@ func (h *advertHandlers) RemoveAdvert(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "advert deleted"})
 }
 
+func (h *advertHandlers) RestoreAdvert(c *gin.Context) {
+	log.Println("RestoreAdvert: Received request to restore an advert.")
+	advertID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("RestoreAdvert: Invalid advert ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid advert ID"))
+		return
+	}
+
+	advert, err := h.advertService.RestoreAdvert(c.Request.Context(), advertID)
+	if err != nil {
+		log.Printf("RestoreAdvert: Failed to restore advert with ID %d: %v", advertID, err)
+		c.Error(err)
+		return
+	}
+
+	log.Printf("RestoreAdvert: Successfully restored advert with ID %d.", advertID)
+	setETag(c, advert.Version)
+	c.JSON(http.StatusOK, advert)
+}
+
+// UpdateAdvert replaces the advert's campaign, progress and run date, all of
+// which are required.
 func (h *advertHandlers) UpdateAdvert(c *gin.Context) {
 	log.Println("UpdateAdvert: Received request to update an advert.")
 	advertID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("UpdateAdvert: Invalid advert ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid advert ID"})
+		c.Error(apperrors.BadRequest("invalid advert ID"))
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Body'den gelen JSON'u models.Advert ile bağla
 	var advert models.Advert
-	if err := c.ShouldBindJSON(&advert); err != nil {
+	if err := bindJSON(c, &advert); err != nil {
 		log.Printf("UpdateAdvert: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 
-	var progressPtr *string
-	if advert.Progress != "" {
-		progressPtr = &advert.Progress
-	}
-
-	var runDatePtr *time.Time
-	if !advert.RunDate.IsZero() {
-		runDatePtr = &advert.RunDate
+	// Whether this reschedules the advert is decided against the record the
+	// update replaces, not against an earlier read.
+	principal, _ := middleware.PrincipalFrom(c)
+	mayReschedule := h.policy.Allowed(principal, models.PermManageAdverts)
+	if !mayReschedule {
+		if err := h.policy.Require(principal, models.PermUpdateAdvertProgress); err != nil {
+			c.Error(err)
+			return
+		}
 	}
 
-	if err := h.advertService.UpdateAdvert(advertID, advert.CampaignID, progressPtr, runDatePtr); err != nil {
+	advert.AdvertID = advertID
+	advert.Version = version
+	if err := h.advertService.UpdateAdvert(c.Request.Context(), &advert, mayReschedule); err != nil {
 		log.Printf("UpdateAdvert: Failed to update advert with ID %d: %v", advertID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	log.Printf("UpdateAdvert: Successfully updated advert with ID %d.", advertID)
+	setETag(c, advert.Version)
 	c.JSON(http.StatusOK, gin.H{"message": "advert updated"})
 }
 
+// PatchAdvert changes only the fields named in a JSON merge patch.
+func (h *advertHandlers) PatchAdvert(c *gin.Context) {
+	log.Println("PatchAdvert: Received request to patch an advert.")
+	advertID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("PatchAdvert: Invalid advert ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid advert ID"))
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	patch, err := readMergePatch(c)
+	if err != nil {
+		log.Printf("PatchAdvert: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	_, touchesProgressOnly := patch["progress"]
+	touchesProgressOnly = touchesProgressOnly && len(patch) == 1
+	if err := h.requireAdvertChange(c, !touchesProgressOnly); err != nil {
+		c.Error(err)
+		return
+	}
+
+	advert, err := h.advertService.PatchAdvert(c.Request.Context(), advertID, version, patch)
+	if err != nil {
+		log.Printf("PatchAdvert: Failed to patch advert with ID %d: %v", advertID, err)
+		c.Error(err)
+		return
+	}
+
+	log.Printf("PatchAdvert: Successfully patched advert with ID %d.", advertID)
+	setETag(c, advert.Version)
+	c.JSON(http.StatusOK, advert)
+}
+
+// requireAdvertChange checks the caller may make an advert change. Creatives
+// may move an advert's progress along but not reschedule it.
+func (h *advertHandlers) requireAdvertChange(c *gin.Context, rescheduled bool) error {
+	required := models.PermUpdateAdvertProgress
+	if rescheduled {
+		required = models.PermManageAdverts
+	}
+	principal, _ := middleware.PrincipalFrom(c)
+	return h.policy.Require(principal, required)
+}
+
 func (h *advertHandlers) GetAdvertsByCampaign(c *gin.Context) {
 	log.Println("GetAdvertsByCampaign: Received request to fetch adverts by campaign ID.")
 	campaignID, err := strconv.Atoi(c.Param("campaignID"))
 	if err != nil {
 		log.Printf("GetAdvertsByCampaign: Invalid campaign ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign id"})
+		c.Error(apperrors.BadRequest("invalid campaign id"))
 		return
 	}
 
-	adverts, err := h.advertService.GetAdvertsByCampaign(campaignID)
+	adverts, err := h.advertService.GetAdvertsByCampaign(c.Request.Context(), campaignID)
 	if err != nil {
 		log.Printf("GetAdvertsByCampaign: Failed to fetch adverts for campaign ID %d: %v", campaignID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"agate-project/services"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandlers interface {
+	GetAuditLog(c *gin.Context)
+}
+
+type auditHandlers struct {
+	auditService services.AuditService
+}
+
+func NewAuditHandlers(service services.AuditService) AuditHandlers {
+	return &auditHandlers{
+		auditService: service,
+	}
+}
+
+func (h *auditHandlers) GetAuditLog(c *gin.Context) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Printf("GetAuditLog: Invalid list parameters: %v", err)
+		c.Error(err)
+		return
+	}
+	page, err := h.auditService.GetAuditLog(c.Request.Context(), opts)
+	if err != nil {
+		log.Printf("GetAuditLog: Failed to fetch audit log: %v", err)
+		c.Error(err)
+		return
+	}
+	writePage(c, page)
+}
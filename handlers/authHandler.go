@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"agate-project/apperrors"
+	"agate-project/services"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandlers interface {
+	Login(c *gin.Context)
+	Refresh(c *gin.Context)
+	Logout(c *gin.Context)
+	SetCredentials(c *gin.Context)
+}
+
+type authHandlers struct {
+	authService services.AuthService
+}
+
+func NewAuthHandlers(service services.AuthService) AuthHandlers {
+	return &authHandlers{
+		authService: service,
+	}
+}
+
+type credentialsRequest struct {
+	Username string `json:"username" validate:"notblank"`
+	Password string `json:"password" validate:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (h *authHandlers) Login(c *gin.Context) {
+	var req credentialsRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("Login: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	tokens, err := h.authService.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *authHandlers) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("Refresh: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	tokens, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *authHandlers) Logout(c *gin.Context) {
+	var req refreshRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("Logout: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *authHandlers) SetCredentials(c *gin.Context) {
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("SetCredentials: Invalid staff ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid staff ID"))
+		return
+	}
+
+	var req credentialsRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("SetCredentials: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	account, err := h.authService.SetCredentials(c.Request.Context(), staffID, req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindJSON decodes the JSON request body into dst and checks it against its
+// validation tags, reporting every invalid field at once.
+func bindJSON(c *gin.Context, dst any) error {
+	if c.Request.Body == nil {
+		return apperrors.BadRequest("invalid request body")
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return apperrors.Wrap(apperrors.KindBadRequest, err, "invalid request body")
+	}
+	return models.DecodeJSON(body, dst)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"agate-project/apperrors"
+	"agate-project/middleware"
 	"agate-project/models"
 	"agate-project/services"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,38 +17,69 @@ type CampaignHandlers interface {
 	CreateCampaign(c *gin.Context)
 	GetCampaignByID(c *gin.Context)
 	UpdateCampaign(c *gin.Context)
+	PatchCampaign(c *gin.Context)
 	RemoveCampaign(c *gin.Context)
+	RestoreCampaign(c *gin.Context)
 	AssignManager(c *gin.Context)
 	GetAllCampaigns(c *gin.Context)
-	//CheckBudget(c *gin.Context)
+	CheckBudget(c *gin.Context)
 	GetCampaignsByClientID(c *gin.Context)
+	TransitionCampaign(c *gin.Context)
+	GetManagerHistory(c *gin.Context)
+	GetCampaignHistory(c *gin.Context)
+}
+
+// createCampaignRequest is a campaign with the adverts to create with it.
+// adverts is optional.
+type createCampaignRequest struct {
+	models.Campaign
+	Adverts []newAdvert `json:"adverts" validate:"dive"`
+}
+
+// newAdvert is an advert created along with its campaign, which gives it
+// its campaign ID.
+type newAdvert struct {
+	Progress models.AdvertProgress `json:"progress" validate:"required,enum"`
+	RunDate  time.Time             `json:"run_date" validate:"required"`
+}
+
+// patchedCampaign is a campaign after a patch with its budget, whose status
+// flags spending past the warning threshold.
+type patchedCampaign struct {
+	models.Campaign
+	BudgetCheck *models.CampaignBudget `json:"budget_check,omitempty"`
 }
 
 type campaignHandlers struct {
 	service services.CampaignService
+	policy  services.Policy
 }
 
-func NewCampaignHandlers(service services.CampaignService) CampaignHandlers {
-	return &campaignHandlers{service: service}
+func NewCampaignHandlers(service services.CampaignService, policy services.Policy) CampaignHandlers {
+	return &campaignHandlers{service: service, policy: policy}
 }
 
 func (h *campaignHandlers) CreateCampaign(c *gin.Context) {
 	log.Println("CreateCampaign: Received request to create a campaign.")
-	var campaign models.Campaign
+	var req createCampaignRequest
 
-	if err := c.ShouldBindJSON(&campaign); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		log.Printf("CreateCampaign: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 
-	if err := h.service.CreateCampaign(campaign); err != nil {
+	adverts := make([]models.Advert, len(req.Adverts))
+	for i, advert := range req.Adverts {
+		adverts[i] = models.Advert{Progress: advert.Progress, RunDate: advert.RunDate}
+	}
+	if err := h.service.CreateCampaign(c.Request.Context(), &req.Campaign, adverts); err != nil {
 		log.Printf("CreateCampaign: Failed to create campaign: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "campaign created"})
+	c.JSON(http.StatusCreated, gin.H{"message": "campaign created", "campaign_id": req.Campaign.CampaignID, "adverts": adverts})
 }
 
 func (h *campaignHandlers) GetCampaignByID(c *gin.Context) {
@@ -53,16 +87,23 @@ func (h *campaignHandlers) GetCampaignByID(c *gin.Context) {
 	campaignID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("GetCampaignByID: Invalid campaign ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign ID"})
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	campaign, err := h.service.GetCampaignByID(campaignID)
+	campaign, err := h.service.GetCampaignByID(c.Request.Context(), campaignID, withDeleted)
 	if err != nil {
 		log.Printf("GetCampaignByID: Failed to fetch campaign by ID %d: %v", campaignID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
+	setETag(c, campaign.Version)
 	c.JSON(http.StatusOK, campaign)
 }
 
@@ -73,22 +114,70 @@ func (h *campaignHandlers) UpdateCampaign(c *gin.Context) {
 	campaignID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("UpdateCampaign: Invalid campaign ID in URL: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign ID"})
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+	version, err := ifMatch(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 	var campaign models.Campaign
-	if err := c.ShouldBindJSON(&campaign); err != nil {
+	if err := bindJSON(c, &campaign); err != nil {
 		log.Printf("UpdateCampaign: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 	campaign.CampaignID = campaignID
-	if err := h.service.UpdateCampaign(campaign); err != nil {
+	campaign.Version = version
+	if err := h.service.UpdateCampaign(c.Request.Context(), &campaign); err != nil {
 		log.Printf("UpdateCampaign: Failed to update campaign with ID %d: %v", campaign.CampaignID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+	setETag(c, campaign.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "campaign updated", "budget_check": h.budgetCheck(c, campaignID)})
+}
+
+// PatchCampaign changes only the fields named in a JSON merge patch.
+func (h *campaignHandlers) PatchCampaign(c *gin.Context) {
+	log.Println("PatchCampaign: Received request to patch a campaign.")
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("PatchCampaign: Invalid campaign ID in URL: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+	version, err := ifMatch(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	patch, err := readMergePatch(c)
+	if err != nil {
+		log.Printf("PatchCampaign: Invalid request body: %v", err)
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "campaign updated"})
+	campaign, err := h.service.PatchCampaign(c.Request.Context(), campaignID, version, patch)
+	if err != nil {
+		log.Printf("PatchCampaign: Failed to patch campaign with ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	setETag(c, campaign.Version)
+	c.JSON(http.StatusOK, patchedCampaign{Campaign: campaign, BudgetCheck: h.budgetCheck(c, campaignID)})
+}
+
+// budgetCheck evaluates a campaign's budget after an update. The update has
+// already succeeded, so a failure here only leaves the check out.
+func (h *campaignHandlers) budgetCheck(c *gin.Context, campaignID int) *models.CampaignBudget {
+	budget, err := h.service.CheckBudget(c.Request.Context(), campaignID)
+	if err != nil {
+		log.Printf("budgetCheck: Failed to check budget of campaign with ID %d: %v", campaignID, err)
+		return nil
+	}
+	return &budget
 }
 
 func (h *campaignHandlers) RemoveCampaign(c *gin.Context) {
@@ -96,85 +185,184 @@ func (h *campaignHandlers) RemoveCampaign(c *gin.Context) {
 	campaignID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("RemoveCampaign: Invalid campaign ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign ID"})
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
 		return
 	}
 
-	if err := h.service.RemoveCampaign(campaignID); err != nil {
+	if err := h.service.RemoveCampaign(c.Request.Context(), campaignID); err != nil {
 		log.Printf("RemoveAdvert: Failed to remove campaign with ID %d: %v", campaignID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "campaign deleted"})
 }
 
+func (h *campaignHandlers) RestoreCampaign(c *gin.Context) {
+	log.Println("RestoreCampaign: Received request to restore a campaign.")
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("RestoreCampaign: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	campaign, err := h.service.RestoreCampaign(c.Request.Context(), campaignID)
+	if err != nil {
+		log.Printf("RestoreCampaign: Failed to restore campaign with ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	setETag(c, campaign.Version)
+	c.JSON(http.StatusOK, campaign)
+}
+
 func (h *campaignHandlers) AssignManager(c *gin.Context) {
 	log.Println("AssignManager: Received request to assign a manager to a campaign.")
 	campaignID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("AssignManager: Invalid campaign ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign id"})
+		c.Error(apperrors.BadRequest("invalid campaign id"))
 		return
 	}
 
 	managerID, err := strconv.Atoi(c.Param("managerID"))
 	if err != nil {
 		log.Printf("AssignManager: Invalid manager ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid manager id"})
+		c.Error(apperrors.BadRequest("invalid manager id"))
 		return
 	}
 
-	if err := h.service.AssignManager(campaignID, managerID); err != nil {
+	assignment, err := h.service.AssignManager(c.Request.Context(), campaignID, managerID)
+	if err != nil {
 		log.Printf("AssignManager: Failed to assign manager with ID %d to campaign with ID %d: %v", managerID, campaignID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "manager assigned to campaign"})
+	c.JSON(http.StatusOK, assignment)
 }
 
 func (h *campaignHandlers) GetAllCampaigns(c *gin.Context) {
-	log.Println("GetAllCampaigns: Received request to fetch all campaigns.")
-	campaigns, err := h.service.FetchAllCampaigns()
+	log.Println("GetAllCampaigns: Received request to fetch campaigns.")
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Printf("GetAllCampaigns: Invalid list parameters: %v", err)
+		c.Error(err)
+		return
+	}
+	page, err := h.service.FetchAllCampaigns(c.Request.Context(), opts)
 	if err != nil {
 		log.Printf("GetAllCampaigns: Failed to fetch campaigns: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch campaigns"})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, campaigns)
+	writePage(c, page)
 }
 
 // CheckBudget handles checking the budget for a campaign
-/*func (h *campaignHandlers) CheckBudget(c *gin.Context) {
+func (h *campaignHandlers) CheckBudget(c *gin.Context) {
+	log.Println("CheckBudget: Received request to check a campaign's budget.")
 	campaignID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+		log.Printf("CheckBudget: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
 		return
 	}
 
-	budgetDifference, err := h.service.CheckBudget(campaignID)
+	budget, err := h.service.CheckBudget(c.Request.Context(), campaignID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Printf("CheckBudget: Failed to check budget for campaign ID %d: %v", campaignID, err)
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"budget_difference": budgetDifference})
-}*/
+	c.JSON(http.StatusOK, budget)
+}
 
 func (h *campaignHandlers) GetCampaignsByClientID(c *gin.Context) {
 	log.Println("GetCampaignsByClientID: Received request to fetch campaigns by client ID.")
 	clientID, err := strconv.Atoi(c.Param("clientID"))
 	if err != nil {
 		log.Printf("GetCampaignsByClientID: Invalid client ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid client id"})
+		c.Error(apperrors.BadRequest("invalid client id"))
 		return
 	}
 
-	campaigns, err := h.service.GetCampaignsByClientID(clientID)
+	campaigns, err := h.service.GetCampaignsByClientID(c.Request.Context(), clientID)
 	if err != nil {
 		log.Printf("GetCampaignsByClientID: Failed to fetch campaigns for client ID %d: %v", clientID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, campaigns)
 }
+
+func (h *campaignHandlers) TransitionCampaign(c *gin.Context) {
+	log.Println("TransitionCampaign: Received request to change a campaign's state.")
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("TransitionCampaign: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	principal, _ := middleware.PrincipalFrom(c)
+	if err := h.policy.RequireCampaignManager(c.Request.Context(), principal, campaignID); err != nil {
+		log.Printf("TransitionCampaign: Staff ID %d may not transition campaign ID %d: %v", principal.StaffID, campaignID, err)
+		c.Error(err)
+		return
+	}
+
+	var transition struct {
+		ToState models.CampaignState `json:"to_state" validate:"required,enum"`
+	}
+	if err := bindJSON(c, &transition); err != nil {
+		log.Printf("TransitionCampaign: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	history, err := h.service.TransitionCampaign(c.Request.Context(), campaignID, transition.ToState, principal.StaffID)
+	if err != nil {
+		log.Printf("TransitionCampaign: Failed to transition campaign with ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+func (h *campaignHandlers) GetCampaignHistory(c *gin.Context) {
+	log.Println("GetCampaignHistory: Received request to fetch a campaign's state history.")
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetCampaignHistory: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	history, err := h.service.GetCampaignHistory(c.Request.Context(), campaignID)
+	if err != nil {
+		log.Printf("GetCampaignHistory: Failed to fetch history for campaign ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+func (h *campaignHandlers) GetManagerHistory(c *gin.Context) {
+	log.Println("GetManagerHistory: Received request to fetch a campaign's manager history.")
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetManagerHistory: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	history, err := h.service.GetManagerHistory(c.Request.Context(), campaignID)
+	if err != nil {
+		log.Printf("GetManagerHistory: Failed to fetch manager history for campaign ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
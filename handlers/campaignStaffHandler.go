@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/services"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CampaignStaffHandlers interface {
+	GetCampaignStaff(c *gin.Context)
+	AssignStaff(c *gin.Context)
+	RemoveStaff(c *gin.Context)
+}
+
+type campaignStaffHandlers struct {
+	campaignStaffService services.CampaignStaffService
+}
+
+func NewCampaignStaffHandlers(service services.CampaignStaffService) CampaignStaffHandlers {
+	return &campaignStaffHandlers{
+		campaignStaffService: service,
+	}
+}
+
+func (h *campaignStaffHandlers) GetCampaignStaff(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetCampaignStaff: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	assignments, err := h.campaignStaffService.GetCampaignStaff(c.Request.Context(), campaignID)
+	if err != nil {
+		log.Printf("GetCampaignStaff: Failed to fetch staff for campaign ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, assignments)
+}
+
+func (h *campaignStaffHandlers) AssignStaff(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("AssignStaff: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	var assignment models.CampaignStaff
+	if err := bindJSON(c, &assignment); err != nil {
+		log.Printf("AssignStaff: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+	assignment.CampaignID = campaignID
+
+	if err := h.campaignStaffService.AssignStaff(c.Request.Context(), &assignment); err != nil {
+		log.Printf("AssignStaff: Failed to assign staff to campaign ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, assignment)
+}
+
+func (h *campaignStaffHandlers) RemoveStaff(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("RemoveStaff: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	staffID, err := strconv.Atoi(c.Param("staffID"))
+	if err != nil {
+		log.Printf("RemoveStaff: Invalid staff ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid staff id"))
+		return
+	}
+
+	if err := h.campaignStaffService.RemoveStaff(c.Request.Context(), campaignID, staffID); err != nil {
+		log.Printf("RemoveStaff: Failed to remove staff ID %d from campaign ID %d: %v", staffID, campaignID, err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "staff removed from campaign"})
+}
@@ -1,9 +1,9 @@
 package handlers
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"agate-project/services"
-	"context"
 	"log"
 	"net/http"
 	"strconv"
@@ -16,43 +16,46 @@ type CampaignManagerHandlers interface {
 	CreateManager(c *gin.Context)
 	DeleteManager(c *gin.Context)
 	//UpdateManager(c *gin.Context)
-	//AssignStaffToManager(c *gin.Context)
 }
 
 type campaignManagerHandlers struct {
-	ctx            context.Context
 	managerService services.CampaignManagerService
 }
 
-func NewCampaignManagerHandlers(ctx context.Context, service services.CampaignManagerService) CampaignManagerHandlers {
+func NewCampaignManagerHandlers(service services.CampaignManagerService) CampaignManagerHandlers {
 	return &campaignManagerHandlers{
-		ctx:            ctx,
 		managerService: service,
 	}
 }
 
 func (h *campaignManagerHandlers) GetAllManagers(c *gin.Context) {
-	managers, err := h.managerService.GetAllCampaignManager()
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Printf("GetAllManagers: Invalid list parameters: %v", err)
+		c.Error(err)
+		return
+	}
+	page, err := h.managerService.GetAllCampaignManager(c.Request.Context(), opts)
 	if err != nil {
 		log.Printf("GetAllManagers: Failed to fetch campaign managers: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch campaign managers"})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, managers)
+	writePage(c, page)
 }
 
 func (h *campaignManagerHandlers) CreateManager(c *gin.Context) {
 	var manager models.CampaignManager
 
-	if err := c.ShouldBindJSON(&manager); err != nil {
+	if err := bindJSON(c, &manager); err != nil {
 		log.Printf("CreateManager: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 
-	if err := h.managerService.AddCampaignManager(&manager); err != nil {
+	if err := h.managerService.AddCampaignManager(c.Request.Context(), &manager); err != nil {
 		log.Printf("CreateManager: Failed to add campaign manager: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add campaign manager"})
+		c.Error(err)
 		return
 	}
 
@@ -65,36 +68,15 @@ func (h *campaignManagerHandlers) DeleteManager(c *gin.Context) {
 	managerID, err := strconv.Atoi(managerIDStr)
 	if err != nil {
 		log.Printf("DeleteManager: Invalid manager ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid manager id"})
+		c.Error(apperrors.BadRequest("invalid manager id"))
 		return
 	}
 
-	if err := h.managerService.DeleteCampaignManager(managerID); err != nil {
+	if err := h.managerService.DeleteCampaignManager(c.Request.Context(), managerID); err != nil {
 		log.Printf("DeleteManager: Failed to delete campaign manager with ID %d: %v", managerID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "campaign manager deleted"})
 }
-
-/*func (h *campaignManagerHandlers) AssignStaffToManager(c *gin.Context) {
-	var assignRequest struct {
-		StaffID   int `json:"staff_id"`
-		ManagerID int `json:"manager_id"`
-	}
-
-	if err := c.ShouldBindJSON(&assignRequest); err != nil {
-		log.Printf("AssignStaffToManager: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
-		return
-	}
-
-	if err := h.managerService.AssignStaffToManager(assignRequest.StaffID, assignRequest.ManagerID); err != nil {
-		log.Printf("AssignStaffToManager: Failed to assign staff to manager: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "staff assigned to manager"})
-}*/
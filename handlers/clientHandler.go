@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"context"
+	"agate-project/apperrors"
 	"log"
 	"net/http"
 	"strconv"
@@ -16,62 +16,75 @@ type ClientHandlers interface {
 	GetClients(c *gin.Context)
 	CreateClient(c *gin.Context)
 	RemoveClient(c *gin.Context)
+	RestoreClient(c *gin.Context)
 	UpdateClient(c *gin.Context)
+	PatchClient(c *gin.Context)
 	GetClientByID(c *gin.Context)
 }
 
 type clientHandlers struct {
-	ctx         context.Context
 	userService services.ClientService
 }
 
-func NewClientHandlers(ctx context.Context, service services.ClientService) ClientHandlers {
+func NewClientHandlers(service services.ClientService) ClientHandlers {
 	return &clientHandlers{
-		ctx:         ctx,
 		userService: service,
 	}
 }
 
 func (h *clientHandlers) GetClients(c *gin.Context) {
-	clients, err := h.userService.FetchAllClients()
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Printf("GetClients: Invalid list parameters: %v", err)
+		c.Error(err)
+		return
+	}
+	page, err := h.userService.FetchAllClients(c.Request.Context(), opts)
 	if err != nil {
 		log.Printf("GetClients: Failed to fetch clients: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch clients"})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, clients)
+	writePage(c, page)
 }
 
 func (h *clientHandlers) GetClientByID(c *gin.Context) {
 	clientID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("GetClientByID: Invalid client ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid client ID"})
+		c.Error(apperrors.BadRequest("invalid client ID"))
+		return
+	}
+
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	client, err := h.userService.GetClientByID(clientID)
+	client, err := h.userService.GetClientByID(c.Request.Context(), clientID, withDeleted)
 	if err != nil {
 		log.Printf("GetClientByID: Failed to fetch client with ID %d: %v", clientID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
+	setETag(c, client.Version)
 	c.JSON(http.StatusOK, gin.H{"data": client})
 }
 
 func (h *clientHandlers) CreateClient(c *gin.Context) {
 	var client models.Client
 
-	if err := c.ShouldBindJSON(&client); err != nil {
+	if err := bindJSON(c, &client); err != nil {
 		log.Printf("CreateClient: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 
-	if err := h.userService.AddNewClient(&client); err != nil {
+	if err := h.userService.AddNewClient(c.Request.Context(), &client); err != nil {
 		log.Printf("CreateClient: Failed to add client: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add client"})
+		c.Error(err)
 		return
 	}
 
@@ -79,60 +92,125 @@ func (h *clientHandlers) CreateClient(c *gin.Context) {
 		"message": "client added"})
 }
 
+// RemoveClient takes strategy=restrict|cascade|archive and dry_run=true,
+// which reports what the deletion would touch without doing it.
 func (h *clientHandlers) RemoveClient(c *gin.Context) {
 	clientIDStr := c.Param("id")
 
 	clientID, err := strconv.Atoi(clientIDStr)
 	if err != nil {
 		log.Printf("RemoveClient: Invalid client ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid client ID"})
+		c.Error(apperrors.BadRequest("invalid client ID"))
 		return
 	}
 
-	if err := h.userService.RemoveClient(clientID); err != nil {
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			log.Printf("RemoveClient: Invalid dry_run %q: %v", value, err)
+			c.Error(apperrors.BadRequest("dry_run must be true or false"))
+			return
+		}
+	}
+	strategy := models.DeleteStrategy(c.Query("strategy"))
+
+	deletion, err := h.userService.RemoveClient(c.Request.Context(), clientID, strategy, dryRun)
+	if err != nil {
 		log.Printf("RemoveClient: Failed to delete client with ID %d: %v", clientID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "client deleted"})
+	message := "client deleted"
+	if dryRun {
+		message = "dry run, nothing deleted"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "data": deletion})
+}
+
+func (h *clientHandlers) RestoreClient(c *gin.Context) {
+	clientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("RestoreClient: Invalid client ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid client ID"))
+		return
+	}
+
+	client, err := h.userService.RestoreClient(c.Request.Context(), clientID)
+	if err != nil {
+		log.Printf("RestoreClient: Failed to restore client with ID %d: %v", clientID, err)
+		c.Error(err)
+		return
+	}
+
+	setETag(c, client.Version)
+	c.JSON(http.StatusOK, gin.H{"data": client})
 }
 
+// UpdateClient replaces the client's details; name is required and fields
+// left out of the body are cleared.
 func (h *clientHandlers) UpdateClient(c *gin.Context) {
 	clientID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("UpdateClient: Invalid client ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid client ID"})
+		c.Error(apperrors.BadRequest("invalid client ID"))
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	var client models.Client
-	if err := c.ShouldBindJSON(&client); err != nil {
+	if err := bindJSON(c, &client); err != nil {
 		log.Printf("UpdateClient: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
+	client.ClientID = clientID
+	client.Version = version
+
+	if err := h.userService.UpdateClient(c.Request.Context(), &client); err != nil {
+		log.Printf("UpdateClient: Failed to update client with ID %d: %v", clientID, err)
+		c.Error(err)
+		return
+	}
+
+	setETag(c, client.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "client updated"})
+}
 
-	var namePtr *string
-	if client.Name != "" {
-		namePtr = &client.Name
+// PatchClient changes only the fields named in a JSON merge patch.
+func (h *clientHandlers) PatchClient(c *gin.Context) {
+	clientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("PatchClient: Invalid client ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid client ID"))
+		return
 	}
 
-	var addressPtr *string
-	if client.Address != "" {
-		addressPtr = &client.Address
+	version, err := ifMatch(c)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	var contactDetailsPtr *string
-	if client.ContactDetails != "" {
-		contactDetailsPtr = &client.ContactDetails
+	patch, err := readMergePatch(c)
+	if err != nil {
+		log.Printf("PatchClient: Invalid request body: %v", err)
+		c.Error(err)
+		return
 	}
 
-	if err := h.userService.UpdateClient(clientID, namePtr, addressPtr, contactDetailsPtr); err != nil {
-		log.Printf("UpdateClient: Failed to update client with ID %d: %v", clientID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	client, err := h.userService.PatchClient(c.Request.Context(), clientID, version, patch)
+	if err != nil {
+		log.Printf("PatchClient: Failed to patch client with ID %d: %v", clientID, err)
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "client updated"})
+	setETag(c, client.Version)
+	c.JSON(http.StatusOK, gin.H{"data": client})
 }
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/services"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CostEntryHandlers interface {
+	GetCostEntries(c *gin.Context)
+	CreateCostEntry(c *gin.Context)
+	RemoveCostEntry(c *gin.Context)
+}
+
+// costEntryResponse is a new cost entry with its campaign's budget after
+// it, whose status flags spending past the warning threshold.
+type costEntryResponse struct {
+	models.CostEntry
+	BudgetCheck models.CampaignBudget `json:"budget_check"`
+}
+
+type costEntryHandlers struct {
+	costService services.CostEntryService
+}
+
+func NewCostEntryHandlers(service services.CostEntryService) CostEntryHandlers {
+	return &costEntryHandlers{
+		costService: service,
+	}
+}
+
+func (h *costEntryHandlers) GetCostEntries(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetCostEntries: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	entries, err := h.costService.GetCostEntriesByCampaign(c.Request.Context(), campaignID)
+	if err != nil {
+		log.Printf("GetCostEntries: Failed to fetch cost entries for campaign ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func (h *costEntryHandlers) CreateCostEntry(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("CreateCostEntry: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	var entry models.CostEntry
+	if err := bindJSON(c, &entry); err != nil {
+		log.Printf("CreateCostEntry: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+	entry.CampaignID = campaignID
+
+	budget, err := h.costService.AddCostEntry(c.Request.Context(), &entry)
+	if err != nil {
+		log.Printf("CreateCostEntry: Failed to add cost entry: %v", err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, costEntryResponse{CostEntry: entry, BudgetCheck: budget})
+}
+
+func (h *costEntryHandlers) RemoveCostEntry(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("RemoveCostEntry: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	entryID, err := strconv.Atoi(c.Param("entryID"))
+	if err != nil {
+		log.Printf("RemoveCostEntry: Invalid cost entry ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid cost entry ID"))
+		return
+	}
+
+	if err := h.costService.RemoveCostEntry(c.Request.Context(), campaignID, entryID); err != nil {
+		log.Printf("RemoveCostEntry: Failed to remove cost entry with ID %d: %v", entryID, err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "cost entry deleted"})
+}
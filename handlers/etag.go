@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"agate-project/apperrors"
+	"agate-project/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag sends a record's version as its entity tag.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// ifMatch reads the version an update was made against from the If-Match
+// header, which must hold one entity tag as sent by setETag, or * for
+// whatever version the record is at. Updates of versioned records require
+// it, so a missing header is 428 and one that cannot match any version is
+// 412. If-Match compares tags strongly, so weak tags never match.
+func ifMatch(c *gin.Context) (int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return 0, apperrors.PreconditionRequired("If-Match header is required")
+	}
+	if header == "*" {
+		return services.AnyVersion, nil
+	}
+	if strings.HasPrefix(header, "W/") {
+		return 0, apperrors.PreconditionFailed("If-Match needs a strong entity tag")
+	}
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, apperrors.PreconditionFailed("If-Match must be a single entity tag")
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return 0, apperrors.PreconditionFailed("If-Match does not match the current version")
+	}
+	return version, nil
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"agate-project/db"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+type HealthHandlers interface {
+	Health(c *gin.Context)
+}
+
+type healthHandlers struct {
+	conn *sqlx.DB
+}
+
+func NewHealthHandlers(conn *sqlx.DB) HealthHandlers {
+	return &healthHandlers{conn: conn}
+}
+
+// Health reports whether the database answers, with connection pool
+// statistics. It returns 503 when the database is unreachable.
+func (h *healthHandlers) Health(c *gin.Context) {
+	status, code := "ok", http.StatusOK
+	if err := h.conn.PingContext(c.Request.Context()); err != nil {
+		log.Printf("Health: Database ping failed: %v", err)
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status":   status,
+		"database": db.Stats(h.conn),
+	})
+}
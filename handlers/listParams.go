@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"agate-project/apperrors"
+	"agate-project/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseListOptions reads limit, offset, sort=field[:asc|desc] and
+// include_deleted from the query string. Every other query parameter is
+// passed on as a filter; the repository rejects names it does not know.
+func parseListOptions(c *gin.Context) (models.ListOptions, error) {
+	opts := models.ListOptions{Limit: models.DefaultPageLimit, Filters: map[string]string{}}
+
+	for key, values := range c.Request.URL.Query() {
+		if len(values) == 0 {
+			continue
+		}
+		value := values[len(values)-1]
+		switch key {
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				return opts, apperrors.BadRequest("limit must be a positive integer")
+			}
+			opts.Limit = min(limit, models.MaxPageLimit)
+		case "offset":
+			offset, err := strconv.Atoi(value)
+			if err != nil || offset < 0 {
+				return opts, apperrors.BadRequest("offset must be a non-negative integer")
+			}
+			opts.Offset = offset
+		case "sort":
+			field, direction, _ := strings.Cut(value, ":")
+			switch direction {
+			case "", "asc":
+			case "desc":
+				opts.Desc = true
+			default:
+				return opts, apperrors.BadRequest("sort direction must be asc or desc")
+			}
+			opts.Sort = field
+		case "include_deleted":
+			include, err := parseIncludeDeleted(value)
+			if err != nil {
+				return opts, err
+			}
+			opts.IncludeDeleted = include
+		default:
+			opts.Filters[key] = value
+		}
+	}
+	return opts, nil
+}
+
+// includeDeleted reads include_deleted=true, which lets get endpoints return
+// soft-deleted records.
+func includeDeleted(c *gin.Context) (bool, error) {
+	return parseIncludeDeleted(c.Query("include_deleted"))
+}
+
+func parseIncludeDeleted(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, apperrors.BadRequest("include_deleted must be true or false")
+	}
+	return include, nil
+}
+
+// writePage renders a page envelope, filling in the link to the next page
+// when there are more rows to fetch.
+func writePage[T any](c *gin.Context, page models.Page[T]) {
+	if next := page.Offset + len(page.Items); next < page.Total {
+		query := c.Request.URL.Query()
+		query.Set("limit", strconv.Itoa(page.Limit))
+		query.Set("offset", strconv.Itoa(next))
+		page.Next = c.Request.URL.Path + "?" + query.Encode()
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"agate-project/apperrors"
+	"agate-project/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// readMergePatch reads a PATCH body, which must be a JSON merge patch
+// object. Bodies labelled application/json are accepted as well.
+func readMergePatch(c *gin.Context) (models.MergePatch, error) {
+	if contentType := c.ContentType(); contentType != mergePatchContentType && contentType != gin.MIMEJSON {
+		return nil, apperrors.UnsupportedMediaType("PATCH bodies must be %s", mergePatchContentType)
+	}
+	var patch models.MergePatch
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil || patch == nil {
+		return nil, apperrors.BadRequest("merge patch must be a JSON object")
+	}
+	return patch, nil
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"agate-project/apperrors"
+	"agate-project/middleware"
+	"agate-project/services"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PortalHandlers interface {
+	Login(c *gin.Context)
+	Refresh(c *gin.Context)
+	Logout(c *gin.Context)
+	GetCampaigns(c *gin.Context)
+	GetCampaign(c *gin.Context)
+	GetCampaignAdverts(c *gin.Context)
+	GetSchedule(c *gin.Context)
+	CreateClientAccount(c *gin.Context)
+	GetClientAccounts(c *gin.Context)
+}
+
+type portalHandlers struct {
+	authService   services.ClientAuthService
+	portalService services.PortalService
+}
+
+func NewPortalHandlers(authService services.ClientAuthService, portalService services.PortalService) PortalHandlers {
+	return &portalHandlers{
+		authService:   authService,
+		portalService: portalService,
+	}
+}
+
+func (h *portalHandlers) Login(c *gin.Context) {
+	var req credentialsRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("Login: Invalid portal request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	tokens, err := h.authService.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *portalHandlers) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("Refresh: Invalid portal request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	tokens, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *portalHandlers) Logout(c *gin.Context) {
+	var req refreshRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("Logout: Invalid portal request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *portalHandlers) GetCampaigns(c *gin.Context) {
+	principal, _ := middleware.ClientPrincipalFrom(c)
+	campaigns, err := h.portalService.GetCampaigns(c.Request.Context(), principal.ClientID)
+	if err != nil {
+		log.Printf("GetCampaigns: Failed to fetch campaigns for client ID %d: %v", principal.ClientID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, campaigns)
+}
+
+func (h *portalHandlers) GetCampaign(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetCampaign: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	principal, _ := middleware.ClientPrincipalFrom(c)
+	campaign, err := h.portalService.GetCampaign(c.Request.Context(), principal.ClientID, campaignID)
+	if err != nil {
+		log.Printf("GetCampaign: Failed to fetch campaign ID %d for client ID %d: %v", campaignID, principal.ClientID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, campaign)
+}
+
+func (h *portalHandlers) GetCampaignAdverts(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetCampaignAdverts: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	principal, _ := middleware.ClientPrincipalFrom(c)
+	adverts, err := h.portalService.GetCampaignAdverts(c.Request.Context(), principal.ClientID, campaignID)
+	if err != nil {
+		log.Printf("GetCampaignAdverts: Failed to fetch adverts for campaign ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, adverts)
+}
+
+func (h *portalHandlers) GetSchedule(c *gin.Context) {
+	principal, _ := middleware.ClientPrincipalFrom(c)
+	adverts, err := h.portalService.GetSchedule(c.Request.Context(), principal.ClientID)
+	if err != nil {
+		log.Printf("GetSchedule: Failed to fetch schedule for client ID %d: %v", principal.ClientID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, adverts)
+}
+
+// CreateClientAccount is used by staff to give a client a portal login.
+func (h *portalHandlers) CreateClientAccount(c *gin.Context) {
+	clientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("CreateClientAccount: Invalid client ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid client ID"))
+		return
+	}
+
+	var req credentialsRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("CreateClientAccount: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	account, err := h.authService.AddAccount(c.Request.Context(), clientID, req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, account)
+}
+
+func (h *portalHandlers) GetClientAccounts(c *gin.Context) {
+	clientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetClientAccounts: Invalid client ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid client ID"))
+		return
+	}
+
+	accounts, err := h.authService.GetAccounts(c.Request.Context(), clientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
@@ -1,12 +1,13 @@
 package handlers
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"agate-project/services"
-	"context"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,42 +18,57 @@ type StaffHandlers interface {
 	CreateStaff(c *gin.Context)
 	RemoveStaff(c *gin.Context)
 	UpdateStaff(c *gin.Context)
+	PatchStaff(c *gin.Context)
+	SetStaffStatus(c *gin.Context)
+	PromoteStaff(c *gin.Context)
+	GetGradeHistory(c *gin.Context)
+}
+
+// promotionRequest moves a staff member to grade_id. effective_date is
+// optional and defaults to today.
+type promotionRequest struct {
+	GradeID       int       `json:"grade_id" validate:"required"`
+	EffectiveDate time.Time `json:"effective_date"`
 }
 
 type staffHandlers struct {
-	ctx         context.Context
 	userService services.StaffService
 }
 
-func NewStaffHandlers(ctx context.Context, service services.StaffService) StaffHandlers {
+func NewStaffHandlers(service services.StaffService) StaffHandlers {
 	return &staffHandlers{
-		ctx:         ctx,
 		userService: service,
 	}
 }
 
 func (h *staffHandlers) GetStaff(c *gin.Context) {
-	staff, err := h.userService.FetchAllStaff()
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Printf("GetStaff: Invalid list parameters: %v", err)
+		c.Error(err)
+		return
+	}
+	page, err := h.userService.FetchAllStaff(c.Request.Context(), opts)
 	if err != nil {
 		log.Printf("GetStaff: Failed to fetch staff: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch staff"})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, staff)
+	writePage(c, page)
 }
 
 func (h *staffHandlers) GetStaffByID(c *gin.Context) {
 	staffID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("GetStaffByID: Invalid staff ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid staff ID"})
+		c.Error(apperrors.BadRequest("invalid staff ID"))
 		return
 	}
 
-	staff, err := h.userService.GetStaffByID(staffID)
+	staff, err := h.userService.GetStaffByID(c.Request.Context(), staffID)
 	if err != nil {
 		log.Printf("GetStaffByID: Failed to retrieve staff with ID %d: %v", staffID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -62,15 +78,15 @@ func (h *staffHandlers) GetStaffByID(c *gin.Context) {
 func (h *staffHandlers) CreateStaff(c *gin.Context) {
 	var staff models.Staff
 
-	if err := c.ShouldBindJSON(&staff); err != nil {
+	if err := bindJSON(c, &staff); err != nil {
 		log.Printf("CreateStaff: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 
-	if err := h.userService.AddStaff(&staff); err != nil {
+	if err := h.userService.AddStaff(c.Request.Context(), &staff); err != nil {
 		log.Printf("CreateStaff: Failed to add staff: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add staff"})
+		c.Error(err)
 		return
 	}
 
@@ -83,13 +99,13 @@ func (h *staffHandlers) RemoveStaff(c *gin.Context) {
 	staffID, err := strconv.Atoi(staffIDStr)
 	if err != nil {
 		log.Printf("RemoveStaff: Invalid staff ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid staff id"})
+		c.Error(apperrors.BadRequest("invalid staff id"))
 		return
 	}
 
-	if err := h.userService.RemoveStaff(staffID); err != nil {
+	if err := h.userService.RemoveStaff(c.Request.Context(), staffID); err != nil {
 		log.Printf("RemoveStaff: Failed to delete staff with ID %d: %v", staffID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -100,22 +116,118 @@ func (h *staffHandlers) UpdateStaff(c *gin.Context) {
 	staffID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("UpdateStaff: Invalid staff ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid staff id"})
+		c.Error(apperrors.BadRequest("invalid staff id"))
 		return
 	}
 
 	var updatedDetails models.Staff
-	if err := c.ShouldBindJSON(&updatedDetails); err != nil {
+	if err := bindJSON(c, &updatedDetails); err != nil {
 		log.Printf("UpdateStaff: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 
-	if err := h.userService.UpdateStaff(staffID, &updatedDetails); err != nil {
+	if err := h.userService.UpdateStaff(c.Request.Context(), staffID, &updatedDetails); err != nil {
 		log.Printf("UpdateStaff: Failed to update staff with ID %d: %v", staffID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "staff updated"})
 }
+
+// PatchStaff changes only the fields named in a JSON merge patch. Staff have
+// no version, so no If-Match is needed.
+func (h *staffHandlers) PatchStaff(c *gin.Context) {
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("PatchStaff: Invalid staff ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid staff id"))
+		return
+	}
+
+	patch, err := readMergePatch(c)
+	if err != nil {
+		log.Printf("PatchStaff: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	staff, err := h.userService.PatchStaff(c.Request.Context(), staffID, patch)
+	if err != nil {
+		log.Printf("PatchStaff: Failed to patch staff with ID %d: %v", staffID, err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, staff)
+}
+
+func (h *staffHandlers) SetStaffStatus(c *gin.Context) {
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("SetStaffStatus: Invalid staff ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid staff id"))
+		return
+	}
+
+	var status struct {
+		Active *bool `json:"active" validate:"required"`
+	}
+	if err := bindJSON(c, &status); err != nil {
+		log.Printf("SetStaffStatus: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	if err := h.userService.SetStaffActive(c.Request.Context(), staffID, *status.Active); err != nil {
+		log.Printf("SetStaffStatus: Failed to update status of staff with ID %d: %v", staffID, err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "staff status updated"})
+}
+
+func (h *staffHandlers) PromoteStaff(c *gin.Context) {
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("PromoteStaff: Invalid staff ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid staff id"))
+		return
+	}
+
+	var req promotionRequest
+	if err := bindJSON(c, &req); err != nil {
+		log.Printf("PromoteStaff: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	change, err := h.userService.Promote(c.Request.Context(), staffID, req.GradeID, req.EffectiveDate)
+	if err != nil {
+		log.Printf("PromoteStaff: Failed to promote staff with ID %d: %v", staffID, err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, change)
+}
+
+func (h *staffHandlers) GetGradeHistory(c *gin.Context) {
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetGradeHistory: Invalid staff ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid staff id"))
+		return
+	}
+
+	history, err := h.userService.GetGradeHistory(c.Request.Context(), staffID)
+	if err != nil {
+		log.Printf("GetGradeHistory: Failed to retrieve grade history for staff with ID %d: %v", staffID, err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
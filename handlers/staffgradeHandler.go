@@ -1,9 +1,9 @@
 package handlers
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"agate-project/services"
-	"context"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,45 +13,70 @@ import (
 
 type StaffGradeHandlers interface {
 	GetAllGrades(c *gin.Context)
+	GetGrade(c *gin.Context)
 	CreateGrade(c *gin.Context)
 	RemoveGrade(c *gin.Context)
 	UpdateGrade(c *gin.Context)
+	PatchGrade(c *gin.Context)
 }
 
 type staffGradeHandlers struct {
-	ctx          context.Context
 	gradeService services.StaffGradeService
 }
 
-func NewStaffGradeHandlers(ctx context.Context, service services.StaffGradeService) StaffGradeHandlers {
+func NewStaffGradeHandlers(service services.StaffGradeService) StaffGradeHandlers {
 	return &staffGradeHandlers{
-		ctx:          ctx,
 		gradeService: service,
 	}
 }
 
 func (h *staffGradeHandlers) GetAllGrades(c *gin.Context) {
-	grades, err := h.gradeService.FetchAllGrades()
+	opts, err := parseListOptions(c)
+	if err != nil {
+		log.Printf("GetAllGrades: Invalid list parameters: %v", err)
+		c.Error(err)
+		return
+	}
+	page, err := h.gradeService.FetchAllGrades(c.Request.Context(), opts)
 	if err != nil {
 		log.Printf("GetAllGrades: Failed to fetch grades: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch grades"})
+		c.Error(err)
+		return
+	}
+	writePage(c, page)
+}
+
+func (h *staffGradeHandlers) GetGrade(c *gin.Context) {
+	gradeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetGrade: Invalid grade ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid grade id"))
+		return
+	}
+
+	grade, err := h.gradeService.GetGrade(c.Request.Context(), gradeID)
+	if err != nil {
+		log.Printf("GetGrade: Failed to fetch grade with ID %d: %v", gradeID, err)
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, grades)
+
+	setETag(c, grade.Version)
+	c.JSON(http.StatusOK, grade)
 }
 
 func (h *staffGradeHandlers) CreateGrade(c *gin.Context) {
 	var grade models.StaffGrade
 
-	if err := c.ShouldBindJSON(&grade); err != nil {
+	if err := bindJSON(c, &grade); err != nil {
 		log.Printf("CreateGrade: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
 
-	if err := h.gradeService.AddGrade(&grade); err != nil {
+	if err := h.gradeService.AddGrade(c.Request.Context(), &grade); err != nil {
 		log.Printf("CreateGrade: Failed to add grade: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add grade"})
+		c.Error(err)
 		return
 	}
 
@@ -64,48 +89,84 @@ func (h *staffGradeHandlers) RemoveGrade(c *gin.Context) {
 	gradeID, err := strconv.Atoi(gradeIDStr)
 	if err != nil {
 		log.Printf("RemoveGrade: Invalid grade ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid grade id"})
+		c.Error(apperrors.BadRequest("invalid grade id"))
 		return
 	}
 
-	if err := h.gradeService.RemoveGrade(gradeID); err != nil {
+	if err := h.gradeService.RemoveGrade(c.Request.Context(), gradeID); err != nil {
 		log.Printf("RemoveGrade: Failed to remove grade with ID %d: %v", gradeID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "grade successfully removed"})
 }
 
+// UpdateGrade replaces the grade's name and pay rate, both of which are
+// required.
 func (h *staffGradeHandlers) UpdateGrade(c *gin.Context) {
 	gradeID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("UpdateGrade: Invalid grade ID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid grade id"})
+		c.Error(apperrors.BadRequest("invalid grade id"))
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	var grade models.StaffGrade
-	if err := c.ShouldBindJSON(&grade); err != nil {
+	if err := bindJSON(c, &grade); err != nil {
 		log.Printf("UpdateGrade: Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.Error(err)
 		return
 	}
+	grade.GradeID = gradeID
+	grade.Version = version
 
-	var gradeNamePtr *string
-	if grade.GradeName != "" {
-		gradeNamePtr = &grade.GradeName
+	if err := h.gradeService.UpdateGrade(c.Request.Context(), &grade); err != nil {
+		log.Printf("UpdateGrade: Failed to update grade with ID %d: %v", gradeID, err)
+		c.Error(err)
+		return
 	}
-	var payRatePtr *int
-	if grade.PayRate != 0 {
-		payRatePtr = &grade.PayRate
+
+	setETag(c, grade.Version)
+
+	c.JSON(http.StatusOK, gin.H{"message": "grade updated"})
+}
+
+// PatchGrade changes only the fields named in a JSON merge patch.
+func (h *staffGradeHandlers) PatchGrade(c *gin.Context) {
+	gradeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("PatchGrade: Invalid grade ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid grade id"))
+		return
 	}
 
-	if err := h.gradeService.UpdateGrade(gradeID, gradeNamePtr, payRatePtr); err != nil {
-		log.Printf("UpdateGrade: Failed to update grade with ID %d: %v", gradeID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, err := ifMatch(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "grade updated"})
+	patch, err := readMergePatch(c)
+	if err != nil {
+		log.Printf("PatchGrade: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+
+	grade, err := h.gradeService.PatchGrade(c.Request.Context(), gradeID, version, patch)
+	if err != nil {
+		log.Printf("PatchGrade: Failed to patch grade with ID %d: %v", gradeID, err)
+		c.Error(err)
+		return
+	}
+
+	setETag(c, grade.Version)
+	c.JSON(http.StatusOK, grade)
 }
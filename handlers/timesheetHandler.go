@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"agate-project/apperrors"
+	"agate-project/middleware"
+	"agate-project/models"
+	"agate-project/services"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimesheetHandlers interface {
+	LogTimesheet(c *gin.Context)
+	GetTimesheetsByStaff(c *gin.Context)
+	GetLabourCost(c *gin.Context)
+}
+
+type timesheetHandlers struct {
+	timesheetService services.TimesheetService
+	policy           services.Policy
+}
+
+func NewTimesheetHandlers(service services.TimesheetService, policy services.Policy) TimesheetHandlers {
+	return &timesheetHandlers{
+		timesheetService: service,
+		policy:           policy,
+	}
+}
+
+func (h *timesheetHandlers) LogTimesheet(c *gin.Context) {
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("LogTimesheet: Invalid staff ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid staff id"))
+		return
+	}
+
+	principal, _ := middleware.PrincipalFrom(c)
+	if err := h.policy.RequireSelfOr(principal, staffID, models.PermManageStaff); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var timesheet models.Timesheet
+	if err := bindJSON(c, &timesheet); err != nil {
+		log.Printf("LogTimesheet: Invalid request body: %v", err)
+		c.Error(err)
+		return
+	}
+	timesheet.StaffID = staffID
+
+	if err := h.timesheetService.LogTimesheet(c.Request.Context(), &timesheet); err != nil {
+		log.Printf("LogTimesheet: Failed to log timesheet for staff ID %d: %v", staffID, err)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, timesheet)
+}
+
+func (h *timesheetHandlers) GetTimesheetsByStaff(c *gin.Context) {
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetTimesheetsByStaff: Invalid staff ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid staff id"))
+		return
+	}
+
+	principal, _ := middleware.PrincipalFrom(c)
+	if err := h.policy.RequireSelfOr(principal, staffID, models.PermReadStaff); err != nil {
+		c.Error(err)
+		return
+	}
+
+	timesheets, err := h.timesheetService.GetTimesheetsByStaff(c.Request.Context(), staffID)
+	if err != nil {
+		log.Printf("GetTimesheetsByStaff: Failed to fetch timesheets for staff ID %d: %v", staffID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, timesheets)
+}
+
+func (h *timesheetHandlers) GetLabourCost(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("GetLabourCost: Invalid campaign ID: %v", err)
+		c.Error(apperrors.BadRequest("invalid campaign ID"))
+		return
+	}
+
+	labour, err := h.timesheetService.GetLabourCost(c.Request.Context(), campaignID)
+	if err != nil {
+		log.Printf("GetLabourCost: Failed to fetch labour cost for campaign ID %d: %v", campaignID, err)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, labour)
+}
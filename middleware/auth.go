@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"strings"
+
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const principalKey = "principal"
+
+// Authenticate requires a valid bearer access token and stores the caller's
+// principal on the context for handlers to read with PrincipalFrom.
+func Authenticate(auth services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			c.Error(apperrors.Unauthorized("missing bearer token"))
+			c.Abort()
+			return
+		}
+
+		principal, err := auth.Authenticate(c.Request.Context(), strings.TrimSpace(token))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, principal)
+		// services read the caller from the request context for the audit log
+		c.Request = c.Request.WithContext(services.WithActor(c.Request.Context(), principal.StaffID))
+		c.Next()
+	}
+}
+
+// PrincipalFrom returns the authenticated caller of the request, if any.
+func PrincipalFrom(c *gin.Context) (models.Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return models.Principal{}, false
+	}
+	principal, ok := value.(models.Principal)
+	return principal, ok
+}
+
+// Require rejects the request with 403 unless the caller holds permission.
+func Require(policy services.Policy, permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := PrincipalFrom(c)
+		if err := policy.Require(principal, permission); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+const clientPrincipalKey = "clientPrincipal"
+
+// AuthenticateClient requires a valid client portal access token. Staff
+// tokens are rejected.
+func AuthenticateClient(auth services.ClientAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			c.Error(apperrors.Unauthorized("missing bearer token"))
+			c.Abort()
+			return
+		}
+
+		principal, err := auth.Authenticate(c.Request.Context(), strings.TrimSpace(token))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Set(clientPrincipalKey, principal)
+		c.Next()
+	}
+}
+
+// ClientPrincipalFrom returns the authenticated portal user of the request.
+func ClientPrincipalFrom(c *gin.Context) (models.ClientPrincipal, bool) {
+	value, ok := c.Get(clientPrincipalKey)
+	if !ok {
+		return models.ClientPrincipal{}, false
+	}
+	principal, ok := value.(models.ClientPrincipal)
+	return principal, ok
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"agate-project/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+	// Errors lists each invalid field of a rejected request body.
+	Errors []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+func statusFor(kind apperrors.Kind) int {
+	switch kind {
+	case apperrors.KindBadRequest:
+		return http.StatusBadRequest
+	case apperrors.KindNotFound:
+		return http.StatusNotFound
+	case apperrors.KindConflict:
+		return http.StatusConflict
+	case apperrors.KindValidation:
+		return http.StatusUnprocessableEntity
+	case apperrors.KindForbidden:
+		return http.StatusForbidden
+	case apperrors.KindUnauthorized:
+		return http.StatusUnauthorized
+	case apperrors.KindTimeout:
+		return http.StatusGatewayTimeout
+	case apperrors.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case apperrors.KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case apperrors.KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
+
+// Errors renders the last error a handler attached with c.Error as a problem
+// details response. Internal errors are logged and reported without detail.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		status := statusFor(apperrors.KindOf(err))
+		detail := err.Error()
+		if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		if status == http.StatusGatewayTimeout {
+			log.Printf("%s %s: timed out: %v", c.Request.Method, c.Request.URL.Path, err)
+			detail = "request timed out"
+		}
+		if status == http.StatusInternalServerError {
+			log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+			detail = "internal server error"
+		}
+		if status == http.StatusUnauthorized {
+			c.Header("WWW-Authenticate", "Bearer")
+		}
+
+		problem := newProblem(c, status, detail)
+		var appErr *apperrors.Error
+		if status == http.StatusUnprocessableEntity && errors.As(err, &appErr) {
+			problem.Errors = appErr.Fields
+		}
+		writeProblem(c, problem)
+	}
+}
+
+// WriteProblem writes a problem details response and stops the chain.
+func WriteProblem(c *gin.Context, status int, detail string) {
+	writeProblem(c, newProblem(c, status, detail))
+}
+
+func newProblem(c *gin.Context, status int, detail string) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+	}
+}
+
+func writeProblem(c *gin.Context, problem Problem) {
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
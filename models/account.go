@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// StaffAccount holds the login credentials of a staff member. Role and Active
+// are read from the staff record when the account is loaded.
+type StaffAccount struct {
+	StaffID      int       `db:"staff_id" json:"staff_id"`
+	Username     string    `db:"username" json:"username"`
+	PasswordHash string    `db:"password_hash" json:"-"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	Role         string    `db:"role" json:"-"`
+	Active       bool      `db:"active" json:"-"`
+}
+
+// AuthSession is one login. Access and refresh tokens issued for it carry its
+// ID, so revoking the session signs out both.
+type AuthSession struct {
+	SessionID string     `db:"session_id" json:"session_id"`
+	StaffID   int        `db:"staff_id" json:"staff_id"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	StaffID   int
+	Role      string
+	SessionID string
+}
@@ -3,8 +3,29 @@ package models
 import "time"
 
 type Advert struct {
-	AdvertID   int       `db:"advert_id" json:"advert_id"`
-	CampaignID int       `db:"campaign_id" json:"campaign_id"`
-	Progress   string    `db:"progress" json:"progress"`
-	RunDate    time.Time `db:"run_date" json:"run_date"`
+	AdvertID   int            `db:"advert_id" json:"advert_id"`
+	CampaignID int            `db:"campaign_id" json:"campaign_id" validate:"required"`
+	Progress   AdvertProgress `db:"progress" json:"progress" validate:"required,enum"`
+	RunDate    time.Time      `db:"run_date" json:"run_date" validate:"required"`
+	Version    int            `db:"version" json:"version"`
+	DeletedAt  *time.Time     `db:"deleted_at" json:"deleted_at,omitempty"`
+}
+
+// AdvertProgress is how far an advert has got, from planning to its run.
+type AdvertProgress string
+
+const (
+	ProgressPlanned          AdvertProgress = "planned"
+	ProgressInProduction     AdvertProgress = "in production"
+	ProgressAwaitingApproval AdvertProgress = "awaiting approval"
+	ProgressApproved         AdvertProgress = "approved"
+	ProgressRunning          AdvertProgress = "running"
+	ProgressFinished         AdvertProgress = "finished"
+)
+
+func (AdvertProgress) Options() []string {
+	return []string{
+		string(ProgressPlanned), string(ProgressInProduction), string(ProgressAwaitingApproval),
+		string(ProgressApproved), string(ProgressRunning), string(ProgressFinished),
+	}
 }
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+	AuditPurge   AuditAction = "purge"
+)
+
+// Entity types recorded in the audit log.
+const (
+	AuditClient   = "client"
+	AuditStaff    = "staff"
+	AuditGrade    = "grade"
+	AuditCampaign = "campaign"
+	AuditManager  = "campaign manager"
+	AuditAdvert   = "advert"
+)
+
+// AuditEntry records one change to a record. ActorID is the staff member who
+// made it, or nil for changes made outside a request. A create or restore
+// has only After and a delete or purge only Before, each a full snapshot; an
+// update holds just the fields that changed, with their old and new values.
+type AuditEntry struct {
+	AuditID    int64       `db:"audit_id" json:"audit_id"`
+	ActorID    *int        `db:"actor_id" json:"actor_id"`
+	EntityType string      `db:"entity_type" json:"entity_type"`
+	EntityID   int         `db:"entity_id" json:"entity_id"`
+	Action     AuditAction `db:"action" json:"action"`
+	Before     JSON        `db:"before" json:"before"`
+	After      JSON        `db:"after" json:"after"`
+	OccurredAt time.Time   `db:"occurred_at" json:"occurred_at"`
+}
+
+// JSON is an encoded JSON document stored in a jsonb column. A nil JSON is
+// SQL and JSON null.
+type JSON []byte
+
+func (j *JSON) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(JSON(nil), v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", src)
+	}
+	return nil
+}
+
+func (j JSON) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if j == nil {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
@@ -0,0 +1,27 @@
+package models
+
+type BudgetStatus string
+
+const (
+	BudgetOK       BudgetStatus = "ok"
+	BudgetWarning  BudgetStatus = "warning"
+	BudgetExceeded BudgetStatus = "exceeded"
+)
+
+type CampaignBudget struct {
+	CampaignID    int          `db:"campaign_id" json:"campaign_id"`
+	Budget        Money        `db:"budget" json:"budget"`
+	EstimatedCost Money        `db:"estimated_cost" json:"estimated_cost"`
+	ActualCost    Money        `db:"actual_cost" json:"actual_cost"`
+	Remaining     Money        `db:"-" json:"remaining"`
+	PercentBurned float64      `db:"-" json:"percent_burned"`
+	Status        BudgetStatus `db:"-" json:"status"`
+	Currency      string       `db:"currency" json:"-"`
+}
+
+func (b *CampaignBudget) ApplyCurrency() {
+	b.Budget.Currency = b.Currency
+	b.EstimatedCost.Currency = b.Currency
+	b.ActualCost.Currency = b.Currency
+	b.Remaining.Currency = b.Currency
+}
@@ -1,17 +1,22 @@
 package models
 
+import "time"
+
 type Campaign struct {
 	CampaignID       int           `db:"campaign_id" json:"campaign_id"`
-	ClientID         int           `db:"client_id" json:"client_id"`
-	Title            string        `db:"title" json:"title"`
-	StartDate        string        `db:"start_date" json:"start_date"`
-	EndDate          string        `db:"end_date" json:"end_date"`
-	EstimatedCost    float64       `db:"estimated_cost" json:"estimated_cost"`
-	ActualCost       float64       `db:"actual_cost" json:"actual_cost"`
+	ClientID         int           `db:"client_id" json:"client_id" validate:"required"`
+	Title            string        `db:"title" json:"title" validate:"notblank"`
+	StartDate        string        `db:"start_date" json:"start_date" validate:"required,date"`
+	EndDate          string        `db:"end_date" json:"end_date" validate:"required,date"`
+	EstimatedCost    Money         `db:"estimated_cost" json:"estimated_cost" validate:"gte=0"`
+	ActualCost       Money         `db:"actual_cost" json:"actual_cost"`
 	CompletionStatus bool          `db:"completion_status" json:"completion_status"`
-	CurrentState     CampaignState `db:"current_state" json:"current_state"`
+	CurrentState     CampaignState `db:"current_state" json:"current_state" validate:"omitempty,enum"`
 	ManagerID        int           `db:"manager_id" json:"manager_id"`
-	Budget           int           `db:"budget" json:"budget"`
+	Budget           Money         `db:"budget" json:"budget" validate:"gte=0"`
+	Currency         string        `db:"currency" json:"-"`
+	Version          int           `db:"version" json:"version"`
+	DeletedAt        *time.Time    `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
 type CampaignState string
@@ -22,3 +27,24 @@ const (
 	StateCompleted  CampaignState = "completed"
 	StateCancelled  CampaignState = "cancelled"
 )
+
+func (CampaignState) Options() []string {
+	return []string{string(StateNotStarted), string(StateInProgress), string(StateCompleted), string(StateCancelled)}
+}
+
+// ApplyCurrency copies the campaign's currency onto its amounts after a load.
+func (c *Campaign) ApplyCurrency() {
+	c.Budget.Currency = c.Currency
+	c.EstimatedCost.Currency = c.Currency
+	c.ActualCost.Currency = c.Currency
+}
+
+// ResolveCurrency makes sure every amount on the campaign uses one currency.
+func (c *Campaign) ResolveCurrency() error {
+	currency, err := resolveCurrency(c.Currency, &c.Budget, &c.EstimatedCost, &c.ActualCost)
+	if err != nil {
+		return err
+	}
+	c.Currency = currency
+	return nil
+}
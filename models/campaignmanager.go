@@ -1,6 +1,18 @@
 package models
 
+import "time"
+
 type CampaignManager struct {
 	ManagerID int `db:"manager_id" json:"manager_id"`
-	StaffID   int `db:"staff_id" json:"staff_id"`
+	StaffID   int `db:"staff_id" json:"staff_id" validate:"required"`
+}
+
+// CampaignManagerAssignment records a change of manager on a campaign.
+// PreviousManagerID is nil when the campaign had no manager.
+type CampaignManagerAssignment struct {
+	AssignmentID      int       `db:"assignment_id" json:"assignment_id"`
+	CampaignID        int       `db:"campaign_id" json:"campaign_id"`
+	PreviousManagerID *int      `db:"previous_manager_id" json:"previous_manager_id"`
+	ManagerID         int       `db:"manager_id" json:"manager_id"`
+	AssignedAt        time.Time `db:"assigned_at" json:"assigned_at"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type CampaignStaff struct {
+	AssignmentID int          `db:"assignment_id" json:"assignment_id"`
+	CampaignID   int          `db:"campaign_id" json:"campaign_id"`
+	StaffID      int          `db:"staff_id" json:"staff_id" validate:"required"`
+	Role         CampaignRole `db:"role" json:"role" validate:"required,enum"`
+	AssignedAt   time.Time    `db:"assigned_at" json:"assigned_at"`
+}
+
+type CampaignRole string
+
+const (
+	CampaignRoleCreative   CampaignRole = "creative"
+	CampaignRoleCopywriter CampaignRole = "copywriter"
+	CampaignRoleAccount    CampaignRole = "account"
+	CampaignRoleManager    CampaignRole = "manager"
+)
+
+func (CampaignRole) Options() []string {
+	return []string{string(CampaignRoleCreative), string(CampaignRoleCopywriter), string(CampaignRoleAccount), string(CampaignRoleManager)}
+}
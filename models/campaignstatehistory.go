@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type CampaignStateHistory struct {
+	HistoryID  int           `db:"history_id" json:"history_id"`
+	CampaignID int           `db:"campaign_id" json:"campaign_id"`
+	FromState  CampaignState `db:"from_state" json:"from_state"`
+	ToState    CampaignState `db:"to_state" json:"to_state"`
+	ChangedBy  int           `db:"changed_by" json:"changed_by"`
+	ChangedAt  time.Time     `db:"changed_at" json:"changed_at"`
+}
@@ -1,8 +1,12 @@
 package models
 
+import "time"
+
 type Client struct {
-	ClientID       int    `db:"client_id" json:"client_id"`
-	Name           string `db:"name" json:"name"`
-	Address        string `db:"address" json:"address"`
-	ContactDetails string `db:"contact_details" json:"contact_details"`
+	ClientID       int        `db:"client_id" json:"client_id"`
+	Name           string     `db:"name" json:"name" validate:"notblank"`
+	Address        string     `db:"address" json:"address"`
+	ContactDetails string     `db:"contact_details" json:"contact_details"`
+	Version        int        `db:"version" json:"version"`
+	DeletedAt      *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
 }
@@ -0,0 +1,45 @@
+package models
+
+// DeleteStrategy says what happens to a client's campaigns when the client
+// is deleted.
+type DeleteStrategy string
+
+const (
+	// DeleteRestrict refuses to delete a client that still has campaigns.
+	DeleteRestrict DeleteStrategy = "restrict"
+	// DeleteCascade permanently deletes the client, its campaigns and
+	// everything recorded against them.
+	DeleteCascade DeleteStrategy = "cascade"
+	// DeleteArchive soft-deletes the client with its campaigns and their
+	// adverts, which can then be restored until they are purged.
+	DeleteArchive DeleteStrategy = "archive"
+)
+
+func (s DeleteStrategy) Valid() bool {
+	switch s {
+	case DeleteRestrict, DeleteCascade, DeleteArchive:
+		return true
+	}
+	return false
+}
+
+// DeletionImpact counts the records that deleting a client touches. Archive
+// and restrict count only records that are not already deleted; cascade
+// counts everything it removes.
+type DeletionImpact struct {
+	Campaigns        int `db:"campaigns" json:"campaigns"`
+	Adverts          int `db:"adverts" json:"adverts"`
+	CostEntries      int `db:"cost_entries" json:"cost_entries"`
+	StaffAssignments int `db:"staff_assignments" json:"staff_assignments"`
+	Timesheets       int `db:"timesheets" json:"timesheets"`
+	PortalAccounts   int `db:"portal_accounts" json:"portal_accounts"`
+}
+
+// ClientDeletion reports a client deletion, or what it would do for a dry
+// run.
+type ClientDeletion struct {
+	ClientID int            `json:"client_id"`
+	Strategy DeleteStrategy `json:"strategy"`
+	DryRun   bool           `json:"dry_run"`
+	Impact   DeletionImpact `json:"impact"`
+}
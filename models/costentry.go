@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type CostEntry struct {
+	EntryID    int          `db:"entry_id" json:"entry_id"`
+	CampaignID int          `db:"campaign_id" json:"campaign_id"`
+	AdvertID   *int         `db:"advert_id" json:"advert_id,omitempty"`
+	Amount     Money        `db:"amount" json:"amount" validate:"gt=0"`
+	Category   CostCategory `db:"category" json:"category" validate:"required,enum"`
+	EntryDate  time.Time    `db:"entry_date" json:"entry_date"`
+	Note       string       `db:"note" json:"note"`
+	Currency   string       `db:"currency" json:"-"`
+}
+
+type CostCategory string
+
+const (
+	CostMediaBuy   CostCategory = "media buy"
+	CostStaffTime  CostCategory = "staff time"
+	CostProduction CostCategory = "production"
+	CostThirdParty CostCategory = "third party"
+)
+
+func (CostCategory) Options() []string {
+	return []string{string(CostMediaBuy), string(CostStaffTime), string(CostProduction), string(CostThirdParty)}
+}
+
+func (e *CostEntry) ApplyCurrency() {
+	e.Amount.Currency = e.Currency
+}
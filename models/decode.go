@@ -0,0 +1,184 @@
+package models
+
+import (
+	"agate-project/apperrors"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	timeType        = reflect.TypeFor[time.Time]()
+)
+
+// DecodeJSON decodes a request body into dst and validates it. Values that
+// cannot be decoded, because they have the wrong JSON type or their field's
+// UnmarshalJSON rejects them (a malformed amount, say), are reported together
+// with the fields that fail validation. A body that is not a JSON value of
+// the right shape is a bad request.
+func DecodeJSON(data []byte, dst any) error {
+	doc, err := decodeNumbers(data)
+	if err != nil {
+		return apperrors.Wrap(apperrors.KindBadRequest, err, "invalid request body")
+	}
+
+	// reddedilen değerler çıkarılır ki gövdenin kalanı yine de çözülsün
+	fields := rejectedValues(reflect.TypeOf(dst), doc, "")
+	if len(fields) > 0 {
+		if data, err = json.Marshal(doc); err != nil {
+			return err
+		}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(data, dst); err != nil {
+		if !errors.As(err, &typeErr) || typeErr.Field == "" {
+			return apperrors.Wrap(apperrors.KindBadRequest, err, "invalid request body")
+		}
+		fields = append(fields, apperrors.FieldError{Field: typeErr.Field, Message: typeMessage(typeErr.Type)})
+	}
+	if len(fields) == 0 {
+		return Validate(dst)
+	}
+
+	var invalid *apperrors.Error
+	if err := Validate(dst); errors.As(err, &invalid) && invalid.Fields != nil {
+		for _, f := range invalid.Fields {
+			if !hasField(fields, f.Field) {
+				fields = append(fields, f)
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+	return apperrors.InvalidFields(fields)
+}
+
+// rejectedValues reports the values in doc that the UnmarshalJSON of the
+// field they belong to, as typ lays doc out, rejects. Rejected object members
+// are removed from doc.
+func rejectedValues(typ reflect.Type, doc any, path string) []apperrors.FieldError {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		members, ok := doc.(map[string]any)
+		if !ok {
+			return nil
+		}
+		return rejectedMembers(typ, members, path)
+	case reflect.Slice, reflect.Array:
+		items, ok := doc.([]any)
+		if !ok {
+			return nil
+		}
+		var fields []apperrors.FieldError
+		for i, item := range items {
+			fields = append(fields, rejectedValues(typ.Elem(), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return fields
+	}
+	return nil
+}
+
+func rejectedMembers(typ reflect.Type, members map[string]any, path string) []apperrors.FieldError {
+	var fields []apperrors.FieldError
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name := jsonFieldName(field)
+		if name == embeddedField {
+			fields = append(fields, rejectedValues(field.Type, members, path)...)
+			continue
+		}
+		if name == "" || !field.IsExported() {
+			continue
+		}
+		key, ok := memberKey(members, name)
+		if !ok || members[key] == nil {
+			continue
+		}
+
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if !reflect.PointerTo(fieldType).Implements(unmarshalerType) {
+			fields = append(fields, rejectedValues(fieldType, members[key], fieldPath)...)
+			continue
+		}
+		raw, err := json.Marshal(members[key])
+		if err != nil {
+			continue
+		}
+		if err := reflect.New(fieldType).Interface().(json.Unmarshaler).UnmarshalJSON(raw); err != nil {
+			fields = append(fields, apperrors.FieldError{Field: fieldPath, Message: rejectedMessage(fieldType, err)})
+			delete(members, key)
+		}
+	}
+	return fields
+}
+
+// memberKey finds the member a field decodes from. Like encoding/json, it
+// prefers an exact match and falls back to one that differs only in case.
+func memberKey(members map[string]any, name string) (string, bool) {
+	if _, ok := members[name]; ok {
+		return name, true
+	}
+	for key := range members {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func hasField(fields []apperrors.FieldError, path string) bool {
+	for _, f := range fields {
+		if f.Field == path {
+			return true
+		}
+	}
+	return false
+}
+
+func rejectedMessage(typ reflect.Type, err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidMoney):
+		if reason := strings.TrimPrefix(err.Error(), ErrInvalidMoney.Error()+": "); reason != err.Error() {
+			return "is not a valid amount: " + reason
+		}
+		return "is not a valid amount"
+	case typ == timeType:
+		return "must be a timestamp such as 2024-01-31T09:00:00Z"
+	}
+	return "is invalid"
+}
+
+func typeMessage(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.String:
+		return "must be a string"
+	case reflect.Bool:
+		return "must be true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "must be an integer"
+	case reflect.Float32, reflect.Float64:
+		return "must be a number"
+	case reflect.Slice, reflect.Array:
+		return "must be an array"
+	case reflect.Struct, reflect.Map:
+		return "must be an object"
+	}
+	return "has the wrong type"
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"agate-project/apperrors"
+)
+
+type campaignWithAdverts struct {
+	Campaign
+	Adverts []Advert `json:"adverts" validate:"dive"`
+}
+
+func TestDecodeJSONReportsBadAmountWithOtherFields(t *testing.T) {
+	body := `{
+		"client_id": 4, "title": "  ", "start_date": "2025-03-01", "end_date": "2025-03-31",
+		"budget": {"amount": "1.234"},
+		"estimated_cost": {"amount": "1000.00"},
+		"adverts": [{"campaign_id": 1, "progress": "planned", "run_date": "next week"}]
+	}`
+	var dst campaignWithAdverts
+	err := DecodeJSON([]byte(body), &dst)
+
+	var invalid *apperrors.Error
+	if !errors.As(err, &invalid) || invalid.Kind != apperrors.KindValidation {
+		t.Fatalf("DecodeJSON = %v, want a validation error", err)
+	}
+	want := map[string]string{
+		"budget":              `is not a valid amount: "1.234" has more than two decimal places`,
+		"title":               "must not be blank",
+		"adverts[0].run_date": "must be a timestamp such as 2024-01-31T09:00:00Z",
+	}
+	got := map[string]string{}
+	for _, f := range invalid.Fields {
+		if _, seen := got[f.Field]; seen {
+			t.Errorf("%s reported twice", f.Field)
+		}
+		got[f.Field] = f.Message
+	}
+	if len(got) != len(want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+	for field, message := range want {
+		if got[field] != message {
+			t.Errorf("%s: message %q, want %q", field, got[field], message)
+		}
+	}
+	if dst.EstimatedCost.Amount != 100000 {
+		t.Errorf("estimated_cost = %d minor units, want the rest of the body decoded", dst.EstimatedCost.Amount)
+	}
+}
+
+func TestDecodeJSONReportsWrongTypeWithOtherFields(t *testing.T) {
+	body := `{"client_id": "four", "title": "Spring", "start_date": "2025-03-31", "end_date": "2025-03-01"}`
+	var dst Campaign
+	err := DecodeJSON([]byte(body), &dst)
+
+	var invalid *apperrors.Error
+	if !errors.As(err, &invalid) {
+		t.Fatalf("DecodeJSON = %v, want a validation error", err)
+	}
+	got := map[string]string{}
+	for _, f := range invalid.Fields {
+		got[f.Field] = f.Message
+	}
+	if got["client_id"] != "must be an integer" || got["end_date"] != "must not be before start_date" || len(got) != 2 {
+		t.Errorf("fields = %v, want client_id's type and end_date's order", got)
+	}
+}
+
+func TestDecodeJSONRejectsMalformedBody(t *testing.T) {
+	for _, body := range []string{`{bad`, `[]`, ``} {
+		var dst Campaign
+		if err := DecodeJSON([]byte(body), &dst); apperrors.KindOf(err) != apperrors.KindBadRequest {
+			t.Errorf("DecodeJSON(%q) = %v, want a bad request", body, err)
+		}
+	}
+}
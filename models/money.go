@@ -0,0 +1,285 @@
+package models
+
+import (
+	"agate-project/apperrors"
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const DefaultCurrency = "GBP"
+
+// ErrNegativeMoney and ErrCurrencyMismatch both wrap ErrInvalidMoney.
+var (
+	ErrInvalidMoney     = apperrors.Validation("invalid money amount")
+	ErrNegativeMoney    = fmt.Errorf("%w: amount cannot be negative", ErrInvalidMoney)
+	ErrCurrencyMismatch = fmt.Errorf("%w: amounts have different currencies", ErrInvalidMoney)
+)
+
+// Money is an exact amount in minor units (pence, cents) of an ISO 4217
+// currency. Every currency is treated as having two decimal places.
+type Money struct {
+	Amount   int64  `json:"-"`
+	Currency string `json:"-"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// moneyPattern is the only form ParseMoney accepts: an optional minus sign,
+// digits, and optionally a point followed by more digits.
+var moneyPattern = regexp.MustCompile(`^(-?)([0-9]+)(?:\.([0-9]+))?$`)
+
+// ParseMoney reads a decimal string such as "1234.56" into minor units.
+func ParseMoney(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, fmt.Errorf("%w: empty amount", ErrInvalidMoney)
+	}
+
+	parts := moneyPattern.FindStringSubmatch(s)
+	if parts == nil {
+		return Money{}, fmt.Errorf("%w: %q is not a decimal amount", ErrInvalidMoney, s)
+	}
+	negative, whole, frac := parts[1] == "-", parts[2], parts[3]
+	if len(frac) > 2 {
+		// NUMERIC columns may come back with trailing zeros
+		if strings.Trim(frac[2:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more than two decimal places", ErrInvalidMoney, s)
+		}
+		frac = frac[:2]
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > (math.MaxInt64-99)/100 {
+		return Money{}, fmt.Errorf("%w: %q is too large", ErrInvalidMoney, s)
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	amount := units*100 + cents
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Validate rejects negative amounts and malformed currency codes.
+func (m Money) Validate() error {
+	if m.Amount < 0 {
+		return fmt.Errorf("%w: %s", ErrNegativeMoney, m)
+	}
+	if len(m.Currency) != 3 || strings.ToUpper(m.Currency) != m.Currency {
+		return fmt.Errorf("%w: currency %q is not an ISO 4217 code", ErrInvalidMoney, m.Currency)
+	}
+	return nil
+}
+
+func (m Money) sameCurrency(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return nil
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}, nil
+}
+
+// Cmp returns -1, 0 or 1 depending on whether m is less than, equal to or
+// greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Multiply scales m by factor, rounding half away from zero to the nearest
+// minor unit. The factor is taken as the shortest decimal that reads back as
+// it, so 7.5 hours means exactly 7.5.
+func (m Money) Multiply(factor float64) (Money, error) {
+	exact, ok := new(big.Rat).SetString(strconv.FormatFloat(factor, 'f', -1, 64))
+	if !ok {
+		return Money{}, fmt.Errorf("%w: cannot multiply by %v", ErrInvalidMoney, factor)
+	}
+	return m.scale(exact)
+}
+
+// Percentage returns percent percent of m, rounded like Multiply. 33.3
+// percent is exactly 0.333 of m.
+func (m Money) Percentage(percent float64) (Money, error) {
+	exact, ok := new(big.Rat).SetString(strconv.FormatFloat(percent, 'f', -1, 64))
+	if !ok {
+		return Money{}, fmt.Errorf("%w: cannot take %v percent", ErrInvalidMoney, percent)
+	}
+	return m.scale(exact.Quo(exact, big.NewRat(100, 1)))
+}
+
+func (m Money) scale(factor *big.Rat) (Money, error) {
+	product := new(big.Rat).Mul(factor, new(big.Rat).SetInt64(m.Amount))
+
+	// yarım birimler sıfırdan uzağa yuvarlanır
+	doubled := new(big.Int).Mul(product.Num(), big.NewInt(2))
+	if doubled.Sign() < 0 {
+		doubled.Sub(doubled, product.Denom())
+	} else {
+		doubled.Add(doubled, product.Denom())
+	}
+	amount := doubled.Quo(doubled, new(big.Int).Mul(product.Denom(), big.NewInt(2)))
+	if !amount.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s times %s is too large", ErrInvalidMoney, m, factor.RatString())
+	}
+	return Money{Amount: amount.Int64(), Currency: m.Currency}, nil
+}
+
+// Percent returns m as a percentage of other.
+func (m Money) Percent(other Money) float64 {
+	if other.Amount == 0 {
+		return 0
+	}
+	return float64(m.Amount) / float64(other.Amount) * 100
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string so no precision is lost.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.Currency})
+}
+
+// UnmarshalJSON accepts the amount either as a string or as a bare JSON
+// number; both are parsed exactly from their text.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMoney, err)
+	}
+
+	text := string(bytes.TrimSpace(raw.Amount))
+	if text == "" || text == "null" {
+		return fmt.Errorf("%w: amount is required", ErrInvalidMoney)
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(raw.Amount, &text); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidMoney, err)
+		}
+	}
+
+	// boş currency, sahibi olan modelin para birimini alır
+	currency := strings.ToUpper(strings.TrimSpace(raw.Currency))
+	parsed, err := ParseMoney(text, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount in a NUMERIC column. The currency lives in its own
+// column on the owning row.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a NUMERIC column. The currency is filled in by the owning model.
+func (m *Money) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		text = "0"
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case int64:
+		text = strconv.FormatInt(v, 10)
+	case float64:
+		// the shortest form, so that values between cents are rejected
+		// rather than rounded
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidMoney, src)
+	}
+
+	parsed, err := ParseMoney(text, m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// resolveCurrency checks that every amount shares one currency and returns it.
+// Amounts without a currency take the shared one.
+func resolveCurrency(fallback string, amounts ...*Money) (string, error) {
+	currency := ""
+	for _, m := range amounts {
+		if m.Currency == "" {
+			continue
+		}
+		if currency != "" && m.Currency != currency {
+			return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, currency, m.Currency)
+		}
+		currency = m.Currency
+	}
+	if currency == "" {
+		currency = fallback
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	for _, m := range amounts {
+		m.Currency = currency
+	}
+	return currency, nil
+}
@@ -0,0 +1,36 @@
+package models
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// ListOptions describes which page of a list to return. Filters are keyed by
+// the public filter name (e.g. "client_id", "start_date_from"); each
+// repository decides which names it understands.
+type ListOptions struct {
+	Limit   int
+	Offset  int
+	Sort    string
+	Desc    bool
+	Filters map[string]string
+	// IncludeDeleted also lists soft-deleted rows, on lists that have them.
+	IncludeDeleted bool
+}
+
+// Page is the envelope returned by list endpoints. Next is the link to the
+// following page and is empty on the last one.
+type Page[T any] struct {
+	Items  []T    `json:"items"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+}
+
+func NewPage[T any](items []T, total int, opts ListOptions) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return Page[T]{Items: items, Total: total, Limit: opts.Limit, Offset: opts.Offset}
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"agate-project/apperrors"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// MergePatch is an RFC 7396 JSON merge patch of one record: its members
+// replace the record's fields, null removes a field, and nested objects are
+// merged the same way.
+type MergePatch map[string]json.RawMessage
+
+// Fields returns the names of the top-level fields the patch touches.
+func (p MergePatch) Fields() []string {
+	fields := make([]string, 0, len(p))
+	for field := range p {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Apply merges the patch into the record target points to. Fields named in
+// readOnly may appear in the patch only with their current value. Fields the
+// record does not have are rejected, and removed fields are left at their
+// zero value, as are fields that are not part of the record's JSON. The
+// patched record must pass validation.
+func (p MergePatch) Apply(target any, readOnly ...string) error {
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	doc, err := decodeNumbers(current)
+	if err != nil {
+		return err
+	}
+	fields, ok := doc.(map[string]any)
+	if !ok {
+		return errors.New("merge patch target is not a JSON object")
+	}
+
+	patch := make(map[string]any, len(p))
+	for _, field := range p.Fields() {
+		value, err := decodeNumbers(p[field])
+		if err != nil {
+			return apperrors.BadRequest("invalid merge patch: field %q is not valid JSON", field)
+		}
+		patch[field] = value
+	}
+
+	for _, field := range readOnly {
+		if value, ok := patch[field]; ok && !reflect.DeepEqual(value, fields[field]) {
+			return apperrors.Validation("%s cannot be changed", field)
+		}
+		delete(patch, field)
+	}
+	for field := range patch {
+		if _, ok := fields[field]; !ok {
+			return apperrors.BadRequest("invalid merge patch: unknown field %q", field)
+		}
+	}
+
+	merged, err := json.Marshal(mergeValue(fields, patch))
+	if err != nil {
+		return err
+	}
+	record := reflect.ValueOf(target).Elem()
+	record.Set(reflect.Zero(record.Type()))
+	if err := json.Unmarshal(merged, target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return apperrors.BadRequest("invalid merge patch: %s has the wrong type", typeErr.Field)
+		}
+		if apperrors.KindOf(err) != apperrors.KindInternal {
+			return err
+		}
+		return apperrors.BadRequest("invalid merge patch")
+	}
+	return Validate(target)
+}
+
+// mergeValue applies patch to target as RFC 7396 describes.
+func mergeValue(target, patch any) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]any)
+	if !ok {
+		merged = map[string]any{}
+	}
+	for field, value := range members {
+		if value == nil {
+			delete(merged, field)
+			continue
+		}
+		merged[field] = mergeValue(merged[field], value)
+	}
+	return merged
+}
+
+// decodeNumbers decodes JSON keeping numbers as written, so that amounts and
+// IDs survive the merge exactly.
+func decodeNumbers(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
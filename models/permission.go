@@ -0,0 +1,61 @@
+package models
+
+import "strings"
+
+type Role string
+
+const (
+	RoleDirector        Role = "director"
+	RoleAccountManager  Role = "account manager"
+	RoleCampaignManager Role = "campaign manager"
+	RoleCreative        Role = "creative"
+	RoleFinance         Role = "finance"
+	RoleClientViewer    Role = "client viewer"
+)
+
+// ParseRole maps the free-form Staff.Role onto a known role. "Account_Manager"
+// and "account manager" are the same role; anything unrecognised is "".
+func ParseRole(role string) Role {
+	normalized := Role(strings.ToLower(strings.Join(strings.FieldsFunc(role, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	}), " ")))
+	switch normalized {
+	case RoleDirector, RoleAccountManager, RoleCampaignManager, RoleCreative, RoleFinance, RoleClientViewer:
+		return normalized
+	}
+	return ""
+}
+
+type Permission string
+
+const (
+	PermReadClients   Permission = "clients:read"
+	PermManageClients Permission = "clients:write"
+
+	PermReadStaff   Permission = "staff:read"
+	PermManageStaff Permission = "staff:write"
+
+	PermReadGrades   Permission = "grades:read"
+	PermManageGrades Permission = "grades:write"
+
+	PermReadCampaigns   Permission = "campaigns:read"
+	PermManageCampaigns Permission = "campaigns:write"
+	PermDeleteCampaigns Permission = "campaigns:delete"
+	// PermTransitionCampaigns lets a campaign's own manager move it between
+	// states; PermTransitionAnyCampaign lets the holder move any campaign.
+	PermTransitionCampaigns   Permission = "campaigns:transition"
+	PermTransitionAnyCampaign Permission = "campaigns:transition-any"
+
+	PermReadCosts Permission = "costs:read"
+	PermPostCosts Permission = "costs:write"
+
+	PermLogTime Permission = "timesheets:write"
+
+	PermManageManagers Permission = "managers:write"
+
+	PermReadAdverts          Permission = "adverts:read"
+	PermManageAdverts        Permission = "adverts:write"
+	PermUpdateAdvertProgress Permission = "adverts:progress"
+
+	PermReadAudit Permission = "audit:read"
+)
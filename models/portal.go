@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// ClientAccount is a login for a client's own staff. It only grants access to
+// the client portal, and only to that client's records.
+type ClientAccount struct {
+	ClientUserID int       `db:"client_user_id" json:"client_user_id"`
+	ClientID     int       `db:"client_id" json:"client_id"`
+	Username     string    `db:"username" json:"username"`
+	PasswordHash string    `db:"password_hash" json:"-"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+type ClientSession struct {
+	SessionID    string     `db:"session_id" json:"session_id"`
+	ClientUserID int        `db:"client_user_id" json:"client_user_id"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt    time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt    *time.Time `db:"revoked_at" json:"revoked_at"`
+}
+
+// ClientPrincipal is the authenticated caller of a portal request.
+type ClientPrincipal struct {
+	ClientUserID int
+	ClientID     int
+	SessionID    string
+}
+
+// PortalCampaign is the client's view of a campaign: its schedule, state and
+// approved budget, without internal costs or staffing.
+type PortalCampaign struct {
+	CampaignID       int           `json:"campaign_id"`
+	Title            string        `json:"title"`
+	StartDate        string        `json:"start_date"`
+	EndDate          string        `json:"end_date"`
+	CurrentState     CampaignState `json:"current_state"`
+	CompletionStatus bool          `json:"completion_status"`
+	Budget           Money         `json:"budget"`
+}
+
+func NewPortalCampaign(c Campaign) PortalCampaign {
+	return PortalCampaign{
+		CampaignID:       c.CampaignID,
+		Title:            c.Title,
+		StartDate:        c.StartDate,
+		EndDate:          c.EndDate,
+		CurrentState:     c.CurrentState,
+		CompletionStatus: c.CompletionStatus,
+		Budget:           c.Budget,
+	}
+}
+
+type PortalAdvert struct {
+	AdvertID   int            `json:"advert_id"`
+	CampaignID int            `json:"campaign_id"`
+	Progress   AdvertProgress `json:"progress"`
+	RunDate    time.Time      `json:"run_date"`
+}
+
+func NewPortalAdvert(a Advert) PortalAdvert {
+	return PortalAdvert{
+		AdvertID:   a.AdvertID,
+		CampaignID: a.CampaignID,
+		Progress:   a.Progress,
+		RunDate:    a.RunDate,
+	}
+}
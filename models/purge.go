@@ -0,0 +1,8 @@
+package models
+
+// PurgeResult counts the records a retention purge removed for good.
+type PurgeResult struct {
+	Clients   int `json:"clients"`
+	Campaigns int `json:"campaigns"`
+	Adverts   int `json:"adverts"`
+}
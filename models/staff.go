@@ -2,10 +2,32 @@ package models
 
 import "time"
 
+// Staff is a staff member. GradeID is the grade currently held and
+// StartingGradeID the grade they joined on; both are derived from the grade
+// history, so changes go through promotions rather than updates.
 type Staff struct {
-	StaffID   int       `db:"staff_id" json:"staff_id"`
-	Name      string    `db:"name" json:"name"`
-	Role      string    `db:"role" json:"role"`
-	GradeID   int       `db:"grade_id" json:"grade_id"`
-	StartDate time.Time `db:"start_date" json:"start_date"`
+	StaffID         int       `db:"staff_id" json:"staff_id"`
+	Name            string    `db:"name" json:"name" validate:"notblank"`
+	Role            string    `db:"role" json:"role"`
+	GradeID         int       `db:"grade_id" json:"grade_id"`
+	StartingGradeID int       `db:"starting_grade" json:"starting_grade"`
+	StartDate       time.Time `db:"start_date" json:"start_date"`
+	Active          bool      `db:"active" json:"active"`
+}
+
+const (
+	GradeReasonStarting  = "starting"
+	GradeReasonPromotion = "promotion"
+)
+
+// StaffGradeChange is one entry in a staff member's grade history. The grade
+// applies from EffectiveDate until the next entry.
+type StaffGradeChange struct {
+	HistoryID     int       `db:"history_id" json:"history_id"`
+	StaffID       int       `db:"staff_id" json:"staff_id"`
+	GradeID       int       `db:"grade_id" json:"grade_id"`
+	GradeName     string    `db:"grade_name" json:"grade_name"`
+	EffectiveDate time.Time `db:"effective_date" json:"effective_date"`
+	Reason        string    `db:"reason" json:"reason"`
+	RecordedAt    time.Time `db:"recorded_at" json:"recorded_at"`
 }
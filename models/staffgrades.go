@@ -2,6 +2,22 @@ package models
 
 type StaffGrade struct {
 	GradeID   int    `db:"grade_id" json:"grade_id"`
-	GradeName string `db:"grade_name" json:"grade_name"`
-	PayRate   int    `db:"pay_rate" json:"pay_rate"`
+	GradeName string `db:"grade_name" json:"grade_name" validate:"notblank"`
+	PayRate   Money  `db:"pay_rate" json:"pay_rate" validate:"gt=0"`
+	Currency  string `db:"currency" json:"-"`
+	Version   int    `db:"version" json:"version"`
+}
+
+// ApplyCurrency copies the grade's currency onto its pay rate after a load.
+func (g *StaffGrade) ApplyCurrency() {
+	g.PayRate.Currency = g.Currency
+}
+
+func (g *StaffGrade) ResolveCurrency() error {
+	currency, err := resolveCurrency(g.Currency, &g.PayRate)
+	if err != nil {
+		return err
+	}
+	g.Currency = currency
+	return nil
 }
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Timesheet is a day's hours on one campaign. Hours are stored to two decimal
+// places, so they may not carry more; the cost is worked out from them as
+// given.
+type Timesheet struct {
+	TimesheetID int       `db:"timesheet_id" json:"timesheet_id"`
+	StaffID     int       `db:"staff_id" json:"staff_id"`
+	CampaignID  int       `db:"campaign_id" json:"campaign_id" validate:"required"`
+	WorkDate    time.Time `db:"work_date" json:"work_date" validate:"required"`
+	Hours       float64   `db:"hours" json:"hours" validate:"gt=0,lte=24,decimals=2"`
+	GradeID     int       `db:"grade_id" json:"grade_id"`
+	HourlyRate  Money     `db:"hourly_rate" json:"hourly_rate"`
+	Cost        Money     `db:"cost" json:"cost"`
+	Note        string    `db:"note" json:"note"`
+	Currency    string    `db:"currency" json:"-"`
+}
+
+func (t *Timesheet) ApplyCurrency() {
+	t.HourlyRate.Currency = t.Currency
+	t.Cost.Currency = t.Currency
+}
+
+// LabourCostLine is the hours and cost one staff member logged on a
+// campaign at one grade.
+type LabourCostLine struct {
+	StaffID   int     `db:"staff_id" json:"staff_id"`
+	StaffName string  `db:"staff_name" json:"staff_name"`
+	GradeID   int     `db:"grade_id" json:"grade_id"`
+	GradeName string  `db:"grade_name" json:"grade_name"`
+	Hours     float64 `db:"hours" json:"hours"`
+	Cost      Money   `db:"cost" json:"cost"`
+	Currency  string  `db:"currency" json:"-"`
+}
+
+type GradeLabourCost struct {
+	GradeID   int     `json:"grade_id"`
+	GradeName string  `json:"grade_name"`
+	Hours     float64 `json:"hours"`
+	Cost      Money   `json:"cost"`
+}
+
+type LabourCost struct {
+	CampaignID int               `json:"campaign_id"`
+	TotalHours float64           `json:"total_hours"`
+	TotalCost  Money             `json:"total_cost"`
+	ByStaff    []LabourCostLine  `json:"by_staff"`
+	ByGrade    []GradeLabourCost `json:"by_grade"`
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"agate-project/apperrors"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Request bodies are checked against the `validate` tags of their fields.
+// Besides the validator's own rules (required, gt, lte, ...) these tags are
+// available:
+//
+//	notblank  a string with something other than whitespace
+//	date      a date such as 2024-01-31
+//	enum      a value listed by the field type's Options method
+//	decimals  a number with at most that many decimal places, e.g. decimals=2
+//
+// Money fields are compared by amount, so gt=0 means a positive amount.
+// Rules across fields are registered per type in newValidator.
+
+// enum is a string type that only takes the values Options lists.
+type enum interface {
+	Options() []string
+}
+
+const dateLayout = "2006-01-02"
+
+// embeddedField names embedded structs in validation paths, which leave them
+// out just like their JSON does.
+const embeddedField = "*"
+
+var validation = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(jsonFieldName)
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(Money).Amount
+	}, Money{})
+
+	v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	v.RegisterValidation("date", func(fl validator.FieldLevel) bool {
+		_, err := parseDate(fl.Field().String())
+		return err == nil
+	})
+	v.RegisterValidation("decimals", func(fl validator.FieldLevel) bool {
+		places, err := strconv.Atoi(fl.Param())
+		if err != nil {
+			panic(fmt.Sprintf("decimals: bad parameter %q", fl.Param()))
+		}
+		_, fraction, _ := strings.Cut(strconv.FormatFloat(fl.Field().Float(), 'f', -1, 64), ".")
+		return len(fraction) <= places
+	})
+	v.RegisterValidation("enum", func(fl validator.FieldLevel) bool {
+		e, ok := fl.Field().Interface().(enum)
+		return ok && slices.Contains(e.Options(), fl.Field().String())
+	})
+
+	v.RegisterStructValidation(validateCampaignDates, Campaign{})
+	return v
+}
+
+// validateCampaignDates rejects campaigns that end before they start. Dates
+// that do not parse are already reported by their own tags.
+func validateCampaignDates(sl validator.StructLevel) {
+	campaign := sl.Current().Interface().(Campaign)
+	start, err := parseDate(campaign.StartDate)
+	if err != nil {
+		return
+	}
+	end, err := parseDate(campaign.EndDate)
+	if err != nil {
+		return
+	}
+	if end.Before(start) {
+		sl.ReportError(campaign.EndDate, "end_date", "EndDate", "notbefore", "start_date")
+	}
+}
+
+// parseDate reads a date as clients send it, or as a timestamp, which is how
+// stored dates come back from the database. Only the date part of a
+// timestamp counts.
+func parseDate(s string) (time.Time, error) {
+	if _, err := time.Parse(time.RFC3339, s); err == nil {
+		s = s[:len(dateLayout)]
+	}
+	return time.Parse(dateLayout, s)
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" && field.Anonymous {
+		return embeddedField
+	}
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// Validate checks a request body against its validation tags and reports
+// every invalid field at once.
+func Validate(v any) error {
+	err := validation.Struct(v)
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	fields := make([]apperrors.FieldError, len(invalid))
+	for i, fe := range invalid {
+		fields[i] = apperrors.FieldError{Field: fieldPath(fe), Message: fieldMessage(fe)}
+	}
+	return apperrors.InvalidFields(fields)
+}
+
+// fieldPath turns a namespace such as "createCampaignRequest.*.adverts[0].progress"
+// into the field's path in the JSON body, "adverts[0].progress".
+func fieldPath(fe validator.FieldError) string {
+	segments := strings.Split(fe.Namespace(), ".")[1:]
+	path := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment != embeddedField {
+			path = append(path, segment)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "date":
+		return "must be a date such as 2024-01-31"
+	case "enum":
+		if e, ok := fe.Value().(enum); ok {
+			return "must be one of: " + strings.Join(e.Options(), ", ")
+		}
+	case "notbefore":
+		return "must not be before " + fe.Param()
+	case "decimals":
+		return "must have at most " + fe.Param() + " decimal places"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	}
+	return "is invalid"
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agate-project/apperrors"
+)
+
+func TestValidateLimitsTimesheetHoursToStoredPrecision(t *testing.T) {
+	valid := Timesheet{CampaignID: 1, WorkDate: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)}
+	for _, hours := range []float64{7, 7.5, 7.33, 0.01, 24} {
+		timesheet := valid
+		timesheet.Hours = hours
+		if err := Validate(&timesheet); err != nil {
+			t.Errorf("%v hours: %v, want nil", hours, err)
+		}
+	}
+
+	for _, hours := range []float64{7.333, 0.005, 23.999} {
+		timesheet := valid
+		timesheet.Hours = hours
+		var invalid *apperrors.Error
+		err := Validate(&timesheet)
+		if !errors.As(err, &invalid) || len(invalid.Fields) != 1 || invalid.Fields[0] != (apperrors.FieldError{Field: "hours", Message: "must have at most 2 decimal places"}) {
+			t.Errorf("%v hours: %v, want hours rejected for its decimal places", hours, err)
+		}
+	}
+}
+
+func TestTimesheetCostMatchesStoredHours(t *testing.T) {
+	// 7.33 hours at 45.55 is 333.8815, which rounds to 333.88.
+	cost, err := NewMoney(4555, "GBP").Multiply(7.33)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost.Amount != 33388 {
+		t.Errorf("cost = %s, want 333.88", cost)
+	}
+}
@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type AccountRepository interface {
+	GetAccountByUsername(ctx context.Context, username string) (models.StaffAccount, error)
+	GetAccountByStaffID(ctx context.Context, staffID int) (models.StaffAccount, error)
+	SaveAccount(ctx context.Context, account *models.StaffAccount) error
+	CreateSession(ctx context.Context, session *models.AuthSession) error
+	GetSession(ctx context.Context, sessionID string) (models.AuthSession, error)
+	RotateSession(ctx context.Context, oldSessionID string, session *models.AuthSession) error
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeStaffSessions(ctx context.Context, staffID int) error
+}
+
+type accountRepository struct {
+	db *sqlx.DB
+}
+
+func NewAccountRepository(db *sqlx.DB) AccountRepository {
+	return &accountRepository{
+		db: db,
+	}
+}
+
+const accountSelect = `SELECT a.staff_id, a.username, a.password_hash, a.created_at, s.role, s.active
+			  FROM staff_accounts a
+			  JOIN staff s ON s.staff_id = a.staff_id`
+
+func (r *accountRepository) GetAccountByUsername(ctx context.Context, username string) (models.StaffAccount, error) {
+	var account models.StaffAccount
+	query := accountSelect + ` WHERE a.username = $1`
+	if err := conn(ctx, r.db).GetContext(ctx, &account, query, username); err != nil {
+		log.Printf("GetAccountByUsername: Failed to get account %q: %v", username, err)
+		return account, fmt.Errorf("failed to get account: %w", apperrors.FromDB(err, "account"))
+	}
+	return account, nil
+}
+
+func (r *accountRepository) GetAccountByStaffID(ctx context.Context, staffID int) (models.StaffAccount, error) {
+	var account models.StaffAccount
+	query := accountSelect + ` WHERE a.staff_id = $1`
+	if err := conn(ctx, r.db).GetContext(ctx, &account, query, staffID); err != nil {
+		log.Printf("GetAccountByStaffID: Failed to get account for staff ID %d: %v", staffID, err)
+		return account, fmt.Errorf("failed to get account for staff id %d: %w", staffID, apperrors.FromDB(err, "account"))
+	}
+	return account, nil
+}
+
+// SaveAccount creates the staff member's account or replaces its username and
+// password.
+func (r *accountRepository) SaveAccount(ctx context.Context, account *models.StaffAccount) error {
+	query := `INSERT INTO staff_accounts (staff_id, username, password_hash)
+			  VALUES ($1, $2, $3)
+			  ON CONFLICT (staff_id) DO UPDATE
+			  SET username = EXCLUDED.username, password_hash = EXCLUDED.password_hash
+			  RETURNING created_at`
+	err := conn(ctx, r.db).GetContext(ctx, &account.CreatedAt, query, account.StaffID, account.Username, account.PasswordHash)
+	if err != nil {
+		log.Printf("SaveAccount: Failed to save account for staff ID %d: %v", account.StaffID, err)
+		return fmt.Errorf("failed to save account: %w", apperrors.FromDB(err, "account"))
+	}
+	return nil
+}
+
+func (r *accountRepository) CreateSession(ctx context.Context, session *models.AuthSession) error {
+	query := `INSERT INTO auth_sessions (session_id, staff_id, expires_at)
+			  VALUES ($1, $2, $3) RETURNING created_at`
+	err := conn(ctx, r.db).GetContext(ctx, &session.CreatedAt, query, session.SessionID, session.StaffID, session.ExpiresAt)
+	if err != nil {
+		log.Printf("CreateSession: Failed to create session for staff ID %d: %v", session.StaffID, err)
+		return fmt.Errorf("failed to create session: %w", apperrors.FromDB(err, "session"))
+	}
+	return nil
+}
+
+func (r *accountRepository) GetSession(ctx context.Context, sessionID string) (models.AuthSession, error) {
+	var session models.AuthSession
+	query := `SELECT session_id, staff_id, created_at, expires_at, revoked_at
+			  FROM auth_sessions
+			  WHERE session_id = $1`
+	if err := conn(ctx, r.db).GetContext(ctx, &session, query, sessionID); err != nil {
+		log.Printf("GetSession: Failed to get session: %v", err)
+		return session, fmt.Errorf("failed to get session: %w", apperrors.FromDB(err, "session"))
+	}
+	return session, nil
+}
+
+// RotateSession revokes the old session and creates its replacement in one
+// transaction. It fails with Unauthorized if the old session was already
+// revoked or has expired, so a refresh token can only be used once.
+func (r *accountRepository) RotateSession(ctx context.Context, oldSessionID string, session *models.AuthSession) error {
+	err := inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		revoke := `UPDATE auth_sessions SET revoked_at = now()
+				   WHERE session_id = $1 AND revoked_at IS NULL AND expires_at > now()`
+		result, err := tx.ExecContext(ctx, revoke, oldSessionID)
+		if err != nil {
+			log.Printf("RotateSession: Failed to revoke session: %v", err)
+			return fmt.Errorf("failed to revoke session: %w", apperrors.FromDB(err, "session"))
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to revoke session: %w", apperrors.FromDB(err, "session"))
+		}
+		if rows == 0 {
+			return apperrors.Unauthorized("refresh token is no longer valid")
+		}
+
+		insert := `INSERT INTO auth_sessions (session_id, staff_id, expires_at)
+				   VALUES ($1, $2, $3) RETURNING created_at`
+		if err := tx.GetContext(ctx, &session.CreatedAt, insert, session.SessionID, session.StaffID, session.ExpiresAt); err != nil {
+			log.Printf("RotateSession: Failed to create session for staff ID %d: %v", session.StaffID, err)
+			return fmt.Errorf("failed to create session: %w", apperrors.FromDB(err, "session"))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *accountRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	query := `UPDATE auth_sessions SET revoked_at = now()
+			  WHERE session_id = $1 AND revoked_at IS NULL`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, sessionID); err != nil {
+		log.Printf("RevokeSession: Failed to revoke session: %v", err)
+		return fmt.Errorf("failed to revoke session: %w", apperrors.FromDB(err, "session"))
+	}
+	return nil
+}
+
+// RevokeStaffSessions revokes every open session of a staff member.
+func (r *accountRepository) RevokeStaffSessions(ctx context.Context, staffID int) error {
+	query := `UPDATE auth_sessions SET revoked_at = now()
+			  WHERE staff_id = $1 AND revoked_at IS NULL`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, staffID); err != nil {
+		log.Printf("RevokeStaffSessions: Failed to revoke sessions: %v", err)
+		return fmt.Errorf("failed to revoke sessions: %w", apperrors.FromDB(err, "session"))
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"context"
 	"fmt"
@@ -11,118 +12,234 @@ import (
 )
 
 type AdvertRepository interface {
-	GetAllAdverts() ([]models.Advert, error)
-	GetAdvertById(advertID int) (models.Advert, error)
-	AddAdvert(advert *models.Advert) error
-	DeleteAdvert(advertID int) error
-	UpdateAdvert(advertID int, campaign_id int, progress *string, runDate *time.Time) error
-	GetAdvertsByCampaign(campaignID int) ([]models.Advert, error)
+	GetAllAdverts(ctx context.Context, opts models.ListOptions) ([]models.Advert, int, error)
+	GetAdvertById(ctx context.Context, advertID int) (models.Advert, error)
+	GetAdvertIncludingDeleted(ctx context.Context, advertID int) (models.Advert, error)
+	AddAdvert(ctx context.Context, advert *models.Advert) error
+	DeleteAdvert(ctx context.Context, advertID int) error
+	DeleteAdvertsByCampaign(ctx context.Context, campaignID int) error
+	RestoreAdvert(ctx context.Context, advertID int) (int, error)
+	RestoreAdvertsDeletedWith(ctx context.Context, campaignID int, deletedAt time.Time) ([]models.Advert, error)
+	PurgeAdvert(ctx context.Context, advertID int) error
+	PurgeAdvertsByCampaign(ctx context.Context, campaignID int) error
+	GetDeletedAdverts(ctx context.Context, before time.Time) ([]models.Advert, error)
+	UpdateAdvert(ctx context.Context, version int, before, after models.Advert) (int, error)
+	GetAdvertsByCampaign(ctx context.Context, campaignID int) ([]models.Advert, error)
+	GetAdvertsByClient(ctx context.Context, clientID int) ([]models.Advert, error)
 }
 
 type advertRepository struct {
-	ctx context.Context
-	db  *sqlx.DB
+	db *sqlx.DB
 }
 
-func NewAdvertRepository(ctx context.Context, db *sqlx.DB) AdvertRepository {
+func NewAdvertRepository(db *sqlx.DB) AdvertRepository {
 	return &advertRepository{
-		db:  db,
-		ctx: ctx,
+		db: db,
 	}
 }
 
-func (s *advertRepository) GetAllAdverts() ([]models.Advert, error) {
-	log.Println("GetAllAdverts: Fetching all adverts.")
-	query := `SELECT advert_id, campaign_id, progress, run_date FROM adverts`
-	var adverts []models.Advert
-	err := s.db.SelectContext(s.ctx, &adverts, query)
+const advertColumns = "advert_id, campaign_id, progress, run_date, version, deleted_at"
+
+var advertListSpec = listSpec{
+	table:       "adverts",
+	columns:     advertColumns,
+	idColumn:    "advert_id",
+	defaultSort: "advert_id",
+	sorts: map[string]string{
+		"advert_id":   "advert_id",
+		"campaign_id": "campaign_id",
+		"progress":    "progress",
+		"run_date":    "run_date",
+	},
+	filters: map[string]listFilter{
+		"campaign_id":   {column: "campaign_id", op: "=", kind: filterInt},
+		"progress":      {column: "progress", op: "=", kind: filterString},
+		"run_date_from": {column: "run_date", op: ">=", kind: filterDate},
+		"run_date_to":   {column: "run_date", op: "<=", kind: filterDate},
+	},
+	softDelete: true,
+}
+
+func (s *advertRepository) GetAllAdverts(ctx context.Context, opts models.ListOptions) ([]models.Advert, int, error) {
+	log.Println("GetAllAdverts: Fetching adverts.")
+	adverts, total, err := selectPage[models.Advert](ctx, conn(ctx, s.db), advertListSpec, opts)
 	if err != nil {
 		log.Printf("GetAllAdverts: Failed to fetch adverts: %v", err)
-		return nil, fmt.Errorf("failed to get all adverts: %w", err)
+		return nil, 0, fmt.Errorf("failed to get all adverts: %w", apperrors.FromDB(err, "advert"))
 	}
-	return adverts, nil
+	return adverts, total, nil
 }
 
-func (s *advertRepository) GetAdvertById(advertID int) (models.Advert, error) {
+// GetAdvertById returns an advert that has not been deleted.
+func (s *advertRepository) GetAdvertById(ctx context.Context, advertID int) (models.Advert, error) {
 	log.Printf("GetAdvertById: Fetching advert with ID %d.", advertID)
-	query := `SELECT advert_id, campaign_id, progress, run_date
-			  FROM adverts
-			  WHERE advert_id = $1`
+	query := "SELECT " + advertColumns + " FROM adverts WHERE advert_id = $1 AND deleted_at IS NULL"
 	var advert models.Advert
-	err := s.db.GetContext(s.ctx, &advert, query, advertID)
+	err := conn(ctx, s.db).GetContext(ctx, &advert, query, advertID)
 	if err != nil {
 		log.Printf("GetAdvertById: Failed to fetch advert with ID %d: %v", advertID, err)
-		return advert, fmt.Errorf("failed to get advert with id %d: %w", advertID, err)
+		return advert, fmt.Errorf("failed to get advert with id %d: %w", advertID, apperrors.FromDB(err, "advert"))
+	}
+	return advert, nil
+}
+
+func (s *advertRepository) GetAdvertIncludingDeleted(ctx context.Context, advertID int) (models.Advert, error) {
+	query := "SELECT " + advertColumns + " FROM adverts WHERE advert_id = $1"
+	var advert models.Advert
+	err := conn(ctx, s.db).GetContext(ctx, &advert, query, advertID)
+	if err != nil {
+		log.Printf("GetAdvertIncludingDeleted: Failed to fetch advert with ID %d: %v", advertID, err)
+		return advert, fmt.Errorf("failed to get advert with id %d: %w", advertID, apperrors.FromDB(err, "advert"))
 	}
 	return advert, nil
 }
 
-func (s *advertRepository) AddAdvert(advert *models.Advert) error {
+func (s *advertRepository) AddAdvert(ctx context.Context, advert *models.Advert) error {
 	log.Printf("AddAdvert: Adding a new advert for campaign ID %d.", advert.CampaignID)
 	query := `INSERT INTO adverts (campaign_id, progress, run_date) 
-	VALUES ($1, $2, $3) RETURNING advert_id`
-	err := s.db.GetContext(s.ctx, &advert.AdvertID, query, advert.CampaignID, advert.Progress, advert.RunDate)
+	VALUES ($1, $2, $3) RETURNING advert_id, version`
+	err := conn(ctx, s.db).QueryRowxContext(ctx, query, advert.CampaignID, advert.Progress, advert.RunDate).Scan(&advert.AdvertID, &advert.Version)
 	if err != nil {
 		log.Printf("AddAdvert: Failed to add advert: %v", err)
-		return fmt.Errorf("failed to add advert: %w", err)
+		return fmt.Errorf("failed to add advert: %w", apperrors.FromDB(err, "advert"))
 	}
 	return nil
 }
 
-func (s *advertRepository) DeleteAdvert(advertID int) error {
+// DeleteAdvert soft-deletes the advert. The row stays until PurgeAdvert.
+func (s *advertRepository) DeleteAdvert(ctx context.Context, advertID int) error {
 	log.Printf("DeleteAdvert: Deleting advert with ID %d.", advertID)
-	query := `DELETE FROM adverts WHERE advert_id = $1`
-	_, err := s.db.ExecContext(s.ctx, query, advertID)
+	query := `UPDATE adverts SET deleted_at = now(), version = version + 1 WHERE advert_id = $1 AND deleted_at IS NULL`
+	_, err := conn(ctx, s.db).ExecContext(ctx, query, advertID)
 	if err != nil {
 		log.Printf("DeleteAdvert: Failed to delete advert with ID %d: %v", advertID, err)
-		return fmt.Errorf("failed to delete advert: %w", err)
+		return fmt.Errorf("failed to delete advert: %w", apperrors.FromDB(err, "advert"))
 	}
 	return nil
 }
 
-// run date ve progress tek başına da güncellenebilir
-func (s *advertRepository) UpdateAdvert(advertID int, campaign_id int, progress *string, runDate *time.Time) error {
-	log.Printf("UpdateAdvert: Updating advert with ID %d.", advertID)
+// UpdateAdvert writes the fields of after that differ from before, if the
+// advert is still at version, and returns its new version.
+func (s *advertRepository) UpdateAdvert(ctx context.Context, version int, before, after models.Advert) (int, error) {
+	log.Printf("UpdateAdvert: Updating advert with ID %d.", before.AdvertID)
+	var changes columnChanges
+	changes.set("campaign_id", before.CampaignID, after.CampaignID)
+	changes.set("progress", before.Progress, after.Progress)
+	changes.set("run_date", before.RunDate, after.RunDate)
 
-	existingAdvert, err := s.GetAdvertById(advertID)
+	newVersion, err := updateVersioned(ctx, conn(ctx, s.db), "adverts", "advert_id", "advert", before.AdvertID, version, changes)
 	if err != nil {
-		log.Printf("UpdateAdvert: Failed to fetch existing advert with ID %d: %v", advertID, err)
-		return err
+		log.Printf("UpdateAdvert: Failed to update advert with ID %d: %v", before.AdvertID, err)
+		return 0, fmt.Errorf("failed to update advert: %w", apperrors.FromDB(err, "advert"))
 	}
+	return newVersion, nil
+}
 
-	newProgress := existingAdvert.Progress
-	if progress != nil {
-		newProgress = *progress
+func (s *advertRepository) GetAdvertsByCampaign(ctx context.Context, campaignID int) ([]models.Advert, error) {
+	log.Printf("GetAdvertsByCampaign: Fetching adverts for campaign ID %d.", campaignID)
+	var adverts []models.Advert
+	query := "SELECT " + advertColumns + " FROM adverts WHERE campaign_id = $1 AND deleted_at IS NULL"
+	err := conn(ctx, s.db).SelectContext(ctx, &adverts, query, campaignID)
+	if err != nil {
+		log.Printf("GetAdvertsByCampaign: Failed to fetch adverts for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to get adverts for campaign id %d: %w", campaignID, apperrors.FromDB(err, "advert"))
 	}
+	return adverts, nil
+}
 
-	newRunDate := existingAdvert.RunDate
-	if runDate != nil {
-		newRunDate = *runDate
+// GetAdvertsByClient returns every advert on the client's campaigns in run
+// date order.
+func (s *advertRepository) GetAdvertsByClient(ctx context.Context, clientID int) ([]models.Advert, error) {
+	log.Printf("GetAdvertsByClient: Fetching adverts for client ID %d.", clientID)
+	adverts := []models.Advert{}
+	query := `SELECT a.advert_id, a.campaign_id, a.progress, a.run_date, a.version, a.deleted_at
+			  FROM adverts a
+			  JOIN campaigns c ON c.campaign_id = a.campaign_id
+			  WHERE c.client_id = $1 AND c.deleted_at IS NULL AND a.deleted_at IS NULL
+			  ORDER BY a.run_date, a.advert_id`
+	err := conn(ctx, s.db).SelectContext(ctx, &adverts, query, clientID)
+	if err != nil {
+		log.Printf("GetAdvertsByClient: Failed to fetch adverts for client ID %d: %v", clientID, err)
+		return nil, fmt.Errorf("failed to get adverts for client id %d: %w", clientID, apperrors.FromDB(err, "advert"))
 	}
+	return adverts, nil
+}
 
-	query := `
-		UPDATE adverts
-		   SET progress = $1, run_date = $2
-		 WHERE advert_id = $3
-	`
-	_, err = s.db.ExecContext(s.ctx, query, newProgress, newRunDate, advertID)
-	if err != nil {
-		log.Printf("UpdateAdvert: Failed to update advert with ID %d: %v", advertID, err)
-		return fmt.Errorf("failed to update advert: %w", err)
+// DeleteAdvertsByCampaign soft-deletes the campaign's adverts that are not
+// already deleted.
+func (s *advertRepository) DeleteAdvertsByCampaign(ctx context.Context, campaignID int) error {
+	log.Printf("DeleteAdvertsByCampaign: Deleting adverts of campaign ID %d.", campaignID)
+	query := `UPDATE adverts SET deleted_at = now(), version = version + 1 WHERE campaign_id = $1 AND deleted_at IS NULL`
+	if _, err := conn(ctx, s.db).ExecContext(ctx, query, campaignID); err != nil {
+		log.Printf("DeleteAdvertsByCampaign: Failed to delete adverts of campaign ID %d: %v", campaignID, err)
+		return fmt.Errorf("failed to delete adverts: %w", apperrors.FromDB(err, "advert"))
 	}
 	return nil
 }
 
-func (s *advertRepository) GetAdvertsByCampaign(campaignID int) ([]models.Advert, error) {
-	log.Printf("GetAdvertsByCampaign: Fetching adverts for campaign ID %d.", campaignID)
-	var adverts []models.Advert
-	query := `SELECT advert_id, campaign_id, progress, run_date
-			  FROM adverts
-			  WHERE campaign_id = $1`
-	err := s.db.SelectContext(s.ctx, &adverts, query, campaignID)
-	if err != nil {
-		log.Printf("GetAdvertsByCampaign: Failed to fetch adverts for campaign ID %d: %v", campaignID, err)
-		return nil, fmt.Errorf("failed to get adverts for campaign id %d: %w", campaignID, err)
+// RestoreAdvert undoes DeleteAdvert and returns the advert's new version.
+func (s *advertRepository) RestoreAdvert(ctx context.Context, advertID int) (int, error) {
+	log.Printf("RestoreAdvert: Restoring advert with ID %d.", advertID)
+	query := `UPDATE adverts SET deleted_at = NULL, version = version + 1 WHERE advert_id = $1 AND deleted_at IS NOT NULL RETURNING version`
+	var version int
+	if err := conn(ctx, s.db).GetContext(ctx, &version, query, advertID); err != nil {
+		log.Printf("RestoreAdvert: Failed to restore advert with ID %d: %v", advertID, err)
+		return 0, fmt.Errorf("failed to restore advert: %w", apperrors.FromDB(err, "deleted advert"))
+	}
+	return version, nil
+}
+
+// RestoreAdvertsDeletedWith restores the campaign's adverts that were
+// deleted at deletedAt, i.e. together with the campaign, and returns them.
+func (s *advertRepository) RestoreAdvertsDeletedWith(ctx context.Context, campaignID int, deletedAt time.Time) ([]models.Advert, error) {
+	log.Printf("RestoreAdvertsDeletedWith: Restoring adverts of campaign ID %d.", campaignID)
+	adverts := []models.Advert{}
+	query := `UPDATE adverts SET deleted_at = NULL, version = version + 1
+			  WHERE campaign_id = $1 AND deleted_at = $2
+			  RETURNING ` + advertColumns
+	if err := conn(ctx, s.db).SelectContext(ctx, &adverts, query, campaignID, deletedAt); err != nil {
+		log.Printf("RestoreAdvertsDeletedWith: Failed to restore adverts of campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to restore adverts: %w", apperrors.FromDB(err, "advert"))
+	}
+	return adverts, nil
+}
+
+// PurgeAdvert permanently deletes a soft-deleted advert. Cost entries that
+// referred to it stay on the campaign without an advert.
+func (s *advertRepository) PurgeAdvert(ctx context.Context, advertID int) error {
+	log.Printf("PurgeAdvert: Purging advert with ID %d.", advertID)
+	return inTx(ctx, s.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE campaign_costs SET advert_id = NULL WHERE advert_id = $1`, advertID); err != nil {
+			log.Printf("PurgeAdvert: Failed to detach cost entries from advert ID %d: %v", advertID, err)
+			return fmt.Errorf("failed to purge advert: %w", apperrors.FromDB(err, "advert"))
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM adverts WHERE advert_id = $1 AND deleted_at IS NOT NULL`, advertID); err != nil {
+			log.Printf("PurgeAdvert: Failed to purge advert with ID %d: %v", advertID, err)
+			return fmt.Errorf("failed to purge advert: %w", apperrors.FromDB(err, "advert"))
+		}
+		return nil
+	})
+}
+
+// PurgeAdvertsByCampaign permanently deletes every advert of the campaign,
+// deleted or not, so the campaign itself can be purged.
+func (s *advertRepository) PurgeAdvertsByCampaign(ctx context.Context, campaignID int) error {
+	log.Printf("PurgeAdvertsByCampaign: Purging adverts of campaign ID %d.", campaignID)
+	query := `DELETE FROM adverts WHERE campaign_id = $1`
+	if _, err := conn(ctx, s.db).ExecContext(ctx, query, campaignID); err != nil {
+		log.Printf("PurgeAdvertsByCampaign: Failed to purge adverts of campaign ID %d: %v", campaignID, err)
+		return fmt.Errorf("failed to purge adverts: %w", apperrors.FromDB(err, "advert"))
+	}
+	return nil
+}
+
+// GetDeletedAdverts returns the adverts deleted before the given time.
+func (s *advertRepository) GetDeletedAdverts(ctx context.Context, before time.Time) ([]models.Advert, error) {
+	adverts := []models.Advert{}
+	query := "SELECT " + advertColumns + " FROM adverts WHERE deleted_at < $1 ORDER BY deleted_at, advert_id"
+	if err := conn(ctx, s.db).SelectContext(ctx, &adverts, query, before); err != nil {
+		log.Printf("GetDeletedAdverts: Failed to fetch adverts deleted before %s: %v", before, err)
+		return nil, fmt.Errorf("failed to get deleted adverts: %w", apperrors.FromDB(err, "advert"))
 	}
 	return adverts, nil
 }
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AuditRepository appends to and reads the audit log. Entries are never
+// changed or removed.
+type AuditRepository interface {
+	AddAuditEntry(ctx context.Context, entry *models.AuditEntry) error
+	GetAuditEntries(ctx context.Context, opts models.ListOptions) ([]models.AuditEntry, int, error)
+}
+
+type auditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+func (r *auditRepository) AddAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	query := `INSERT INTO audit_log (actor_id, entity_type, entity_id, action, before, after)
+			  VALUES ($1, $2, $3, $4, $5, $6)
+			  RETURNING audit_id, occurred_at`
+	err := conn(ctx, r.db).QueryRowxContext(ctx, query, entry.ActorID, entry.EntityType, entry.EntityID, entry.Action, entry.Before, entry.After).
+		Scan(&entry.AuditID, &entry.OccurredAt)
+	if err != nil {
+		log.Printf("AddAuditEntry: Failed to record %s of %s %d: %v", entry.Action, entry.EntityType, entry.EntityID, err)
+		return fmt.Errorf("failed to record audit entry: %w", apperrors.FromDB(err, "audit entry"))
+	}
+	return nil
+}
+
+var auditListSpec = listSpec{
+	table:       "audit_log",
+	columns:     "audit_id, actor_id, entity_type, entity_id, action, before, after, occurred_at",
+	idColumn:    "audit_id",
+	defaultSort: "audit_id",
+	sorts: map[string]string{
+		"audit_id":    "audit_id",
+		"occurred_at": "occurred_at",
+	},
+	filters: map[string]listFilter{
+		"entity_type":      {column: "entity_type", op: "=", kind: filterString},
+		"entity_id":        {column: "entity_id", op: "=", kind: filterInt},
+		"actor_id":         {column: "actor_id", op: "=", kind: filterInt},
+		"action":           {column: "action", op: "=", kind: filterString},
+		"occurred_at_from": {column: "occurred_at::date", op: ">=", kind: filterDate},
+		"occurred_at_to":   {column: "occurred_at::date", op: "<=", kind: filterDate},
+	},
+}
+
+func (r *auditRepository) GetAuditEntries(ctx context.Context, opts models.ListOptions) ([]models.AuditEntry, int, error) {
+	entries, total, err := selectPage[models.AuditEntry](ctx, conn(ctx, r.db), auditListSpec, opts)
+	if err != nil {
+		log.Printf("GetAuditEntries: Failed to fetch audit entries: %v", err)
+		return nil, 0, fmt.Errorf("failed to get audit entries: %w", apperrors.FromDB(err, "audit entry"))
+	}
+	return entries, total, nil
+}
@@ -1,142 +1,461 @@
 package repositories
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type CampaignRepository interface {
-	CreateCampaign(campaign models.Campaign) error
-	GetCampaignByID(campaignID int) (models.Campaign, error)
-	UpdateCampaign(campaign models.Campaign) error
-	DeleteCampaign(campaignID int) error
-	AssignManager(campaignID, managerID int) error
-	GetAllCampaigns() ([]models.Campaign, error)
-	//CheckBudget(campaignID int) (float64, error)
-	GetCampaignsByClientID(clientID int) ([]models.Campaign, error)
+	CreateCampaign(ctx context.Context, campaign *models.Campaign) error
+	GetCampaignByID(ctx context.Context, campaignID int) (models.Campaign, error)
+	GetCampaignIncludingDeleted(ctx context.Context, campaignID int) (models.Campaign, error)
+	GetCampaignForUpdate(ctx context.Context, campaignID int) (models.Campaign, error)
+	UpdateCampaign(ctx context.Context, version int, before, after models.Campaign) (int, error)
+	DeleteCampaign(ctx context.Context, campaignID int) error
+	RestoreCampaign(ctx context.Context, campaignID int) (int, error)
+	PurgeCampaign(ctx context.Context, campaignID int) error
+	GetDeletedCampaigns(ctx context.Context, before time.Time) ([]models.Campaign, error)
+	GetCampaignsDeletedWith(ctx context.Context, clientID int, deletedAt time.Time) ([]models.Campaign, error)
+	DeleteCampaignHistory(ctx context.Context, campaignID int) error
+	AssignManager(ctx context.Context, campaignID, managerID int) (models.CampaignManagerAssignment, error)
+	GetManagerHistory(ctx context.Context, campaignID int) ([]models.CampaignManagerAssignment, error)
+	GetAllCampaigns(ctx context.Context, opts models.ListOptions) ([]models.Campaign, int, error)
+	CheckBudget(ctx context.Context, campaignID int) (models.CampaignBudget, error)
+	GetBudgetForUpdate(ctx context.Context, campaignID int) (models.CampaignBudget, error)
+	GetCampaignsByClientID(ctx context.Context, clientID int) ([]models.Campaign, error)
+	GetCampaignsByClientIncludingDeleted(ctx context.Context, clientID int) ([]models.Campaign, error)
+	TransitionCampaignState(ctx context.Context, campaignID int, from, to models.CampaignState, completionStatus bool, changedBy int) (models.CampaignStateHistory, error)
+	GetCampaignStateHistory(ctx context.Context, campaignID int) ([]models.CampaignStateHistory, error)
 }
 
+// ErrCampaignStateChanged is returned when a campaign's state no longer matches
+// the state a transition was computed from.
+var ErrCampaignStateChanged = apperrors.Conflict("campaign state changed concurrently")
+
 type campaignRepository struct {
-	db  *sqlx.DB
-	ctx context.Context
+	db *sqlx.DB
 }
 
-func NewCampaignRepository(ctx context.Context, db *sqlx.DB) CampaignRepository {
+func NewCampaignRepository(db *sqlx.DB) CampaignRepository {
 	return &campaignRepository{
-		db:  db,
-		ctx: ctx,
+		db: db,
 	}
 }
 
-func (r *campaignRepository) CreateCampaign(campaign models.Campaign) error {
+// CreateCampaign inserts a campaign and sets its CampaignID.
+func (r *campaignRepository) CreateCampaign(ctx context.Context, campaign *models.Campaign) error {
 	log.Println("CreateCampaign: Starting to create a new campaign.")
 	query := `
     INSERT INTO campaigns (
-        client_id, title, start_date, end_date, estimated_cost, actual_cost, completion_status, current_state, manager_id, budget
+        client_id, title, start_date, end_date, estimated_cost, actual_cost, completion_status, current_state, manager_id, budget, currency
     ) VALUES (
-        :client_id, :title, :start_date, :end_date, :estimated_cost, :actual_cost, :completion_status, :current_state, :manager_id, :budget
-    );`
+        :client_id, :title, :start_date, :end_date, :estimated_cost, :actual_cost, :completion_status, :current_state, NULLIF(:manager_id, 0), :budget, :currency
+    ) RETURNING campaign_id, version;`
 
-	_, err := r.db.NamedExecContext(r.ctx, query, campaign)
+	rows, err := sqlx.NamedQueryContext(ctx, conn(ctx, r.db), query, campaign)
 	if err != nil {
 		log.Printf("CreateCampaign: Failed to create campaign: %v\n", err)
-		return fmt.Errorf("failed to create campaign: %w", err)
+		return fmt.Errorf("failed to create campaign: %w", apperrors.FromDB(err, "campaign"))
+	}
+	defer rows.Close()
+	if rows.Next() {
+		err = rows.Scan(&campaign.CampaignID, &campaign.Version)
+	} else {
+		err = rows.Err()
+	}
+	if err != nil {
+		log.Printf("CreateCampaign: Failed to read new campaign ID: %v\n", err)
+		return fmt.Errorf("failed to create campaign: %w", apperrors.FromDB(err, "campaign"))
 	}
 	return nil
 }
 
-func (r *campaignRepository) GetAllCampaigns() ([]models.Campaign, error) {
-	log.Println("GetAllCampaigns: Fetching all campaigns.")
-	query := `SELECT campaign_id, title, start_date, end_date, estimated_cost, actual_cost, completion_status, current_state, manager_id, budget FROM campaigns`
-	var campaigns []models.Campaign
-	err := r.db.SelectContext(r.ctx, &campaigns, query)
+// campaignColumns reads a campaign without a manager as manager_id 0.
+const campaignColumns = "campaign_id, client_id, title, start_date, end_date, estimated_cost, actual_cost, completion_status, current_state, COALESCE(manager_id, 0) AS manager_id, budget, currency, version, deleted_at"
+
+var campaignListSpec = listSpec{
+	table:       "campaigns",
+	columns:     campaignColumns,
+	idColumn:    "campaign_id",
+	defaultSort: "campaign_id",
+	sorts: map[string]string{
+		"campaign_id":   "campaign_id",
+		"title":         "title",
+		"start_date":    "start_date",
+		"end_date":      "end_date",
+		"budget":        "budget",
+		"current_state": "current_state",
+	},
+	filters: map[string]listFilter{
+		"title":           {column: "title", op: "ILIKE", kind: filterString},
+		"current_state":   {column: "current_state", op: "=", kind: filterString},
+		"client_id":       {column: "client_id", op: "=", kind: filterInt},
+		"manager_id":      {column: "manager_id", op: "=", kind: filterInt},
+		"start_date_from": {column: "start_date", op: ">=", kind: filterDate},
+		"start_date_to":   {column: "start_date", op: "<=", kind: filterDate},
+		"end_date_from":   {column: "end_date", op: ">=", kind: filterDate},
+		"end_date_to":     {column: "end_date", op: "<=", kind: filterDate},
+	},
+	softDelete: true,
+}
+
+func (r *campaignRepository) GetAllCampaigns(ctx context.Context, opts models.ListOptions) ([]models.Campaign, int, error) {
+	log.Println("GetAllCampaigns: Fetching campaigns.")
+	campaigns, total, err := selectPage[models.Campaign](ctx, conn(ctx, r.db), campaignListSpec, opts)
 	if err != nil {
 		log.Printf("GetAllCampaigns: Failed to fetch campaigns: %v\n", err)
-		return nil, fmt.Errorf("failed to get all campaigns: %w", err)
+		return nil, 0, fmt.Errorf("failed to get all campaigns: %w", apperrors.FromDB(err, "campaign"))
 	}
-	return campaigns, nil
+	for i := range campaigns {
+		campaigns[i].ApplyCurrency()
+	}
+	return campaigns, total, nil
 }
 
-func (r *campaignRepository) GetCampaignByID(campaignID int) (models.Campaign, error) {
+// GetCampaignByID returns a campaign that has not been deleted.
+func (r *campaignRepository) GetCampaignByID(ctx context.Context, campaignID int) (models.Campaign, error) {
 	log.Printf("GetCampaignByID: Fetching campaign with ID %d.\n", campaignID)
 	var campaign models.Campaign
-	query := "SELECT * FROM campaigns WHERE campaign_id = $1"
-	err := r.db.GetContext(r.ctx, &campaign, query, campaignID)
+	query := "SELECT " + campaignColumns + " FROM campaigns WHERE campaign_id = $1 AND deleted_at IS NULL"
+	err := conn(ctx, r.db).GetContext(ctx, &campaign, query, campaignID)
 	if err != nil {
 		log.Printf("GetCampaignByID: Failed to fetch campaign with ID %d: %v\n", campaignID, err)
-		return campaign, fmt.Errorf("failed to get campaign by ID: %w", err)
+		return campaign, fmt.Errorf("failed to get campaign by ID: %w", apperrors.FromDB(err, "campaign"))
 	}
+	campaign.ApplyCurrency()
 	return campaign, nil
 }
 
-func (r *campaignRepository) UpdateCampaign(campaign models.Campaign) error {
-	log.Printf("UpdateCampaign: Updating campaign with ID %d.\n", campaign.CampaignID)
-	query := `
-		UPDATE campaigns 
-		SET client_id = $1, title = $2, start_date = $3, end_date = $4, estimated_cost = $5, actual_cost = $6, completion_status = $7, current_state = $8, manager_id = $9, budget = $10 
-		WHERE campaign_id = $11;
-	`
-	_, err := r.db.ExecContext(r.ctx, query, campaign.ClientID, campaign.Title, campaign.StartDate, campaign.EndDate, campaign.EstimatedCost, campaign.ActualCost, campaign.CompletionStatus, campaign.CurrentState, campaign.ManagerID, campaign.Budget, campaign.CampaignID)
+func (r *campaignRepository) GetCampaignIncludingDeleted(ctx context.Context, campaignID int) (models.Campaign, error) {
+	var campaign models.Campaign
+	query := "SELECT " + campaignColumns + " FROM campaigns WHERE campaign_id = $1"
+	err := conn(ctx, r.db).GetContext(ctx, &campaign, query, campaignID)
 	if err != nil {
-		log.Printf("UpdateCampaign: Failed to update campaign with ID %d: %v\n", campaign.CampaignID, err)
-		return fmt.Errorf("failed to update campaign: %w", err)
+		log.Printf("GetCampaignIncludingDeleted: Failed to fetch campaign with ID %d: %v\n", campaignID, err)
+		return campaign, fmt.Errorf("failed to get campaign by ID: %w", apperrors.FromDB(err, "campaign"))
 	}
-	return nil
+	campaign.ApplyCurrency()
+	return campaign, nil
+}
+
+// GetCampaignForUpdate reads a campaign and locks its row until the unit of
+// work in ctx ends. Outside a unit of work the lock is released at once.
+func (r *campaignRepository) GetCampaignForUpdate(ctx context.Context, campaignID int) (models.Campaign, error) {
+	var campaign models.Campaign
+	query := "SELECT " + campaignColumns + " FROM campaigns WHERE campaign_id = $1 AND deleted_at IS NULL FOR UPDATE"
+	err := conn(ctx, r.db).GetContext(ctx, &campaign, query, campaignID)
+	if err != nil {
+		log.Printf("GetCampaignForUpdate: Failed to lock campaign with ID %d: %v\n", campaignID, err)
+		return campaign, fmt.Errorf("failed to get campaign by ID: %w", apperrors.FromDB(err, "campaign"))
+	}
+	campaign.ApplyCurrency()
+	return campaign, nil
 }
 
-func (s *campaignRepository) DeleteCampaign(campaignID int) error {
+// UpdateCampaign writes the fields of after that differ from before, if the
+// campaign is still at version, and returns its new version. Its state,
+// manager and actual cost change through their own methods.
+func (r *campaignRepository) UpdateCampaign(ctx context.Context, version int, before, after models.Campaign) (int, error) {
+	log.Printf("UpdateCampaign: Updating campaign with ID %d.\n", before.CampaignID)
+	var changes columnChanges
+	changes.set("client_id", before.ClientID, after.ClientID)
+	changes.set("title", before.Title, after.Title)
+	changes.set("start_date", before.StartDate, after.StartDate)
+	changes.set("end_date", before.EndDate, after.EndDate)
+	changes.set("estimated_cost", before.EstimatedCost, after.EstimatedCost)
+	changes.set("budget", before.Budget, after.Budget)
+	changes.set("currency", before.Currency, after.Currency)
+
+	newVersion, err := updateVersioned(ctx, conn(ctx, r.db), "campaigns", "campaign_id", "campaign", before.CampaignID, version, changes)
+	if err != nil {
+		log.Printf("UpdateCampaign: Failed to update campaign with ID %d: %v\n", before.CampaignID, err)
+		return 0, fmt.Errorf("failed to update campaign: %w", apperrors.FromDB(err, "campaign"))
+	}
+	return newVersion, nil
+}
+
+// DeleteCampaign soft-deletes the campaign. The row stays until
+// PurgeCampaign.
+func (s *campaignRepository) DeleteCampaign(ctx context.Context, campaignID int) error {
 	log.Printf("DeleteCampaign: Deleting campaign with ID %d.", campaignID)
-	query := `DELETE FROM campaigns WHERE campaign_id = $1`
-	_, err := s.db.ExecContext(s.ctx, query, campaignID)
+	query := `UPDATE campaigns SET deleted_at = now(), version = version + 1 WHERE campaign_id = $1 AND deleted_at IS NULL`
+	_, err := conn(ctx, s.db).ExecContext(ctx, query, campaignID)
 	if err != nil {
 		log.Printf("DeleteCampaign: Failed to delete campaign with ID %d: %v", campaignID, err)
-		return fmt.Errorf("failed to delete campaign: %w", err)
+		return fmt.Errorf("failed to delete campaign: %w", apperrors.FromDB(err, "campaign"))
+	}
+	return nil
+}
+
+// RestoreCampaign undoes DeleteCampaign and returns the campaign's new
+// version.
+func (s *campaignRepository) RestoreCampaign(ctx context.Context, campaignID int) (int, error) {
+	log.Printf("RestoreCampaign: Restoring campaign with ID %d.", campaignID)
+	query := `UPDATE campaigns SET deleted_at = NULL, version = version + 1 WHERE campaign_id = $1 AND deleted_at IS NOT NULL RETURNING version`
+	var version int
+	if err := conn(ctx, s.db).GetContext(ctx, &version, query, campaignID); err != nil {
+		log.Printf("RestoreCampaign: Failed to restore campaign with ID %d: %v", campaignID, err)
+		return 0, fmt.Errorf("failed to restore campaign: %w", apperrors.FromDB(err, "deleted campaign"))
+	}
+	return version, nil
+}
+
+// PurgeCampaign permanently deletes a campaign row. Its dependent records
+// must already be gone.
+func (s *campaignRepository) PurgeCampaign(ctx context.Context, campaignID int) error {
+	log.Printf("PurgeCampaign: Purging campaign with ID %d.", campaignID)
+	query := `DELETE FROM campaigns WHERE campaign_id = $1`
+	if _, err := conn(ctx, s.db).ExecContext(ctx, query, campaignID); err != nil {
+		log.Printf("PurgeCampaign: Failed to purge campaign with ID %d: %v", campaignID, err)
+		return fmt.Errorf("failed to purge campaign: %w", apperrors.FromDB(err, "campaign"))
 	}
 	return nil
 }
 
-func (r *campaignRepository) AssignManager(campaignID, managerID int) error {
+// GetDeletedCampaigns returns the campaigns deleted before the given time.
+func (s *campaignRepository) GetDeletedCampaigns(ctx context.Context, before time.Time) ([]models.Campaign, error) {
+	campaigns := []models.Campaign{}
+	query := "SELECT " + campaignColumns + " FROM campaigns WHERE deleted_at < $1 ORDER BY deleted_at, campaign_id"
+	if err := conn(ctx, s.db).SelectContext(ctx, &campaigns, query, before); err != nil {
+		log.Printf("GetDeletedCampaigns: Failed to fetch campaigns deleted before %s: %v", before, err)
+		return nil, fmt.Errorf("failed to get deleted campaigns: %w", apperrors.FromDB(err, "campaign"))
+	}
+	for i := range campaigns {
+		campaigns[i].ApplyCurrency()
+	}
+	return campaigns, nil
+}
+
+// GetCampaignsDeletedWith returns the client's campaigns that were deleted
+// at deletedAt, i.e. together with the client.
+func (s *campaignRepository) GetCampaignsDeletedWith(ctx context.Context, clientID int, deletedAt time.Time) ([]models.Campaign, error) {
+	campaigns := []models.Campaign{}
+	query := "SELECT " + campaignColumns + " FROM campaigns WHERE client_id = $1 AND deleted_at = $2 ORDER BY campaign_id"
+	if err := conn(ctx, s.db).SelectContext(ctx, &campaigns, query, clientID, deletedAt); err != nil {
+		log.Printf("GetCampaignsDeletedWith: Failed to fetch deleted campaigns of client ID %d: %v", clientID, err)
+		return nil, fmt.Errorf("failed to get deleted campaigns: %w", apperrors.FromDB(err, "campaign"))
+	}
+	for i := range campaigns {
+		campaigns[i].ApplyCurrency()
+	}
+	return campaigns, nil
+}
+
+// DeleteCampaignHistory removes a campaign's state and manager history so
+// the campaign itself can be deleted.
+func (r *campaignRepository) DeleteCampaignHistory(ctx context.Context, campaignID int) error {
+	return inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM campaign_state_history WHERE campaign_id = $1`, campaignID); err != nil {
+			log.Printf("DeleteCampaignHistory: Failed to delete state history for campaign ID %d: %v", campaignID, err)
+			return fmt.Errorf("failed to delete campaign state history: %w", apperrors.FromDB(err, "campaign"))
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM campaign_manager_history WHERE campaign_id = $1`, campaignID); err != nil {
+			log.Printf("DeleteCampaignHistory: Failed to delete manager history for campaign ID %d: %v", campaignID, err)
+			return fmt.Errorf("failed to delete campaign manager history: %w", apperrors.FromDB(err, "campaign"))
+		}
+		return nil
+	})
+}
+
+// AssignManager sets a campaign's manager and records the previous one.
+// A missing campaign is reported as not found.
+func (r *campaignRepository) AssignManager(ctx context.Context, campaignID, managerID int) (models.CampaignManagerAssignment, error) {
 	log.Printf("AssignManager: Assigning manager with ID %d to campaign with ID %d.\n", managerID, campaignID)
+	assignment := models.CampaignManagerAssignment{
+		CampaignID: campaignID,
+		ManagerID:  managerID,
+	}
+
+	err := inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		var previous sql.NullInt64
+		lockQuery := `SELECT manager_id FROM campaigns WHERE campaign_id = $1 AND deleted_at IS NULL FOR UPDATE`
+		if err := tx.GetContext(ctx, &previous, lockQuery, campaignID); err != nil {
+			log.Printf("AssignManager: Failed to fetch campaign with ID %d: %v\n", campaignID, err)
+			return fmt.Errorf("failed to assign manager: %w", apperrors.FromDB(err, "campaign"))
+		}
+		if previous.Valid && previous.Int64 != 0 {
+			previousID := int(previous.Int64)
+			assignment.PreviousManagerID = &previousID
+		}
+
+		query := `
+			UPDATE campaigns 
+			SET manager_id = $1, version = version + 1
+			WHERE campaign_id = $2
+		`
+		if _, err := tx.ExecContext(ctx, query, managerID, campaignID); err != nil {
+			log.Printf("AssignManager: Failed to assign manager with ID %d to campaign with ID %d: %v\n", managerID, campaignID, err)
+			return fmt.Errorf("failed to assign manager: %w", apperrors.FromDB(err, "campaign"))
+		}
+
+		historyQuery := `
+			INSERT INTO campaign_manager_history (campaign_id, previous_manager_id, manager_id)
+			VALUES ($1, $2, $3)
+			RETURNING assignment_id, assigned_at
+		`
+		err := tx.QueryRowxContext(ctx, historyQuery, campaignID, assignment.PreviousManagerID, managerID).
+			Scan(&assignment.AssignmentID, &assignment.AssignedAt)
+		if err != nil {
+			log.Printf("AssignManager: Failed to record manager history for campaign with ID %d: %v\n", campaignID, err)
+			return fmt.Errorf("failed to record manager history: %w", apperrors.FromDB(err, "campaign"))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return assignment, err
+	}
+	return assignment, nil
+}
+
+func (r *campaignRepository) GetManagerHistory(ctx context.Context, campaignID int) ([]models.CampaignManagerAssignment, error) {
+	log.Printf("GetManagerHistory: Fetching manager history for campaign ID %d.\n", campaignID)
+	history := []models.CampaignManagerAssignment{}
+	query := `SELECT assignment_id, campaign_id, previous_manager_id, manager_id, assigned_at
+			  FROM campaign_manager_history
+			  WHERE campaign_id = $1
+			  ORDER BY assigned_at, assignment_id`
+	if err := conn(ctx, r.db).SelectContext(ctx, &history, query, campaignID); err != nil {
+		log.Printf("GetManagerHistory: Failed to fetch manager history for campaign ID %d: %v\n", campaignID, err)
+		return nil, fmt.Errorf("failed to get manager history: %w", apperrors.FromDB(err, "campaign"))
+	}
+	return history, nil
+}
+
+// CheckBudget fetches the budget figures of a campaign.
+func (r *campaignRepository) CheckBudget(ctx context.Context, campaignID int) (models.CampaignBudget, error) {
+	log.Printf("CheckBudget: Fetching budget for campaign with ID %d.\n", campaignID)
+	var budget models.CampaignBudget
 	query := `
-		UPDATE campaigns 
-		SET manager_id = $1 
-		WHERE campaign_id = $2
+		SELECT campaign_id, budget, estimated_cost, actual_cost, currency
+		FROM campaigns
+		WHERE campaign_id = $1 AND deleted_at IS NULL
 	`
-	_, err := r.db.ExecContext(r.ctx, query, managerID, campaignID)
+	err := conn(ctx, r.db).GetContext(ctx, &budget, query, campaignID)
 	if err != nil {
-		log.Printf("AssignManager: Failed to assign manager with ID %d to campaign with ID %d: %v\n", managerID, campaignID, err)
-		return fmt.Errorf("failed to assign manager: %w", err)
+		log.Printf("CheckBudget: Failed to fetch budget for campaign with ID %d: %v\n", campaignID, err)
+		return budget, fmt.Errorf("failed to check budget: %w", apperrors.FromDB(err, "campaign"))
 	}
-	return nil
+	budget.ApplyCurrency()
+	return budget, nil
 }
 
-// CheckBudget checks the budget of a campaign.
-/*func (r *campaignRepository) CheckBudget(campaignID int) (float64, error) {
-	var budgetDifference float64
+// GetBudgetForUpdate locks the campaign's row until the unit of work in ctx
+// ends and fetches its budget figures, summing its actual cost afresh from
+// its cost entries. Spending checked under the lock cannot be overtaken by a
+// concurrent posting.
+func (r *campaignRepository) GetBudgetForUpdate(ctx context.Context, campaignID int) (models.CampaignBudget, error) {
+	var budget models.CampaignBudget
 	query := `
-		SELECT estimated_cost - actual_cost AS budget_difference
-		FROM campaigns
-		WHERE campaign_id = $1
+		SELECT c.campaign_id, c.budget, c.estimated_cost, c.currency,
+		       (SELECT COALESCE(SUM(amount), 0) FROM campaign_costs WHERE campaign_id = c.campaign_id) AS actual_cost
+		FROM campaigns c
+		WHERE c.campaign_id = $1 AND c.deleted_at IS NULL
+		FOR UPDATE OF c
 	`
-	err := r.db.GetContext(r.ctx, &budgetDifference, query, campaignID)
+	err := conn(ctx, r.db).GetContext(ctx, &budget, query, campaignID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to check budget: %w", err)
+		log.Printf("GetBudgetForUpdate: Failed to lock budget for campaign with ID %d: %v\n", campaignID, err)
+		return budget, fmt.Errorf("failed to check budget: %w", apperrors.FromDB(err, "campaign"))
 	}
-	return budgetDifference, nil
-}*/
+	budget.ApplyCurrency()
+	return budget, nil
+}
 
-func (r *campaignRepository) GetCampaignsByClientID(clientID int) ([]models.Campaign, error) {
+func (r *campaignRepository) GetCampaignsByClientID(ctx context.Context, clientID int) ([]models.Campaign, error) {
 	log.Printf("GetCampaignsByClientID: Fetching campaigns for client ID %d.\n", clientID)
 	var campaigns []models.Campaign
-	query := "SELECT * FROM campaigns WHERE client_id = $1"
-	err := r.db.SelectContext(r.ctx, &campaigns, query, clientID)
+	query := "SELECT " + campaignColumns + " FROM campaigns WHERE client_id = $1 AND deleted_at IS NULL"
+	err := conn(ctx, r.db).SelectContext(ctx, &campaigns, query, clientID)
 	if err != nil {
 		log.Printf("GetCampaignsByClientID: Failed to fetch campaigns for client ID %d: %v\n", clientID, err)
-		return nil, fmt.Errorf("failed to get campaigns by client id: %w", err)
+		return nil, fmt.Errorf("failed to get campaigns by client id: %w", apperrors.FromDB(err, "campaign"))
+	}
+	for i := range campaigns {
+		campaigns[i].ApplyCurrency()
+	}
+	return campaigns, nil
+}
+
+func (r *campaignRepository) GetCampaignsByClientIncludingDeleted(ctx context.Context, clientID int) ([]models.Campaign, error) {
+	campaigns := []models.Campaign{}
+	query := "SELECT " + campaignColumns + " FROM campaigns WHERE client_id = $1 ORDER BY campaign_id"
+	if err := conn(ctx, r.db).SelectContext(ctx, &campaigns, query, clientID); err != nil {
+		log.Printf("GetCampaignsByClientIncludingDeleted: Failed to fetch campaigns for client ID %d: %v\n", clientID, err)
+		return nil, fmt.Errorf("failed to get campaigns by client id: %w", apperrors.FromDB(err, "campaign"))
+	}
+	for i := range campaigns {
+		campaigns[i].ApplyCurrency()
 	}
 	return campaigns, nil
 }
+
+// current_state ve completion_status sadece bu metot üzerinden değişir
+func (r *campaignRepository) TransitionCampaignState(ctx context.Context, campaignID int, from, to models.CampaignState, completionStatus bool, changedBy int) (models.CampaignStateHistory, error) {
+	log.Printf("TransitionCampaignState: Moving campaign with ID %d from %q to %q.\n", campaignID, from, to)
+	history := models.CampaignStateHistory{
+		CampaignID: campaignID,
+		FromState:  from,
+		ToState:    to,
+		ChangedBy:  changedBy,
+	}
+
+	err := inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		query := `
+			UPDATE campaigns
+			SET current_state = $1, completion_status = $2, version = version + 1
+			WHERE campaign_id = $3 AND current_state = $4
+		`
+		result, err := tx.ExecContext(ctx, query, to, completionStatus, campaignID, from)
+		if err != nil {
+			log.Printf("TransitionCampaignState: Failed to update state of campaign with ID %d: %v\n", campaignID, err)
+			return fmt.Errorf("failed to update campaign state: %w", apperrors.FromDB(err, "campaign"))
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to update campaign state: %w", apperrors.FromDB(err, "campaign"))
+		}
+		if rows == 0 {
+			log.Printf("TransitionCampaignState: Campaign with ID %d is no longer in state %q.\n", campaignID, from)
+			return ErrCampaignStateChanged
+		}
+
+		historyQuery := `
+			INSERT INTO campaign_state_history (campaign_id, from_state, to_state, changed_by)
+			VALUES ($1, $2, $3, $4)
+			RETURNING history_id, changed_at
+		`
+		if err := tx.QueryRowxContext(ctx, historyQuery, campaignID, from, to, changedBy).Scan(&history.HistoryID, &history.ChangedAt); err != nil {
+			log.Printf("TransitionCampaignState: Failed to record state history for campaign with ID %d: %v\n", campaignID, err)
+			return fmt.Errorf("failed to record campaign state history: %w", apperrors.FromDB(err, "campaign"))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return history, err
+	}
+	return history, nil
+}
+
+func (r *campaignRepository) GetCampaignStateHistory(ctx context.Context, campaignID int) ([]models.CampaignStateHistory, error) {
+	log.Printf("GetCampaignStateHistory: Fetching state history for campaign ID %d.\n", campaignID)
+	history := []models.CampaignStateHistory{}
+	query := `SELECT history_id, campaign_id, from_state, to_state, changed_by, changed_at
+			  FROM campaign_state_history
+			  WHERE campaign_id = $1
+			  ORDER BY changed_at, history_id`
+	err := conn(ctx, r.db).SelectContext(ctx, &history, query, campaignID)
+	if err != nil {
+		log.Printf("GetCampaignStateHistory: Failed to fetch state history for campaign ID %d: %v\n", campaignID, err)
+		return nil, fmt.Errorf("failed to get campaign state history: %w", apperrors.FromDB(err, "campaign"))
+	}
+	return history, nil
+}
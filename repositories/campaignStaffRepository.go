@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CampaignStaffRepository interface {
+	AddAssignment(ctx context.Context, assignment *models.CampaignStaff) error
+	RemoveAssignment(ctx context.Context, campaignID, staffID int) error
+	RemoveAssignmentsByCampaign(ctx context.Context, campaignID int) error
+	GetStaffByCampaign(ctx context.Context, campaignID int) ([]models.CampaignStaff, error)
+	AssignmentExists(ctx context.Context, campaignID, staffID int) (bool, error)
+	CountActiveAssignments(ctx context.Context, staffID int) (int, error)
+}
+
+type campaignStaffRepository struct {
+	db *sqlx.DB
+}
+
+func NewCampaignStaffRepository(db *sqlx.DB) CampaignStaffRepository {
+	return &campaignStaffRepository{
+		db: db,
+	}
+}
+
+func (r *campaignStaffRepository) AddAssignment(ctx context.Context, assignment *models.CampaignStaff) error {
+	query := `INSERT INTO campaign_staff (campaign_id, staff_id, role)
+			  VALUES ($1, $2, $3) RETURNING assignment_id, assigned_at`
+	err := conn(ctx, r.db).QueryRowxContext(ctx, query, assignment.CampaignID, assignment.StaffID, assignment.Role).
+		Scan(&assignment.AssignmentID, &assignment.AssignedAt)
+	if err != nil {
+		log.Printf("AddAssignment: Failed to assign staff ID %d to campaign ID %d: %v", assignment.StaffID, assignment.CampaignID, err)
+		return fmt.Errorf("failed to assign staff to campaign: %w", apperrors.FromDB(err, "campaign staff assignment"))
+	}
+	return nil
+}
+
+func (r *campaignStaffRepository) RemoveAssignment(ctx context.Context, campaignID, staffID int) error {
+	query := `DELETE FROM campaign_staff WHERE campaign_id = $1 AND staff_id = $2`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, campaignID, staffID)
+	if err != nil {
+		log.Printf("RemoveAssignment: Failed to remove staff ID %d from campaign ID %d: %v", staffID, campaignID, err)
+		return fmt.Errorf("failed to remove staff from campaign: %w", apperrors.FromDB(err, "campaign staff assignment"))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to remove staff from campaign: %w", apperrors.FromDB(err, "campaign staff assignment"))
+	}
+	if rows == 0 {
+		return apperrors.NotFound("staff %d is not assigned to campaign %d", staffID, campaignID)
+	}
+	return nil
+}
+
+func (r *campaignStaffRepository) GetStaffByCampaign(ctx context.Context, campaignID int) ([]models.CampaignStaff, error) {
+	query := `SELECT assignment_id, campaign_id, staff_id, role, assigned_at
+			  FROM campaign_staff
+			  WHERE campaign_id = $1
+			  ORDER BY assigned_at, assignment_id`
+	assignments := []models.CampaignStaff{}
+	if err := conn(ctx, r.db).SelectContext(ctx, &assignments, query, campaignID); err != nil {
+		log.Printf("GetStaffByCampaign: Failed to get staff for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to get staff for campaign id %d: %w", campaignID, apperrors.FromDB(err, "campaign staff assignment"))
+	}
+	return assignments, nil
+}
+
+func (r *campaignStaffRepository) AssignmentExists(ctx context.Context, campaignID, staffID int) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM campaign_staff WHERE campaign_id = $1 AND staff_id = $2)`
+	if err := conn(ctx, r.db).GetContext(ctx, &exists, query, campaignID, staffID); err != nil {
+		log.Printf("AssignmentExists: Failed to check assignment of staff ID %d: %v", staffID, err)
+		return false, fmt.Errorf("failed to check assignment: %w", apperrors.FromDB(err, "campaign staff assignment"))
+	}
+	return exists, nil
+}
+
+// CountActiveAssignments counts the campaigns a staff member is on that are
+// not yet completed, cancelled or deleted.
+func (r *campaignStaffRepository) CountActiveAssignments(ctx context.Context, staffID int) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(*)
+		  FROM campaign_staff cs
+		  JOIN campaigns c ON c.campaign_id = cs.campaign_id
+		 WHERE cs.staff_id = $1
+		   AND c.current_state IN ($2, $3)
+		   AND c.deleted_at IS NULL
+	`
+	if err := conn(ctx, r.db).GetContext(ctx, &count, query, staffID, models.StateNotStarted, models.StateInProgress); err != nil {
+		log.Printf("CountActiveAssignments: Failed to count assignments of staff ID %d: %v", staffID, err)
+		return 0, fmt.Errorf("failed to count active assignments: %w", apperrors.FromDB(err, "campaign staff assignment"))
+	}
+	return count, nil
+}
+
+func (r *campaignStaffRepository) RemoveAssignmentsByCampaign(ctx context.Context, campaignID int) error {
+	query := `DELETE FROM campaign_staff WHERE campaign_id = $1`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, campaignID); err != nil {
+		log.Printf("RemoveAssignmentsByCampaign: Failed to remove staff from campaign ID %d: %v", campaignID, err)
+		return fmt.Errorf("failed to remove staff from campaign: %w", apperrors.FromDB(err, "campaign staff assignment"))
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"context"
 	"fmt"
@@ -10,86 +11,98 @@ import (
 )
 
 type CampaignManagerRepository interface {
-	GetAllCampaignManager() ([]models.CampaignManager, error)
-	AddCampaignManager(staffGrade *models.CampaignManager) error
-	DeleteCampaignManager(managerID int) error
-	//AssignStaffToManager(staffID int, managerID int) error
+	GetAllCampaignManager(ctx context.Context, opts models.ListOptions) ([]models.CampaignManager, int, error)
+	AddCampaignManager(ctx context.Context, staffGrade *models.CampaignManager) error
+	DeleteCampaignManager(ctx context.Context, managerID int) error
+	GetCampaignManagerByID(ctx context.Context, managerID int) (models.CampaignManager, error)
 	//UpdateCampaignManager(managerID int, staffID *int) error
 }
 
 type campaignManagerRepository struct {
-	ctx context.Context
-	db  *sqlx.DB
+	db *sqlx.DB
 }
 
-func NewCampaignManagerRepository(ctx context.Context, db *sqlx.DB) CampaignManagerRepository {
+func NewCampaignManagerRepository(db *sqlx.DB) CampaignManagerRepository {
 	return &campaignManagerRepository{
-		db:  db,
-		ctx: ctx,
+		db: db,
 	}
 }
 
-func (r *campaignManagerRepository) GetAllCampaignManager() ([]models.CampaignManager, error) {
-	var campaignManager []models.CampaignManager
-	query := "SELECT manager_id, staff_id FROM campaign_manager"
+var campaignManagerListSpec = listSpec{
+	table:       "campaign_manager",
+	columns:     "manager_id, staff_id",
+	idColumn:    "manager_id",
+	defaultSort: "manager_id",
+	sorts: map[string]string{
+		"manager_id": "manager_id",
+		"staff_id":   "staff_id",
+	},
+	filters: map[string]listFilter{
+		"staff_id": {column: "staff_id", op: "=", kind: filterInt},
+	},
+}
 
-	if err := r.db.SelectContext(r.ctx, &campaignManager, query); err != nil {
+func (r *campaignManagerRepository) GetAllCampaignManager(ctx context.Context, opts models.ListOptions) ([]models.CampaignManager, int, error) {
+	campaignManager, total, err := selectPage[models.CampaignManager](ctx, conn(ctx, r.db), campaignManagerListSpec, opts)
+	if err != nil {
 		log.Printf("GetAllCampaignManager: Failed to retrieve managers: %v", err)
-		return nil, fmt.Errorf("failed to retrieve managers: %w", err)
+		return nil, 0, fmt.Errorf("failed to retrieve managers: %w", apperrors.FromDB(err, "campaign manager"))
 	}
 
-	return campaignManager, nil
+	return campaignManager, total, nil
+}
+
+func (r *campaignManagerRepository) GetCampaignManagerByID(ctx context.Context, managerID int) (models.CampaignManager, error) {
+	var manager models.CampaignManager
+	query := "SELECT manager_id, staff_id FROM campaign_manager WHERE manager_id = $1"
+
+	if err := conn(ctx, r.db).GetContext(ctx, &manager, query, managerID); err != nil {
+		log.Printf("GetCampaignManagerByID: Failed to retrieve manager with ID %d: %v", managerID, err)
+		return manager, fmt.Errorf("failed to retrieve manager with id %d: %w", managerID, apperrors.FromDB(err, "campaign manager"))
+	}
+	return manager, nil
 }
 
-func (r *campaignManagerRepository) AddCampaignManager(staffGrade *models.CampaignManager) error {
+func (r *campaignManagerRepository) AddCampaignManager(ctx context.Context, staffGrade *models.CampaignManager) error {
 	query := `INSERT INTO campaign_manager (staff_id) 
               VALUES ($1) RETURNING manager_id`
-	err := r.db.GetContext(r.ctx, &staffGrade.ManagerID, query, staffGrade.StaffID)
+	err := conn(ctx, r.db).GetContext(ctx, &staffGrade.ManagerID, query, staffGrade.StaffID)
 	if err != nil {
 		log.Printf("AddCampaignManager: Failed to add campaign manager: %v", err)
-		return fmt.Errorf("failed to add campaign manager: %w", err)
+		return fmt.Errorf("failed to add campaign manager: %w", apperrors.FromDB(err, "campaign manager"))
 	}
 	return nil
 }
 
-func (r *campaignManagerRepository) DeleteCampaignManager(managerID int) error {
+func (r *campaignManagerRepository) DeleteCampaignManager(ctx context.Context, managerID int) error {
 	query := `DELETE FROM campaign_manager WHERE manager_id = $1`
-	_, err := r.db.ExecContext(r.ctx, query, managerID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, managerID)
 	if err != nil {
 		log.Printf("DeleteCampaignManager: Failed to delete campaign manager with ID %d: %v", managerID, err)
-		return fmt.Errorf("failed to delete campaign manager: %w", err)
+		return fmt.Errorf("failed to delete campaign manager: %w", apperrors.FromDB(err, "campaign manager"))
 	}
 	return nil
 }
 
-/*func (r *campaignManagerRepository) AssignStaffToManager(staffID int, managerID int) error {
-	query := `UPDATE staff SET manager_id = $1 WHERE staff_id = $2`
-	_, err := r.db.ExecContext(r.ctx, query, managerID, staffID)
-	if err != nil {
-		return fmt.Errorf("failed to assign staff to manager: %w", err)
-	}
-	return nil
-}*/
-
-func (r *campaignManagerRepository) UpdateCampaignManager(managerID int, staffID *int) error {
+func (r *campaignManagerRepository) UpdateCampaignManager(ctx context.Context, managerID int, staffID *int) error {
 	query := `UPDATE campaign_manager 
 	SET staff_id = $1 
 	WHERE manager_id = $2`
-	_, err := r.db.ExecContext(r.ctx, query, staffID, managerID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, staffID, managerID)
 	if err != nil {
 		log.Printf("UpdateCampaignManager: Failed to update campaign manager with ID %d: %v", managerID, err)
-		return fmt.Errorf("failed to update campaign manager: %w", err)
+		return fmt.Errorf("failed to update campaign manager: %w", apperrors.FromDB(err, "campaign manager"))
 	}
 	return nil
 }
 
-/*func (r *campaignManagerRepository) UpdateCampaignManager(managerID int, staffID *int) error {
+/*func (r *campaignManagerRepository) UpdateCampaignManager(ctx context.Context, managerID int, staffID *int) error {
 	query := `UPDATE campaign_manager
 	SET staff_id = $1,
 	WHERE manager_id = $2`
-	_, err := r.db.ExecContext(r.ctx, query, staffID,managerID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, staffID,managerID)
 	if err != nil {
-		return fmt.Errorf("failed to update campaign manager: %w", err)
+		return fmt.Errorf("failed to update campaign manager: %w", apperrors.FromDB(err, "campaign manager"))
 	}
 	return nil
 }*/
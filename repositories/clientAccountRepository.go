@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ClientAccountRepository interface {
+	GetClientAccountByUsername(ctx context.Context, username string) (models.ClientAccount, error)
+	GetClientAccountByID(ctx context.Context, clientUserID int) (models.ClientAccount, error)
+	GetClientAccountsByClient(ctx context.Context, clientID int) ([]models.ClientAccount, error)
+	AddClientAccount(ctx context.Context, account *models.ClientAccount) error
+	CreateSession(ctx context.Context, session *models.ClientSession) error
+	GetSession(ctx context.Context, sessionID string) (models.ClientSession, error)
+	RotateSession(ctx context.Context, oldSessionID string, session *models.ClientSession) error
+	RevokeSession(ctx context.Context, sessionID string) error
+}
+
+type clientAccountRepository struct {
+	db *sqlx.DB
+}
+
+func NewClientAccountRepository(db *sqlx.DB) ClientAccountRepository {
+	return &clientAccountRepository{
+		db: db,
+	}
+}
+
+// Accounts of deleted clients are not found, so their users can no longer
+// log in or refresh their tokens.
+func (r *clientAccountRepository) GetClientAccountByUsername(ctx context.Context, username string) (models.ClientAccount, error) {
+	var account models.ClientAccount
+	query := `SELECT a.client_user_id, a.client_id, a.username, a.password_hash, a.created_at
+			  FROM client_accounts a
+			  JOIN clients c ON c.client_id = a.client_id
+			  WHERE a.username = $1 AND c.deleted_at IS NULL`
+	if err := conn(ctx, r.db).GetContext(ctx, &account, query, username); err != nil {
+		log.Printf("GetClientAccountByUsername: Failed to get client account %q: %v", username, err)
+		return account, fmt.Errorf("failed to get client account: %w", apperrors.FromDB(err, "client account"))
+	}
+	return account, nil
+}
+
+func (r *clientAccountRepository) GetClientAccountByID(ctx context.Context, clientUserID int) (models.ClientAccount, error) {
+	var account models.ClientAccount
+	query := `SELECT a.client_user_id, a.client_id, a.username, a.password_hash, a.created_at
+			  FROM client_accounts a
+			  JOIN clients c ON c.client_id = a.client_id
+			  WHERE a.client_user_id = $1 AND c.deleted_at IS NULL`
+	if err := conn(ctx, r.db).GetContext(ctx, &account, query, clientUserID); err != nil {
+		log.Printf("GetClientAccountByID: Failed to get client account with ID %d: %v", clientUserID, err)
+		return account, fmt.Errorf("failed to get client account with id %d: %w", clientUserID, apperrors.FromDB(err, "client account"))
+	}
+	return account, nil
+}
+
+func (r *clientAccountRepository) GetClientAccountsByClient(ctx context.Context, clientID int) ([]models.ClientAccount, error) {
+	accounts := []models.ClientAccount{}
+	query := `SELECT client_user_id, client_id, username, password_hash, created_at
+			  FROM client_accounts
+			  WHERE client_id = $1
+			  ORDER BY client_user_id`
+	if err := conn(ctx, r.db).SelectContext(ctx, &accounts, query, clientID); err != nil {
+		log.Printf("GetClientAccountsByClient: Failed to get accounts for client ID %d: %v", clientID, err)
+		return nil, fmt.Errorf("failed to get accounts for client id %d: %w", clientID, apperrors.FromDB(err, "client account"))
+	}
+	return accounts, nil
+}
+
+func (r *clientAccountRepository) AddClientAccount(ctx context.Context, account *models.ClientAccount) error {
+	query := `INSERT INTO client_accounts (client_id, username, password_hash)
+			  VALUES ($1, $2, $3) RETURNING client_user_id, created_at`
+	err := conn(ctx, r.db).QueryRowxContext(ctx, query, account.ClientID, account.Username, account.PasswordHash).
+		Scan(&account.ClientUserID, &account.CreatedAt)
+	if err != nil {
+		log.Printf("AddClientAccount: Failed to add account for client ID %d: %v", account.ClientID, err)
+		return fmt.Errorf("failed to add client account: %w", apperrors.FromDB(err, "client account"))
+	}
+	return nil
+}
+
+func (r *clientAccountRepository) CreateSession(ctx context.Context, session *models.ClientSession) error {
+	query := `INSERT INTO client_sessions (session_id, client_user_id, expires_at)
+			  VALUES ($1, $2, $3) RETURNING created_at`
+	err := conn(ctx, r.db).GetContext(ctx, &session.CreatedAt, query, session.SessionID, session.ClientUserID, session.ExpiresAt)
+	if err != nil {
+		log.Printf("CreateSession: Failed to create session for client user ID %d: %v", session.ClientUserID, err)
+		return fmt.Errorf("failed to create session: %w", apperrors.FromDB(err, "session"))
+	}
+	return nil
+}
+
+func (r *clientAccountRepository) GetSession(ctx context.Context, sessionID string) (models.ClientSession, error) {
+	var session models.ClientSession
+	query := `SELECT session_id, client_user_id, created_at, expires_at, revoked_at
+			  FROM client_sessions
+			  WHERE session_id = $1`
+	if err := conn(ctx, r.db).GetContext(ctx, &session, query, sessionID); err != nil {
+		log.Printf("GetSession: Failed to get client session: %v", err)
+		return session, fmt.Errorf("failed to get session: %w", apperrors.FromDB(err, "session"))
+	}
+	return session, nil
+}
+
+// RotateSession works like accountRepository.RotateSession for portal logins.
+func (r *clientAccountRepository) RotateSession(ctx context.Context, oldSessionID string, session *models.ClientSession) error {
+	err := inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		revoke := `UPDATE client_sessions SET revoked_at = now()
+				   WHERE session_id = $1 AND revoked_at IS NULL AND expires_at > now()`
+		result, err := tx.ExecContext(ctx, revoke, oldSessionID)
+		if err != nil {
+			log.Printf("RotateSession: Failed to revoke client session: %v", err)
+			return fmt.Errorf("failed to revoke session: %w", apperrors.FromDB(err, "session"))
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to revoke session: %w", apperrors.FromDB(err, "session"))
+		}
+		if rows == 0 {
+			return apperrors.Unauthorized("refresh token is no longer valid")
+		}
+
+		insert := `INSERT INTO client_sessions (session_id, client_user_id, expires_at)
+				   VALUES ($1, $2, $3) RETURNING created_at`
+		if err := tx.GetContext(ctx, &session.CreatedAt, insert, session.SessionID, session.ClientUserID, session.ExpiresAt); err != nil {
+			log.Printf("RotateSession: Failed to create session for client user ID %d: %v", session.ClientUserID, err)
+			return fmt.Errorf("failed to create session: %w", apperrors.FromDB(err, "session"))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *clientAccountRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	query := `UPDATE client_sessions SET revoked_at = now()
+			  WHERE session_id = $1 AND revoked_at IS NULL`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, sessionID); err != nil {
+		log.Printf("RevokeSession: Failed to revoke client session: %v", err)
+		return fmt.Errorf("failed to revoke session: %w", apperrors.FromDB(err, "session"))
+	}
+	return nil
+}
@@ -4,110 +4,177 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
+	"agate-project/apperrors"
 	"agate-project/models"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type ClientRepository interface {
-	GetAllClients() ([]models.Client, error)
-	AddClient(client *models.Client) error
-	RemoveClient(ClientID int) error
-	GetClientByID(clientID int) (models.Client, error)
-	UpdateClient(clientID int, name *string, address *string, contact_details *string) error
+	GetAllClients(ctx context.Context, opts models.ListOptions) ([]models.Client, int, error)
+	AddClient(ctx context.Context, client *models.Client) error
+	RemoveClient(ctx context.Context, ClientID int) error
+	RestoreClient(ctx context.Context, clientID int) (int, error)
+	PurgeClient(ctx context.Context, clientID int) error
+	GetClientByID(ctx context.Context, clientID int) (models.Client, error)
+	GetClientIncludingDeleted(ctx context.Context, clientID int) (models.Client, error)
+	GetDeletedClients(ctx context.Context, before time.Time) ([]models.Client, error)
+	GetDeletionImpact(ctx context.Context, clientID int, includeDeleted bool) (models.DeletionImpact, error)
+	UpdateClient(ctx context.Context, version int, before, after models.Client) (int, error)
 }
 
 type clientRepository struct {
-	ctx context.Context
-	db  *sqlx.DB
+	db *sqlx.DB
 }
 
-func NewClientRepository(ctx context.Context, db *sqlx.DB) ClientRepository {
+func NewClientRepository(db *sqlx.DB) ClientRepository {
 	return &clientRepository{
-		db:  db,
-		ctx: ctx,
+		db: db,
 	}
 }
 
-// r clientRepository'e ait bir pointer receiver
-func (r *clientRepository) GetAllClients() ([]models.Client, error) {
-	var clients []models.Client
-	query := "SELECT client_id, name, address, contact_details FROM clients"
+const clientColumns = "client_id, name, address, contact_details, version, deleted_at"
+
+var clientListSpec = listSpec{
+	table:       "clients",
+	columns:     clientColumns,
+	idColumn:    "client_id",
+	defaultSort: "client_id",
+	sorts: map[string]string{
+		"client_id": "client_id",
+		"name":      "name",
+	},
+	filters: map[string]listFilter{
+		"name": {column: "name", op: "ILIKE", kind: filterString},
+	},
+	softDelete: true,
+}
 
-	if err := r.db.SelectContext(r.ctx, &clients, query); err != nil {
+// r clientRepository'e ait bir pointer receiver
+func (r *clientRepository) GetAllClients(ctx context.Context, opts models.ListOptions) ([]models.Client, int, error) {
+	clients, total, err := selectPage[models.Client](ctx, conn(ctx, r.db), clientListSpec, opts)
+	if err != nil {
 		log.Printf("GetAllClients: Failed to retrieve clients: %v", err)
-		return nil, fmt.Errorf("failed to retrieve clients: %w", err)
+		return nil, 0, fmt.Errorf("failed to retrieve clients: %w", apperrors.FromDB(err, "client"))
 	}
-	return clients, nil
+	return clients, total, nil
 }
 
-func (r *clientRepository) AddClient(client *models.Client) error {
-	query := "INSERT INTO clients (name, address, contact_details) VALUES ($1, $2, $3) RETURNING client_id"
+func (r *clientRepository) AddClient(ctx context.Context, client *models.Client) error {
+	query := "INSERT INTO clients (name, address, contact_details) VALUES ($1, $2, $3) RETURNING client_id, version"
 
-	if err := r.db.GetContext(r.ctx, &client.ClientID, query, client.Name, client.Address, client.ContactDetails); err != nil {
+	if err := conn(ctx, r.db).QueryRowxContext(ctx, query, client.Name, client.Address, client.ContactDetails).Scan(&client.ClientID, &client.Version); err != nil {
 		log.Printf("AddClient: Failed to add client: %v", err)
-		return fmt.Errorf("failed to add client: %w", err)
+		return fmt.Errorf("failed to add client: %w", apperrors.FromDB(err, "client"))
 	}
 	return nil
 }
 
-func (r *clientRepository) RemoveClient(clientID int) error {
-	query := "DELETE FROM clients WHERE client_id = $1"
-	if _, err := r.db.ExecContext(r.ctx, query, clientID); err != nil {
+// RemoveClient soft-deletes the client. The row stays until PurgeClient.
+func (r *clientRepository) RemoveClient(ctx context.Context, clientID int) error {
+	query := "UPDATE clients SET deleted_at = now(), version = version + 1 WHERE client_id = $1 AND deleted_at IS NULL"
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, clientID); err != nil {
 		log.Printf("RemoveClient: Failed to delete client with ID %d: %v", clientID, err)
-		return fmt.Errorf("failed to delete client with id %d: %w", clientID, err)
+		return fmt.Errorf("failed to delete client with id %d: %w", clientID, apperrors.FromDB(err, "client"))
+	}
+	return nil
+}
+
+// RestoreClient undoes RemoveClient and returns the client's new version.
+func (r *clientRepository) RestoreClient(ctx context.Context, clientID int) (int, error) {
+	query := "UPDATE clients SET deleted_at = NULL, version = version + 1 WHERE client_id = $1 AND deleted_at IS NOT NULL RETURNING version"
+	var version int
+	if err := conn(ctx, r.db).GetContext(ctx, &version, query, clientID); err != nil {
+		log.Printf("RestoreClient: Failed to restore client with ID %d: %v", clientID, err)
+		return 0, fmt.Errorf("failed to restore client with id %d: %w", clientID, apperrors.FromDB(err, "deleted client"))
+	}
+	return version, nil
+}
+
+// PurgeClient permanently deletes a client, deleted or not. Its campaigns
+// must already be gone; its portal accounts go with it.
+func (r *clientRepository) PurgeClient(ctx context.Context, clientID int) error {
+	query := "DELETE FROM clients WHERE client_id = $1"
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, clientID); err != nil {
+		log.Printf("PurgeClient: Failed to purge client with ID %d: %v", clientID, err)
+		return fmt.Errorf("failed to purge client with id %d: %w", clientID, apperrors.FromDB(err, "client"))
 	}
 	return nil
 }
 
-func (s *clientRepository) GetClientByID(clientID int) (models.Client, error) {
-	query := `SELECT client_id, name, address, contact_details
-			  FROM clients
-			  WHERE client_id = $1`
+// GetClientByID returns a client that has not been deleted.
+func (s *clientRepository) GetClientByID(ctx context.Context, clientID int) (models.Client, error) {
+	query := "SELECT " + clientColumns + " FROM clients WHERE client_id = $1 AND deleted_at IS NULL"
 	var client models.Client
-	err := s.db.GetContext(s.ctx, &client, query, clientID)
+	err := conn(ctx, s.db).GetContext(ctx, &client, query, clientID)
 	if err != nil {
 		log.Printf("GetClientByID: Failed to get client with ID %d: %v", clientID, err)
-		return client, fmt.Errorf("failed to get client with ID %d: %w", clientID, err)
+		return client, fmt.Errorf("failed to get client with ID %d: %w", clientID, apperrors.FromDB(err, "client"))
 	}
 	return client, nil
 }
 
-func (r *clientRepository) UpdateClient(clientID int, name *string, address *string, contact_details *string) error {
-	existingClient, err := r.GetClientByID(clientID)
+func (s *clientRepository) GetClientIncludingDeleted(ctx context.Context, clientID int) (models.Client, error) {
+	query := "SELECT " + clientColumns + " FROM clients WHERE client_id = $1"
+	var client models.Client
+	err := conn(ctx, s.db).GetContext(ctx, &client, query, clientID)
 	if err != nil {
-		log.Printf("UpdateClient: Failed to fetch existing client with ID %d: %v", clientID, err)
-		return err
+		log.Printf("GetClientIncludingDeleted: Failed to get client with ID %d: %v", clientID, err)
+		return client, fmt.Errorf("failed to get client with ID %d: %w", clientID, apperrors.FromDB(err, "client"))
 	}
+	return client, nil
+}
 
-	// Gelen değer boş değilse güncelle, boşsa mevcut değeri koru
-	newClientName := existingClient.Name
-	if name != nil {
-		newClientName = *name
+// GetDeletionImpact counts the client's campaigns, their adverts and the
+// records kept against them, and the client's portal accounts. Deleted
+// campaigns and adverts are counted only if includeDeleted is set.
+func (s *clientRepository) GetDeletionImpact(ctx context.Context, clientID int, includeDeleted bool) (models.DeletionImpact, error) {
+	query := `
+		WITH c AS (
+			SELECT campaign_id FROM campaigns
+			 WHERE client_id = $1 AND ($2 OR deleted_at IS NULL)
+		)
+		SELECT
+			(SELECT COUNT(*) FROM c) AS campaigns,
+			(SELECT COUNT(*) FROM adverts WHERE campaign_id IN (SELECT campaign_id FROM c) AND ($2 OR deleted_at IS NULL)) AS adverts,
+			(SELECT COUNT(*) FROM campaign_costs WHERE campaign_id IN (SELECT campaign_id FROM c)) AS cost_entries,
+			(SELECT COUNT(*) FROM campaign_staff WHERE campaign_id IN (SELECT campaign_id FROM c)) AS staff_assignments,
+			(SELECT COUNT(*) FROM timesheets WHERE campaign_id IN (SELECT campaign_id FROM c)) AS timesheets,
+			(SELECT COUNT(*) FROM client_accounts WHERE client_id = $1) AS portal_accounts
+	`
+	var impact models.DeletionImpact
+	if err := conn(ctx, s.db).GetContext(ctx, &impact, query, clientID, includeDeleted); err != nil {
+		log.Printf("GetDeletionImpact: Failed to count records of client ID %d: %v", clientID, err)
+		return impact, fmt.Errorf("failed to count records of client %d: %w", clientID, apperrors.FromDB(err, "client"))
 	}
+	return impact, nil
+}
 
-	newClientAddress := existingClient.Address
-	if address != nil {
-		newClientAddress = *address
+// GetDeletedClients returns the clients deleted before the given time.
+func (s *clientRepository) GetDeletedClients(ctx context.Context, before time.Time) ([]models.Client, error) {
+	clients := []models.Client{}
+	query := "SELECT " + clientColumns + " FROM clients WHERE deleted_at < $1 ORDER BY deleted_at, client_id"
+	if err := conn(ctx, s.db).SelectContext(ctx, &clients, query, before); err != nil {
+		log.Printf("GetDeletedClients: Failed to get clients deleted before %s: %v", before, err)
+		return nil, fmt.Errorf("failed to get deleted clients: %w", apperrors.FromDB(err, "client"))
 	}
+	return clients, nil
+}
 
-	newContactDetails := existingClient.ContactDetails
-	if contact_details != nil {
-		newContactDetails = *contact_details
-	}
+// UpdateClient writes the fields of after that differ from before, if the
+// client is still at version, and returns its new version.
+func (r *clientRepository) UpdateClient(ctx context.Context, version int, before, after models.Client) (int, error) {
+	var changes columnChanges
+	changes.set("name", before.Name, after.Name)
+	changes.set("address", before.Address, after.Address)
+	changes.set("contact_details", before.ContactDetails, after.ContactDetails)
 
-	// Güncelleme sorgusu
-	query := `
-		UPDATE clients
-		   SET name = $1, address = $2, contact_details = $3
-		 WHERE client_id = $4
-	`
-	_, err = r.db.ExecContext(r.ctx, query, newClientName, newClientAddress, newContactDetails, clientID)
+	newVersion, err := updateVersioned(ctx, conn(ctx, r.db), "clients", "client_id", "client", before.ClientID, version, changes)
 	if err != nil {
-		log.Printf("UpdateClient: Failed to update client with ID %d: %v", clientID, err)
-		return fmt.Errorf("failed to update client with id %d: %w", clientID, err)
+		log.Printf("UpdateClient: Failed to update client with ID %d: %v", before.ClientID, err)
+		return 0, fmt.Errorf("failed to update client with id %d: %w", before.ClientID, apperrors.FromDB(err, "client"))
 	}
-
-	return nil
+	return newVersion, nil
 }
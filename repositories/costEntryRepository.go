@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CostEntryRepository interface {
+	AddCostEntry(ctx context.Context, entry *models.CostEntry) error
+	GetCostEntryByID(ctx context.Context, entryID int) (models.CostEntry, error)
+	GetCostEntriesByCampaign(ctx context.Context, campaignID int) ([]models.CostEntry, error)
+	DeleteCostEntry(ctx context.Context, entryID int) error
+	DeleteCostEntriesByCampaign(ctx context.Context, campaignID int) error
+}
+
+type costEntryRepository struct {
+	db *sqlx.DB
+}
+
+func NewCostEntryRepository(db *sqlx.DB) CostEntryRepository {
+	return &costEntryRepository{
+		db: db,
+	}
+}
+
+// her kayıt eklendiğinde campaigns.actual_cost yeniden hesaplanır
+func (r *costEntryRepository) AddCostEntry(ctx context.Context, entry *models.CostEntry) error {
+	log.Printf("AddCostEntry: Adding a cost entry for campaign ID %d.", entry.CampaignID)
+	err := inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		query := `INSERT INTO campaign_costs (campaign_id, advert_id, amount, currency, category, entry_date, note)
+				  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING entry_id`
+		err := tx.GetContext(ctx, &entry.EntryID, query, entry.CampaignID, entry.AdvertID, entry.Amount, entry.Currency, entry.Category, entry.EntryDate, entry.Note)
+		if err != nil {
+			log.Printf("AddCostEntry: Failed to add cost entry: %v", err)
+			return fmt.Errorf("failed to add cost entry: %w", apperrors.FromDB(err, "cost entry"))
+		}
+
+		if err := r.refreshActualCost(ctx, tx, entry.CampaignID); err != nil {
+			log.Printf("AddCostEntry: Failed to refresh actual cost for campaign ID %d: %v", entry.CampaignID, err)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *costEntryRepository) GetCostEntryByID(ctx context.Context, entryID int) (models.CostEntry, error) {
+	query := `SELECT entry_id, campaign_id, advert_id, amount, currency, category, entry_date, note
+			  FROM campaign_costs
+			  WHERE entry_id = $1`
+	var entry models.CostEntry
+	if err := conn(ctx, r.db).GetContext(ctx, &entry, query, entryID); err != nil {
+		log.Printf("GetCostEntryByID: Failed to get cost entry with ID %d: %v", entryID, err)
+		return entry, fmt.Errorf("failed to get cost entry with id %d: %w", entryID, apperrors.FromDB(err, "cost entry"))
+	}
+	entry.ApplyCurrency()
+	return entry, nil
+}
+
+func (r *costEntryRepository) GetCostEntriesByCampaign(ctx context.Context, campaignID int) ([]models.CostEntry, error) {
+	query := `SELECT entry_id, campaign_id, advert_id, amount, currency, category, entry_date, note
+			  FROM campaign_costs
+			  WHERE campaign_id = $1
+			  ORDER BY entry_date, entry_id`
+	entries := []models.CostEntry{}
+	if err := conn(ctx, r.db).SelectContext(ctx, &entries, query, campaignID); err != nil {
+		log.Printf("GetCostEntriesByCampaign: Failed to get cost entries for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to get cost entries for campaign id %d: %w", campaignID, apperrors.FromDB(err, "cost entry"))
+	}
+	for i := range entries {
+		entries[i].ApplyCurrency()
+	}
+	return entries, nil
+}
+
+func (r *costEntryRepository) DeleteCostEntry(ctx context.Context, entryID int) error {
+	log.Printf("DeleteCostEntry: Deleting cost entry with ID %d.", entryID)
+	err := inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		var campaignID int
+		query := `DELETE FROM campaign_costs WHERE entry_id = $1 RETURNING campaign_id`
+		if err := tx.GetContext(ctx, &campaignID, query, entryID); err != nil {
+			log.Printf("DeleteCostEntry: Failed to delete cost entry with ID %d: %v", entryID, err)
+			return fmt.Errorf("failed to delete cost entry with id %d: %w", entryID, apperrors.FromDB(err, "cost entry"))
+		}
+
+		if err := r.refreshActualCost(ctx, tx, campaignID); err != nil {
+			log.Printf("DeleteCostEntry: Failed to refresh actual cost for campaign ID %d: %v", campaignID, err)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// refreshActualCost sets a campaign's actual cost to the sum of its cost entries.
+func (r *costEntryRepository) refreshActualCost(ctx context.Context, tx *sqlx.Tx, campaignID int) error {
+	query := `
+		UPDATE campaigns
+		   SET actual_cost = (SELECT COALESCE(SUM(amount), 0) FROM campaign_costs WHERE campaign_id = $1),
+		       version = version + 1
+		 WHERE campaign_id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, campaignID); err != nil {
+		return fmt.Errorf("failed to refresh actual cost: %w", apperrors.FromDB(err, "cost entry"))
+	}
+	return nil
+}
+
+// DeleteCostEntriesByCampaign removes all of a campaign's cost entries
+// without touching its actual cost; it is used when the campaign itself is
+// being deleted.
+func (r *costEntryRepository) DeleteCostEntriesByCampaign(ctx context.Context, campaignID int) error {
+	query := `DELETE FROM campaign_costs WHERE campaign_id = $1`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, campaignID); err != nil {
+		log.Printf("DeleteCostEntriesByCampaign: Failed to delete cost entries for campaign ID %d: %v", campaignID, err)
+		return fmt.Errorf("failed to delete cost entries: %w", apperrors.FromDB(err, "cost entry"))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agate-project/apperrors"
+	"agate-project/models"
+)
+
+type filterKind int
+
+const (
+	filterString filterKind = iota
+	filterInt
+	filterBool
+	filterDate
+)
+
+// listFilter maps a public filter name onto a column comparison.
+type listFilter struct {
+	column string
+	op     string // "=", ">=", "<=" or "ILIKE" (substring match)
+	kind   filterKind
+}
+
+// listSpec is the whitelist a repository exposes for its list endpoint. Only
+// the sort fields and filters named here ever reach the SQL text; values are
+// always bound as parameters. A table with softDelete set has a deleted_at
+// column, and its deleted rows are left out unless IncludeDeleted is set.
+type listSpec struct {
+	table       string
+	columns     string
+	idColumn    string
+	defaultSort string
+	sorts       map[string]string
+	filters     map[string]listFilter
+	softDelete  bool
+}
+
+// likeEscaper escapes LIKE wildcards so a substring filter matches them
+// literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (f listFilter) parse(name, value string) (any, error) {
+	switch f.kind {
+	case filterInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, apperrors.BadRequest("filter %s must be an integer", name)
+		}
+		return n, nil
+	case filterBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, apperrors.BadRequest("filter %s must be true or false", name)
+		}
+		return b, nil
+	case filterDate:
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, apperrors.BadRequest("filter %s must be a date (YYYY-MM-DD)", name)
+		}
+		return d, nil
+	}
+	return value, nil
+}
+
+// build returns the page query, the matching count query and their arguments.
+// The count query uses only the leading filter arguments.
+func (s listSpec) build(opts models.ListOptions) (string, string, []any, error) {
+	names := make([]string, 0, len(opts.Filters))
+	for name := range opts.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var conditions []string
+	var args []any
+	if opts.IncludeDeleted && !s.softDelete {
+		return "", "", nil, apperrors.BadRequest("%s cannot be listed with include_deleted", s.table)
+	}
+	if s.softDelete && !opts.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	for _, name := range names {
+		f, ok := s.filters[name]
+		if !ok {
+			return "", "", nil, apperrors.BadRequest("unknown filter %q", name)
+		}
+		value, err := f.parse(name, opts.Filters[name])
+		if err != nil {
+			return "", "", nil, err
+		}
+		if f.op == "ILIKE" {
+			args = append(args, likeEscaper.Replace(opts.Filters[name]))
+			conditions = append(conditions, fmt.Sprintf(`%s ILIKE '%%' || $%d || '%%' ESCAPE '\'`, f.column, len(args)))
+		} else {
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s %s $%d", f.column, f.op, len(args)))
+		}
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	sortField := opts.Sort
+	if sortField == "" {
+		sortField = s.defaultSort
+	}
+	column, ok := s.sorts[sortField]
+	if !ok {
+		return "", "", nil, apperrors.BadRequest("cannot sort by %q", sortField)
+	}
+	direction := "ASC"
+	if opts.Desc {
+		direction = "DESC"
+	}
+	order := fmt.Sprintf(" ORDER BY %s %s", column, direction)
+	if column != s.idColumn {
+		order += fmt.Sprintf(", %s %s", s.idColumn, direction)
+	}
+
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", s.table, where)
+	query := fmt.Sprintf("SELECT %s FROM %s%s%s LIMIT $%d OFFSET $%d",
+		s.columns, s.table, where, order, len(args)+1, len(args)+2)
+	return query, countQuery, args, nil
+}
+
+// selectPage runs a list query built from spec and returns the requested page
+// along with the total number of matching rows.
+func selectPage[T any](ctx context.Context, db dbtx, spec listSpec, opts models.ListOptions) ([]T, int, error) {
+	query, countQuery, args, err := spec.build(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+
+	var items []T
+	if err := db.SelectContext(ctx, &items, query, append(args, opts.Limit, opts.Offset)...); err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
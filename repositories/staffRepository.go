@@ -1,86 +1,227 @@
 package repositories
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type StaffRepository interface {
-	GetAllStaff() ([]models.Staff, error)
-	AddStaff(staff *models.Staff) error
-	RemoveStaff(StaffID int) error
-	UpdateStaff(StaffID int, updatedDetails *models.Staff) error
-	GetStaffByID(staffID int) (models.Staff, error)
+	GetAllStaff(ctx context.Context, opts models.ListOptions) ([]models.Staff, int, error)
+	AddStaff(ctx context.Context, staff *models.Staff) error
+	RemoveStaff(ctx context.Context, StaffID int) error
+	UpdateStaff(ctx context.Context, before, after models.Staff) error
+	GetStaffByID(ctx context.Context, staffID int) (models.Staff, error)
+	GetStaffForUpdate(ctx context.Context, staffID int) (models.Staff, error)
+	SetStaffActive(ctx context.Context, staffID int, active bool) error
+	GetGradeHistory(ctx context.Context, staffID int) ([]models.StaffGradeChange, error)
+	AddGradeChange(ctx context.Context, change *models.StaffGradeChange) error
+	GetGradeOn(ctx context.Context, staffID int, date time.Time) (int, error)
 }
 
 type staffRepository struct {
-	ctx context.Context
-	db  *sqlx.DB
+	db *sqlx.DB
 }
 
-func NewStaffRepository(ctx context.Context, db *sqlx.DB) StaffRepository {
+func NewStaffRepository(db *sqlx.DB) StaffRepository {
 	return &staffRepository{
-		db:  db,
-		ctx: ctx,
+		db: db,
 	}
 }
 
-func (r *staffRepository) GetAllStaff() ([]models.Staff, error) {
-	var staff []models.Staff
-	query := "SELECT staff_id, name, role, grade_id,starting_grade, start_date FROM staff"
+// staffColumns are read from the staff_details view, which derives the
+// starting and current grade from staff_grade_history.
+const staffColumns = "staff_id, name, role, grade_id, starting_grade, start_date, active"
 
-	if err := r.db.SelectContext(r.ctx, &staff, query); err != nil {
+var staffListSpec = listSpec{
+	table:       "staff_details",
+	columns:     staffColumns,
+	idColumn:    "staff_id",
+	defaultSort: "staff_id",
+	sorts: map[string]string{
+		"staff_id":   "staff_id",
+		"name":       "name",
+		"role":       "role",
+		"grade_id":   "grade_id",
+		"start_date": "start_date",
+	},
+	filters: map[string]listFilter{
+		"name":           {column: "name", op: "ILIKE", kind: filterString},
+		"role":           {column: "role", op: "=", kind: filterString},
+		"grade_id":       {column: "grade_id", op: "=", kind: filterInt},
+		"starting_grade": {column: "starting_grade", op: "=", kind: filterInt},
+		"active":         {column: "active", op: "=", kind: filterBool},
+	},
+}
+
+func (r *staffRepository) GetAllStaff(ctx context.Context, opts models.ListOptions) ([]models.Staff, int, error) {
+	staff, total, err := selectPage[models.Staff](ctx, conn(ctx, r.db), staffListSpec, opts)
+	if err != nil {
 		log.Printf("GetAllStaff: Failed to retrieve staff: %v", err)
-		return nil, fmt.Errorf("failed to retrieve staff: %w", err)
+		return nil, 0, fmt.Errorf("failed to retrieve staff: %w", apperrors.FromDB(err, "staff"))
 	}
-	return staff, nil
+	return staff, total, nil
 }
 
-func (r *staffRepository) GetStaffByID(staffID int) (models.Staff, error) {
+func (r *staffRepository) GetStaffByID(ctx context.Context, staffID int) (models.Staff, error) {
 	var staff models.Staff
-	query := `SELECT * FROM staff WHERE staff_id = $1`
-	err := r.db.GetContext(r.ctx, &staff, query, staffID)
+	query := `SELECT ` + staffColumns + ` FROM staff_details WHERE staff_id = $1`
+	err := conn(ctx, r.db).GetContext(ctx, &staff, query, staffID)
 	if err != nil {
 		log.Printf("GetStaffByID: Failed to get staff with ID %d: %v", staffID, err)
-		return staff, fmt.Errorf("failed to get staff by ID: %w", err)
+		return staff, fmt.Errorf("failed to get staff by ID: %w", apperrors.FromDB(err, "staff"))
 	}
 	return staff, nil
 }
 
-func (r *staffRepository) AddStaff(staff *models.Staff) error {
-	query := `
-		INSERT INTO staff (name, role, grade_id, starting_grade) 
-		VALUES (:name, :role, :grade_id, :starting_grade)
-	`
+// GetStaffForUpdate reads a staff member and locks their row until the unit
+// of work in ctx ends. Outside a unit of work the lock is released at once.
+func (r *staffRepository) GetStaffForUpdate(ctx context.Context, staffID int) (models.Staff, error) {
+	var locked int
+	query := `SELECT staff_id FROM staff WHERE staff_id = $1 FOR UPDATE`
+	if err := conn(ctx, r.db).GetContext(ctx, &locked, query, staffID); err != nil {
+		log.Printf("GetStaffForUpdate: Failed to lock staff with ID %d: %v", staffID, err)
+		return models.Staff{}, fmt.Errorf("failed to get staff by ID: %w", apperrors.FromDB(err, "staff"))
+	}
+	return r.GetStaffByID(ctx, staffID)
+}
+
+// AddStaff inserts the staff member and their starting grade entry together.
+// The starting grade applies from the start date.
+func (r *staffRepository) AddStaff(ctx context.Context, staff *models.Staff) error {
+	err := inTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		query := `
+			INSERT INTO staff (name, role, start_date, active)
+			VALUES ($1, $2, $3, $4)
+			RETURNING staff_id
+		`
+		err := tx.QueryRowxContext(ctx, query, staff.Name, staff.Role, staff.StartDate, staff.Active).Scan(&staff.StaffID)
+		if err != nil {
+			log.Printf("AddStaff: Failed to add staff: %v", err)
+			return fmt.Errorf("failed to add staff: %w", apperrors.FromDB(err, "staff"))
+		}
+
+		historyQuery := `
+			INSERT INTO staff_grade_history (staff_id, grade_id, effective_date, reason)
+			VALUES ($1, $2, $3, $4)
+		`
+		if _, err := tx.ExecContext(ctx, historyQuery, staff.StaffID, staff.StartingGradeID, staff.StartDate, models.GradeReasonStarting); err != nil {
+			log.Printf("AddStaff: Failed to record starting grade for staff with ID %d: %v", staff.StaffID, err)
+			return fmt.Errorf("failed to record starting grade: %w", apperrors.FromDB(err, "staff grade"))
+		}
 
-	_, err := r.db.NamedExecContext(r.ctx, query, staff)
+		return nil
+	})
 	if err != nil {
-		log.Printf("AddStaff: Failed to add staff: %v", err)
-		return fmt.Errorf("failed to add staff: %w", err)
+		return err
 	}
+	staff.GradeID = staff.StartingGradeID
 	return nil
 }
 
-func (r *staffRepository) RemoveStaff(staffID int) error {
+func (r *staffRepository) RemoveStaff(ctx context.Context, staffID int) error {
 	query := "DELETE FROM staff WHERE staff_id = $1"
-	if _, err := r.db.ExecContext(r.ctx, query, staffID); err != nil {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, staffID); err != nil {
 		log.Printf("RemoveStaff: Failed to delete staff with ID %d: %v", staffID, err)
-		return fmt.Errorf("failed to delete staff with ID %d: %w", staffID, err)
+		return fmt.Errorf("failed to delete staff with ID %d: %w", staffID, apperrors.FromDB(err, "staff"))
 	}
 	return nil
 }
 
-func (r *staffRepository) UpdateStaff(staffID int, updatedDetails *models.Staff) error {
-	query := "UPDATE staff SET name = $1, role = $2, grade_id = $3, starting_grade =$4 WHERE staff_id = $5"
+// UpdateStaff writes the name and role of after where they differ from
+// before. Grades change through AddGradeChange.
+func (r *staffRepository) UpdateStaff(ctx context.Context, before, after models.Staff) error {
+	var changes columnChanges
+	changes.set("name", before.Name, after.Name)
+	changes.set("role", before.Role, after.Role)
+	if changes.empty() {
+		return nil
+	}
 
-	_, err := r.db.ExecContext(r.ctx, query, updatedDetails.Name, updatedDetails.Role, updatedDetails.GradeID, staffID)
+	query := "UPDATE staff SET " + changes.assignments(2) + " WHERE staff_id = $1"
+	args := append([]any{before.StaffID}, changes.values...)
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("UpdateStaff: Failed to update staff with ID %d: %v", before.StaffID, err)
+		return fmt.Errorf("failed to update staff with ID %d: %w", before.StaffID, apperrors.FromDB(err, "staff"))
+	}
+	rows, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("UpdateStaff: Failed to update staff with ID %d: %v", staffID, err)
-		return fmt.Errorf("failed to update staff with ID %d: %w", staffID, err)
+		return fmt.Errorf("failed to update staff with ID %d: %w", before.StaffID, apperrors.FromDB(err, "staff"))
+	}
+	if rows == 0 {
+		return apperrors.NotFound("staff with ID %d not found", before.StaffID)
 	}
 	return nil
 }
+
+func (r *staffRepository) SetStaffActive(ctx context.Context, staffID int, active bool) error {
+	query := "UPDATE staff SET active = $1 WHERE staff_id = $2"
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, active, staffID)
+	if err != nil {
+		log.Printf("SetStaffActive: Failed to update staff with ID %d: %v", staffID, err)
+		return fmt.Errorf("failed to update status of staff with ID %d: %w", staffID, apperrors.FromDB(err, "staff"))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update status of staff with ID %d: %w", staffID, apperrors.FromDB(err, "staff"))
+	}
+	if rows == 0 {
+		return apperrors.NotFound("staff with ID %d not found", staffID)
+	}
+	return nil
+}
+
+func (r *staffRepository) GetGradeHistory(ctx context.Context, staffID int) ([]models.StaffGradeChange, error) {
+	var history []models.StaffGradeChange
+	query := `
+		SELECT h.history_id, h.staff_id, h.grade_id, g.grade_name, h.effective_date, h.reason, h.recorded_at
+		FROM staff_grade_history h
+		JOIN staff_grades g ON g.grade_id = h.grade_id
+		WHERE h.staff_id = $1
+		ORDER BY h.effective_date
+	`
+	if err := conn(ctx, r.db).SelectContext(ctx, &history, query, staffID); err != nil {
+		log.Printf("GetGradeHistory: Failed to get grade history for staff with ID %d: %v", staffID, err)
+		return nil, fmt.Errorf("failed to get grade history: %w", apperrors.FromDB(err, "staff grade history"))
+	}
+	return history, nil
+}
+
+func (r *staffRepository) AddGradeChange(ctx context.Context, change *models.StaffGradeChange) error {
+	query := `
+		INSERT INTO staff_grade_history (staff_id, grade_id, effective_date, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING history_id, recorded_at
+	`
+	err := conn(ctx, r.db).QueryRowxContext(ctx, query, change.StaffID, change.GradeID, change.EffectiveDate, change.Reason).
+		Scan(&change.HistoryID, &change.RecordedAt)
+	if err != nil {
+		log.Printf("AddGradeChange: Failed to record grade change for staff with ID %d: %v", change.StaffID, err)
+		return fmt.Errorf("failed to record grade change: %w", apperrors.FromDB(err, "grade change"))
+	}
+	return nil
+}
+
+// GetGradeOn returns the grade a staff member held on date: the latest entry
+// effective on or before it.
+func (r *staffRepository) GetGradeOn(ctx context.Context, staffID int, date time.Time) (int, error) {
+	var gradeID int
+	query := `
+		SELECT grade_id
+		FROM staff_grade_history
+		WHERE staff_id = $1 AND effective_date <= $2
+		ORDER BY effective_date DESC
+		LIMIT 1
+	`
+	if err := conn(ctx, r.db).GetContext(ctx, &gradeID, query, staffID, date); err != nil {
+		log.Printf("GetGradeOn: Failed to get grade of staff with ID %d on %s: %v", staffID, date.Format(time.DateOnly), err)
+		return 0, fmt.Errorf("failed to get grade on %s: %w", date.Format(time.DateOnly), apperrors.FromDB(err, "staff grade"))
+	}
+	return gradeID, nil
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"context"
 	"fmt"
@@ -10,106 +11,97 @@ import (
 )
 
 type StaffGradeRepository interface {
-	AddStaffGrade(staffGrade *models.StaffGrade) error
-	GetAllStaffGrades() ([]models.StaffGrade, error)
-	GetStaffGradeById(gradeID int) (models.StaffGrade, error)
-	DeleteStaffGrade(gradeID int) error
-	UpdateStaffGrade(gradeID int, gradeName *string, payRate *int) error
-	AssignStaffToGrade(staffID int, gradeID int) error
+	AddStaffGrade(ctx context.Context, staffGrade *models.StaffGrade) error
+	GetAllStaffGrades(ctx context.Context, opts models.ListOptions) ([]models.StaffGrade, int, error)
+	GetStaffGradeById(ctx context.Context, gradeID int) (models.StaffGrade, error)
+	DeleteStaffGrade(ctx context.Context, gradeID int) error
+	UpdateStaffGrade(ctx context.Context, version int, before, after models.StaffGrade) (int, error)
 }
 
 type staffGradeRepository struct {
-	ctx context.Context
-	db  *sqlx.DB
+	db *sqlx.DB
 }
 
-func NewStaffGradeRepository(ctx context.Context, db *sqlx.DB) StaffGradeRepository {
+func NewStaffGradeRepository(db *sqlx.DB) StaffGradeRepository {
 	return &staffGradeRepository{
-		db:  db,
-		ctx: ctx,
+		db: db,
 	}
 }
 
-func (s *staffGradeRepository) AddStaffGrade(staffGrade *models.StaffGrade) error {
-	query := `INSERT INTO staff_grades (grade_name, pay_rate) 
-              VALUES ($1, $2) RETURNING grade_id`
-	if err := s.db.GetContext(s.ctx, &staffGrade.GradeID, query, staffGrade.GradeName, staffGrade.PayRate); err != nil {
+func (s *staffGradeRepository) AddStaffGrade(ctx context.Context, staffGrade *models.StaffGrade) error {
+	query := `INSERT INTO staff_grades (grade_name, pay_rate, currency) 
+              VALUES ($1, $2, $3) RETURNING grade_id, version`
+	if err := conn(ctx, s.db).QueryRowxContext(ctx, query, staffGrade.GradeName, staffGrade.PayRate, staffGrade.Currency).Scan(&staffGrade.GradeID, &staffGrade.Version); err != nil {
 		log.Printf("AddStaffGrade: Failed to add staff grade: %v", err)
-		return fmt.Errorf("failed to add staff grade: %w", err)
+		return fmt.Errorf("failed to add staff grade: %w", apperrors.FromDB(err, "staff grade"))
 	}
 	return nil
 }
 
-func (s *staffGradeRepository) GetStaffGradeById(gradeID int) (models.StaffGrade, error) {
-	query := `SELECT grade_id, grade_name, pay_rate
+func (s *staffGradeRepository) GetStaffGradeById(ctx context.Context, gradeID int) (models.StaffGrade, error) {
+	query := `SELECT grade_id, grade_name, pay_rate, currency, version
 			  FROM staff_grades
 			  WHERE grade_id = $1`
 	var grade models.StaffGrade
-	err := s.db.GetContext(s.ctx, &grade, query, gradeID)
+	err := conn(ctx, s.db).GetContext(ctx, &grade, query, gradeID)
 	if err != nil {
 		log.Printf("GetStaffGradeById: Failed to get staff grade with ID %d: %v", gradeID, err)
-		return grade, fmt.Errorf("failed to get staff grade with id %d: %w", gradeID, err)
+		return grade, fmt.Errorf("failed to get staff grade with id %d: %w", gradeID, apperrors.FromDB(err, "staff grade"))
 	}
+	grade.ApplyCurrency()
 	return grade, nil
 }
 
-func (s *staffGradeRepository) GetAllStaffGrades() ([]models.StaffGrade, error) {
-	query := `SELECT grade_id, grade_name, pay_rate FROM staff_grades`
-	var grades []models.StaffGrade
-	err := s.db.SelectContext(s.ctx, &grades, query)
+var staffGradeListSpec = listSpec{
+	table:       "staff_grades",
+	columns:     "grade_id, grade_name, pay_rate, currency, version",
+	idColumn:    "grade_id",
+	defaultSort: "grade_id",
+	sorts: map[string]string{
+		"grade_id":   "grade_id",
+		"grade_name": "grade_name",
+		"pay_rate":   "pay_rate",
+	},
+	filters: map[string]listFilter{
+		"grade_name": {column: "grade_name", op: "ILIKE", kind: filterString},
+		"currency":   {column: "currency", op: "=", kind: filterString},
+	},
+}
+
+func (s *staffGradeRepository) GetAllStaffGrades(ctx context.Context, opts models.ListOptions) ([]models.StaffGrade, int, error) {
+	grades, total, err := selectPage[models.StaffGrade](ctx, conn(ctx, s.db), staffGradeListSpec, opts)
 	if err != nil {
 		log.Printf("GetAllStaffGrades: Failed to get all staff grades: %v", err)
-		return nil, fmt.Errorf("failed to get all staff grades: %w", err)
+		return nil, 0, fmt.Errorf("failed to get all staff grades: %w", apperrors.FromDB(err, "staff grade"))
 	}
-	return grades, nil
+	for i := range grades {
+		grades[i].ApplyCurrency()
+	}
+	return grades, total, nil
 }
 
-func (s *staffGradeRepository) DeleteStaffGrade(gradeID int) error {
+func (s *staffGradeRepository) DeleteStaffGrade(ctx context.Context, gradeID int) error {
 	query := `DELETE FROM staff_grades WHERE grade_id = $1`
-	_, err := s.db.ExecContext(s.ctx, query, gradeID)
+	_, err := conn(ctx, s.db).ExecContext(ctx, query, gradeID)
 	if err != nil {
 		log.Printf("DeleteStaffGrade: Failed to delete staff grade with ID %d: %v", gradeID, err)
-		return fmt.Errorf("failed to delete staff grade: %w", err)
+		return fmt.Errorf("failed to delete staff grade: %w", apperrors.FromDB(err, "staff grade"))
 	}
 	return nil
 }
 
-func (s *staffGradeRepository) UpdateStaffGrade(gradeID int, gradeName *string, payRate *int) error {
-	existingGrade, err := s.GetStaffGradeById(gradeID)
-	if err != nil {
-		log.Printf("UpdateStaffGrade: Failed to fetch existing staff grade with ID %d: %v", gradeID, err)
-		return err
-	}
-
-	newGradeName := existingGrade.GradeName
-	if gradeName != nil {
-		newGradeName = *gradeName
-	}
+// UpdateStaffGrade writes the fields of after that differ from before, if
+// the grade is still at version, and returns its new version.
+func (s *staffGradeRepository) UpdateStaffGrade(ctx context.Context, version int, before, after models.StaffGrade) (int, error) {
+	var changes columnChanges
+	changes.set("grade_name", before.GradeName, after.GradeName)
+	changes.set("pay_rate", before.PayRate, after.PayRate)
+	changes.set("currency", before.Currency, after.Currency)
 
-	newPayRate := existingGrade.PayRate
-	if payRate != nil {
-		newPayRate = *payRate
-	}
-
-	query := `
-		UPDATE staff_grades 
-		   SET grade_name = $1, pay_rate = $2
-		 WHERE grade_id = $3
-	`
-	_, err = s.db.ExecContext(s.ctx, query, newGradeName, newPayRate, gradeID)
+	newVersion, err := updateVersioned(ctx, conn(ctx, s.db), "staff_grades", "grade_id", "staff grade", before.GradeID, version, changes)
 	if err != nil {
-		log.Printf("UpdateStaffGrade: Failed to update staff grade with ID %d: %v", gradeID, err)
-		return fmt.Errorf("failed to update staff grade: %w", err)
+		log.Printf("UpdateStaffGrade: Failed to update staff grade with ID %d: %v", before.GradeID, err)
+		return 0, fmt.Errorf("failed to update staff grade: %w", apperrors.FromDB(err, "staff grade"))
 	}
-	return nil
-}
-
-func (s *staffGradeRepository) AssignStaffToGrade(staffID int, gradeID int) error {
-	query := `UPDATE staff SET grade_id = $1 WHERE staff_id = $2`
-	_, err := s.db.ExecContext(s.ctx, query, gradeID, staffID)
-	if err != nil {
-		log.Printf("AssignStaffToGrade: Failed to assign staff with ID %d to grade with ID %d: %v", staffID, gradeID, err)
-		return fmt.Errorf("failed to assign staff to grade: %w", err)
-	}
-	return nil
+	return newVersion, nil
 }
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type TimesheetRepository interface {
+	AddTimesheet(ctx context.Context, timesheet *models.Timesheet) error
+	GetTimesheetsByStaff(ctx context.Context, staffID int) ([]models.Timesheet, error)
+	GetHoursForStaffOnDate(ctx context.Context, staffID int, workDate time.Time) (float64, error)
+	TimesheetExists(ctx context.Context, staffID, campaignID int, workDate time.Time) (bool, error)
+	GetLabourCostByCampaign(ctx context.Context, campaignID int) ([]models.LabourCostLine, error)
+	DeleteTimesheetsByCampaign(ctx context.Context, campaignID int) error
+}
+
+type timesheetRepository struct {
+	db *sqlx.DB
+}
+
+func NewTimesheetRepository(db *sqlx.DB) TimesheetRepository {
+	return &timesheetRepository{
+		db: db,
+	}
+}
+
+func (r *timesheetRepository) AddTimesheet(ctx context.Context, timesheet *models.Timesheet) error {
+	query := `INSERT INTO timesheets (staff_id, campaign_id, work_date, hours, grade_id, hourly_rate, cost, currency, note)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING timesheet_id`
+	err := conn(ctx, r.db).GetContext(ctx, &timesheet.TimesheetID, query,
+		timesheet.StaffID, timesheet.CampaignID, timesheet.WorkDate, timesheet.Hours, timesheet.GradeID,
+		timesheet.HourlyRate, timesheet.Cost, timesheet.Currency, timesheet.Note)
+	if err != nil {
+		log.Printf("AddTimesheet: Failed to add timesheet for staff ID %d: %v", timesheet.StaffID, err)
+		return fmt.Errorf("failed to add timesheet: %w", apperrors.FromDB(err, "timesheet"))
+	}
+	return nil
+}
+
+func (r *timesheetRepository) GetTimesheetsByStaff(ctx context.Context, staffID int) ([]models.Timesheet, error) {
+	query := `SELECT timesheet_id, staff_id, campaign_id, work_date, hours, grade_id, hourly_rate, cost, currency, note
+			  FROM timesheets
+			  WHERE staff_id = $1
+			  ORDER BY work_date, timesheet_id`
+	timesheets := []models.Timesheet{}
+	if err := conn(ctx, r.db).SelectContext(ctx, &timesheets, query, staffID); err != nil {
+		log.Printf("GetTimesheetsByStaff: Failed to get timesheets for staff ID %d: %v", staffID, err)
+		return nil, fmt.Errorf("failed to get timesheets for staff id %d: %w", staffID, apperrors.FromDB(err, "timesheet"))
+	}
+	for i := range timesheets {
+		timesheets[i].ApplyCurrency()
+	}
+	return timesheets, nil
+}
+
+func (r *timesheetRepository) GetHoursForStaffOnDate(ctx context.Context, staffID int, workDate time.Time) (float64, error) {
+	var hours float64
+	query := `SELECT COALESCE(SUM(hours), 0) FROM timesheets WHERE staff_id = $1 AND work_date = $2`
+	if err := conn(ctx, r.db).GetContext(ctx, &hours, query, staffID, workDate); err != nil {
+		log.Printf("GetHoursForStaffOnDate: Failed to get hours for staff ID %d: %v", staffID, err)
+		return 0, fmt.Errorf("failed to get hours for staff id %d: %w", staffID, apperrors.FromDB(err, "timesheet"))
+	}
+	return hours, nil
+}
+
+func (r *timesheetRepository) TimesheetExists(ctx context.Context, staffID, campaignID int, workDate time.Time) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (
+				SELECT 1 FROM timesheets WHERE staff_id = $1 AND campaign_id = $2 AND work_date = $3
+			  )`
+	if err := conn(ctx, r.db).GetContext(ctx, &exists, query, staffID, campaignID, workDate); err != nil {
+		log.Printf("TimesheetExists: Failed to check timesheet for staff ID %d: %v", staffID, err)
+		return false, fmt.Errorf("failed to check timesheet for staff id %d: %w", staffID, apperrors.FromDB(err, "timesheet"))
+	}
+	return exists, nil
+}
+
+func (r *timesheetRepository) GetLabourCostByCampaign(ctx context.Context, campaignID int) ([]models.LabourCostLine, error) {
+	query := `
+		SELECT t.staff_id, s.name AS staff_name, t.grade_id, g.grade_name,
+		       SUM(t.hours) AS hours, SUM(t.cost) AS cost, t.currency
+		  FROM timesheets t
+		  JOIN staff s ON s.staff_id = t.staff_id
+		  JOIN staff_grades g ON g.grade_id = t.grade_id
+		 WHERE t.campaign_id = $1
+		 GROUP BY t.staff_id, s.name, t.grade_id, g.grade_name, t.currency
+		 ORDER BY t.staff_id, t.grade_id
+	`
+	lines := []models.LabourCostLine{}
+	if err := conn(ctx, r.db).SelectContext(ctx, &lines, query, campaignID); err != nil {
+		log.Printf("GetLabourCostByCampaign: Failed to get labour cost for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to get labour cost for campaign id %d: %w", campaignID, apperrors.FromDB(err, "timesheet"))
+	}
+	for i := range lines {
+		lines[i].Cost.Currency = lines[i].Currency
+	}
+	return lines, nil
+}
+
+func (r *timesheetRepository) DeleteTimesheetsByCampaign(ctx context.Context, campaignID int) error {
+	query := `DELETE FROM timesheets WHERE campaign_id = $1`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, campaignID); err != nil {
+		log.Printf("DeleteTimesheetsByCampaign: Failed to delete timesheets for campaign ID %d: %v", campaignID, err)
+		return fmt.Errorf("failed to delete timesheets: %w", apperrors.FromDB(err, "timesheet"))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// columnChanges collects the columns an update writes, so that updates only
+// touch the columns whose values actually changed. Column names come from
+// the repositories, never from requests; values are bound as parameters.
+type columnChanges struct {
+	columns []string
+	values  []any
+}
+
+// set records column as changed to after unless after equals before.
+func (c *columnChanges) set(column string, before, after any) {
+	if t, ok := before.(time.Time); ok {
+		if t.Equal(after.(time.Time)) {
+			return
+		}
+	} else if before == after {
+		return
+	}
+	c.columns = append(c.columns, column)
+	c.values = append(c.values, after)
+}
+
+func (c columnChanges) empty() bool {
+	return len(c.columns) == 0
+}
+
+// assignments renders the SET list, numbering parameters from first.
+func (c columnChanges) assignments(first int) string {
+	parts := make([]string, len(c.columns))
+	for i, column := range c.columns {
+		parts[i] = fmt.Sprintf("%s = $%d", column, first+i)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// updateVersioned writes changes to the row of table whose idColumn is id if
+// the row is still at version, and returns its new version. With nothing to
+// write it only checks the version, which it then returns unchanged.
+func updateVersioned(ctx context.Context, db dbtx, table, idColumn, entity string, id, version int, changes columnChanges) (int, error) {
+	query := fmt.Sprintf("SELECT version FROM %s WHERE %s = $1 AND version = $2", table, idColumn)
+	if !changes.empty() {
+		query = fmt.Sprintf("UPDATE %s SET %s, version = version + 1 WHERE %s = $1 AND version = $2 RETURNING version",
+			table, changes.assignments(3), idColumn)
+	}
+	args := append([]any{id, version}, changes.values...)
+
+	var newVersion int
+	if err := db.GetContext(ctx, &newVersion, query, args...); err != nil {
+		return 0, checkVersion(err, entity, id, version)
+	}
+	return newVersion, nil
+}
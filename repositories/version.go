@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"agate-project/apperrors"
+	"database/sql"
+	"errors"
+)
+
+// Versioned rows carry a version that every write bumps. Updates name the
+// version they were made against and match on it, so a write based on a
+// stale read changes nothing and fails with staleVersion instead of
+// overwriting someone else's change.
+
+// checkVersion fails with staleVersion when a conditional update matched no
+// row because the row's version had moved on.
+func checkVersion(err error, entity string, id, version int) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return staleVersion(entity, id, version)
+	}
+	return err
+}
+
+func staleVersion(entity string, id, version int) error {
+	return apperrors.PreconditionFailed("%s %d has been modified since version %d", entity, id, version)
+}
+
+// AnyVersion stands for whatever version a record is at, as asked for by
+// If-Match: *. Real versions start at 1.
+const AnyVersion = 0
+
+// RequireVersion returns the version an update must be made against:
+// version itself, or current, the version of the record read in the
+// update's transaction, for AnyVersion. It fails with the error of a stale
+// update if version is not current. Services call it before validating a
+// change so that a stale write is reported as such rather than as whatever
+// is wrong with the change.
+func RequireVersion(entity string, id, current, version int) (int, error) {
+	if version == AnyVersion {
+		return current, nil
+	}
+	if current != version {
+		return 0, staleVersion(entity, id, version)
+	}
+	return version, nil
+}
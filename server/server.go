@@ -2,22 +2,31 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"sync"
 
-	"agate-project/db"
+	"agate-project/config"
 	"agate-project/handlers"
+	"agate-project/middleware"
+	"agate-project/models"
 	"agate-project/repositories"
 	"agate-project/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
 )
 
 type Server struct {
+	closeOnce sync.Once
+
+	Config                  config.Config
 	DB                      *sqlx.DB
 	Router                  *gin.Engine
+	HTTPServer              *http.Server
 	ClientRepo              repositories.ClientRepository
 	ClientService           services.ClientService
 	ClientHandlers          handlers.ClientHandlers
@@ -30,94 +39,201 @@ type Server struct {
 	CampaignRepo            repositories.CampaignRepository
 	CampaignService         services.CampaignService
 	CampaignHandlers        handlers.CampaignHandlers
+	CostEntryRepo           repositories.CostEntryRepository
+	CostEntryService        services.CostEntryService
+	CostEntryHandlers       handlers.CostEntryHandlers
+	TimesheetRepo           repositories.TimesheetRepository
+	TimesheetService        services.TimesheetService
+	TimesheetHandlers       handlers.TimesheetHandlers
+	CampaignStaffRepo       repositories.CampaignStaffRepository
+	CampaignStaffService    services.CampaignStaffService
+	CampaignStaffHandlers   handlers.CampaignStaffHandlers
 	CampaignManagerRepo     repositories.CampaignManagerRepository
 	CampaignManagerService  services.CampaignManagerService
 	CampaignManagerHandlers handlers.CampaignManagerHandlers
 	AdvertRepo              repositories.AdvertRepository
 	AdvertService           services.AdvertService
 	AdvertHandlers          handlers.AdvertHandlers
+	AccountRepo             repositories.AccountRepository
+	AuthService             services.AuthService
+	AuthHandlers            handlers.AuthHandlers
+	Policy                  services.Policy
+	ClientAccountRepo       repositories.ClientAccountRepository
+	ClientAuthService       services.ClientAuthService
+	PortalService           services.PortalService
+	PortalHandlers          handlers.PortalHandlers
+	HealthHandlers          handlers.HealthHandlers
+	AuditRepo               repositories.AuditRepository
+	AuditService            services.AuditService
+	AuditHandlers           handlers.AuditHandlers
+	PurgeService            services.PurgeService
 }
 
-func NewServer(ctx context.Context) (*Server, error) {
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Printf(".env file could not be loaded: %v", err)
-	}
+// NewServer wires every layer from cfg, which must already be valid, on top
+// of conn. The server takes ownership of conn and closes it on shutdown.
+func NewServer(cfg config.Config, conn *sqlx.DB) *Server {
+	uow := repositories.NewUnitOfWork(conn)
+
+	auditRepo := repositories.NewAuditRepository(conn)
+	auditService := services.NewAuditService(auditRepo)
+	auditHandlers := handlers.NewAuditHandlers(auditService)
+
+	clientRepo := repositories.NewClientRepository(conn)
+
+	staffGradeRepo := repositories.NewStaffGradeRepository(conn)
+	staffGradeService := services.NewStaffGradeService(staffGradeRepo, auditService, uow)
+	staffGradeHandlers := handlers.NewStaffGradeHandlers(staffGradeService)
+
+	staffRepo := repositories.NewStaffRepository(conn)
+	staffService := services.NewStaffService(staffRepo, staffGradeRepo, auditService, uow)
+	staffHandlers := handlers.NewStaffHandlers(staffService)
+
+	campaignManagerRepo := repositories.NewCampaignManagerRepository(conn)
+	campaignManagerService := services.NewCampaignManagerService(campaignManagerRepo, auditService, uow)
+	campaignManagerHandlers := handlers.NewCampaignManagerHandlers(campaignManagerService)
+
+	campaignRepo := repositories.NewCampaignRepository(conn)
+	advertRepo := repositories.NewAdvertRepository(conn)
+	campaignService := services.NewCampaignService(campaignRepo, clientRepo, campaignManagerRepo, staffRepo, advertRepo, auditService, uow, cfg.Budget)
+	advertService := services.NewAdvertService(advertRepo, campaignRepo, auditService, uow)
+
+	costEntryRepo := repositories.NewCostEntryRepository(conn)
+	costEntryService := services.NewCostEntryService(costEntryRepo, campaignRepo, advertRepo, uow, cfg.Budget)
+	costEntryHandlers := handlers.NewCostEntryHandlers(costEntryService)
+
+	timesheetRepo := repositories.NewTimesheetRepository(conn)
+	timesheetService := services.NewTimesheetService(timesheetRepo, staffRepo, staffGradeRepo, campaignRepo, uow)
+
+	campaignStaffRepo := repositories.NewCampaignStaffRepository(conn)
+	campaignStaffService := services.NewCampaignStaffService(campaignStaffRepo, campaignRepo, staffRepo, uow, cfg.MaxConcurrentCampaigns)
+	campaignStaffHandlers := handlers.NewCampaignStaffHandlers(campaignStaffService)
+
+	clientService := services.NewClientService(clientRepo, campaignRepo, advertRepo, costEntryRepo, timesheetRepo, campaignStaffRepo, auditService, uow)
+	clientHandlers := handlers.NewClientHandlers(clientService)
 
-	if err := db.OpenDatabase(); err != nil {
-		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	purgeService := services.NewPurgeService(clientRepo, campaignRepo, advertRepo, costEntryRepo, timesheetRepo, campaignStaffRepo, auditService, uow, cfg.Retention)
+
+	policy := services.NewPolicy(campaignRepo, campaignManagerRepo)
+	campaignHandlers := handlers.NewCampaignHandlers(campaignService, policy)
+	advertHandlers := handlers.NewAdvertHandlers(advertService, policy)
+	timesheetHandlers := handlers.NewTimesheetHandlers(timesheetService, policy)
+
+	accountRepo := repositories.NewAccountRepository(conn)
+	authService := services.NewAuthService(accountRepo, cfg.Auth)
+	authHandlers := handlers.NewAuthHandlers(authService)
+
+	clientAccountRepo := repositories.NewClientAccountRepository(conn)
+	clientAuthService := services.NewClientAuthService(clientAccountRepo, clientRepo, cfg.Auth)
+	portalService := services.NewPortalService(campaignRepo, advertRepo)
+	portalHandlers := handlers.NewPortalHandlers(clientAuthService, portalService)
+
+	router := newRouter(cfg)
+
+	healthHandlers := handlers.NewHealthHandlers(conn)
+	router.GET("/health", healthHandlers.Health)
+
+	router.POST("/auth/login", authHandlers.Login)
+	router.POST("/auth/refresh", authHandlers.Refresh)
+	router.POST("/auth/logout", authHandlers.Logout)
+
+	// The client portal has its own logins and tokens; staff tokens are not
+	// accepted here and portal tokens are not accepted anywhere else.
+	router.POST("/portal/auth/login", portalHandlers.Login)
+	router.POST("/portal/auth/refresh", portalHandlers.Refresh)
+	router.POST("/portal/auth/logout", portalHandlers.Logout)
+
+	portal := router.Group("/portal", middleware.AuthenticateClient(clientAuthService))
+	portal.GET("/campaigns", portalHandlers.GetCampaigns)
+	portal.GET("/campaigns/:id", portalHandlers.GetCampaign)
+	portal.GET("/campaigns/:id/adverts", portalHandlers.GetCampaignAdverts)
+	portal.GET("/schedule", portalHandlers.GetSchedule)
+
+	// Everything below requires a valid access token. Routes name the
+	// permission they need; handlers check ownership rules themselves.
+	api := router.Group("/", middleware.Authenticate(authService))
+	can := func(permission models.Permission) gin.HandlerFunc {
+		return middleware.Require(policy, permission)
 	}
 
-	sqlxDB := sqlx.NewDb(db.DB, "postgres")
-
-	clientRepo := repositories.NewClientRepository(ctx, sqlxDB)
-	clientService := services.NewClientService(clientRepo)
-	clientHandlers := handlers.NewClientHandlers(ctx, clientService)
-
-	staffRepo := repositories.NewStaffRepository(ctx, sqlxDB)
-	staffService := services.NewStaffService(staffRepo)
-	staffHandlers := handlers.NewStaffHandlers(ctx, staffService)
-
-	staffGradeRepo := repositories.NewStaffGradeRepository(ctx, sqlxDB)
-	staffGradeService := services.NewStaffGradeService(staffGradeRepo)
-	staffGradeHandlers := handlers.NewStaffGradeHandlers(ctx, staffGradeService)
-
-	campaignRepo := repositories.NewCampaignRepository(ctx, sqlxDB)
-	campaignService := services.NewCampaignService(campaignRepo)
-	campaignHandlers := handlers.NewCampaignHandlers(campaignService)
-
-	campaignManagerRepo := repositories.NewCampaignManagerRepository(ctx, sqlxDB)
-	campaignManagerService := services.NewCampaignManagerService(campaignManagerRepo)
-	campaignManagerHandlers := handlers.NewCampaignManagerHandlers(ctx, campaignManagerService)
-
-	advertRepo := repositories.NewAdvertRepository(ctx, sqlxDB)
-	advertService := services.NewAdvertService(advertRepo)
-	advertHandlers := handlers.NewAdvertHandlers(ctx, advertService)
-
-	router := gin.Default()
-
-	router.GET("/clients", clientHandlers.GetClients)
-	router.GET("/clients/:id", clientHandlers.GetClientByID)
-	router.POST("/clients", clientHandlers.CreateClient)
-	router.DELETE("/clients/:id", clientHandlers.RemoveClient)
-	router.PUT("/clients/:id", clientHandlers.UpdateClient)
-
-	router.GET("/staff", staffHandlers.GetStaff)
-	router.GET("/staff/:id", staffHandlers.GetStaffByID)
-	router.POST("/staff", staffHandlers.CreateStaff)
-	router.DELETE("/staff/:id", staffHandlers.RemoveStaff)
-	router.PUT("/staff/:id", staffHandlers.UpdateStaff)
-
-	router.GET("/grades", staffGradeHandlers.GetAllGrades)
-	router.POST("/grades", staffGradeHandlers.CreateGrade)
-	router.DELETE("/grades/:id", staffGradeHandlers.RemoveGrade)
-	router.PUT("/grades/:id", staffGradeHandlers.UpdateGrade)
-
-	router.GET("/campaigns", campaignHandlers.GetAllCampaigns)
-	router.POST("/campaigns", campaignHandlers.CreateCampaign)
-	router.GET("/campaigns/:id", campaignHandlers.GetCampaignByID)
-	router.PUT("/campaigns/:id", campaignHandlers.UpdateCampaign)
-	router.DELETE("/campaigns/:id", campaignHandlers.RemoveCampaign)
-	//router.GET("/campaigns/:id/budget", campaignHandlers.CheckBudget)
-	router.PUT("/campaigns/:id/manager/:managerID", campaignHandlers.AssignManager)
-
-	router.GET("/campaigns/client/:clientID", campaignHandlers.GetCampaignsByClientID)
-
-	router.GET("/campaign-manager", campaignManagerHandlers.GetAllManagers)
-	router.POST("/campaign-manager", campaignManagerHandlers.CreateManager)
-	router.DELETE("/campaign-manager/:id", campaignManagerHandlers.DeleteManager)
-	//router.PUT("/campaign-managers/:id", campaignManagerHandlers.UpdateManager)
-	//router.POST("/campaign-managers/assign", campaignManagerHandlers.AssignStaffToCampaign)
-
-	router.GET("/adverts", advertHandlers.GetAllAdverts)
-	router.GET("/adverts/:id", advertHandlers.GetAdvertByID)
-	router.POST("/adverts", advertHandlers.CreateAdvert)
-	router.DELETE("/adverts/:id", advertHandlers.RemoveAdvert)
-	router.PUT("/adverts/:id", advertHandlers.UpdateAdvert)
-	router.GET("/adverts/campaign/:campaignID", advertHandlers.GetAdvertsByCampaign)
+	api.GET("/clients", can(models.PermReadClients), clientHandlers.GetClients)
+	api.GET("/clients/:id", can(models.PermReadClients), clientHandlers.GetClientByID)
+	api.POST("/clients", can(models.PermManageClients), clientHandlers.CreateClient)
+	api.DELETE("/clients/:id", can(models.PermManageClients), clientHandlers.RemoveClient)
+	api.POST("/clients/:id/restore", can(models.PermManageClients), clientHandlers.RestoreClient)
+	api.PUT("/clients/:id", can(models.PermManageClients), clientHandlers.UpdateClient)
+	api.PATCH("/clients/:id", can(models.PermManageClients), clientHandlers.PatchClient)
+	api.GET("/clients/:id/accounts", can(models.PermManageClients), portalHandlers.GetClientAccounts)
+	api.POST("/clients/:id/accounts", can(models.PermManageClients), portalHandlers.CreateClientAccount)
+
+	api.GET("/staff", can(models.PermReadStaff), staffHandlers.GetStaff)
+	api.GET("/staff/:id", can(models.PermReadStaff), staffHandlers.GetStaffByID)
+	api.POST("/staff", can(models.PermManageStaff), staffHandlers.CreateStaff)
+	api.DELETE("/staff/:id", can(models.PermManageStaff), staffHandlers.RemoveStaff)
+	api.PUT("/staff/:id", can(models.PermManageStaff), staffHandlers.UpdateStaff)
+	api.PATCH("/staff/:id", can(models.PermManageStaff), staffHandlers.PatchStaff)
+	api.PUT("/staff/:id/status", can(models.PermManageStaff), staffHandlers.SetStaffStatus)
+	api.PUT("/staff/:id/credentials", can(models.PermManageStaff), authHandlers.SetCredentials)
+	api.GET("/staff/:id/grades", can(models.PermReadStaff), staffHandlers.GetGradeHistory)
+	api.POST("/staff/:id/promotions", can(models.PermManageStaff), staffHandlers.PromoteStaff)
+	api.GET("/staff/:id/timesheets", timesheetHandlers.GetTimesheetsByStaff)
+	api.POST("/staff/:id/timesheets", can(models.PermLogTime), timesheetHandlers.LogTimesheet)
+
+	api.GET("/grades", can(models.PermReadGrades), staffGradeHandlers.GetAllGrades)
+	api.GET("/grades/:id", can(models.PermReadGrades), staffGradeHandlers.GetGrade)
+	api.POST("/grades", can(models.PermManageGrades), staffGradeHandlers.CreateGrade)
+	api.DELETE("/grades/:id", can(models.PermManageGrades), staffGradeHandlers.RemoveGrade)
+	api.PUT("/grades/:id", can(models.PermManageGrades), staffGradeHandlers.UpdateGrade)
+	api.PATCH("/grades/:id", can(models.PermManageGrades), staffGradeHandlers.PatchGrade)
+
+	api.GET("/campaigns", can(models.PermReadCampaigns), campaignHandlers.GetAllCampaigns)
+	api.POST("/campaigns", can(models.PermManageCampaigns), campaignHandlers.CreateCampaign)
+	api.GET("/campaigns/:id", can(models.PermReadCampaigns), campaignHandlers.GetCampaignByID)
+	api.PUT("/campaigns/:id", can(models.PermManageCampaigns), campaignHandlers.UpdateCampaign)
+	api.PATCH("/campaigns/:id", can(models.PermManageCampaigns), campaignHandlers.PatchCampaign)
+	api.DELETE("/campaigns/:id", can(models.PermDeleteCampaigns), campaignHandlers.RemoveCampaign)
+	api.POST("/campaigns/:id/restore", can(models.PermDeleteCampaigns), campaignHandlers.RestoreCampaign)
+	api.GET("/campaigns/:id/budget", can(models.PermReadCampaigns), campaignHandlers.CheckBudget)
+	api.GET("/campaigns/:id/costs", can(models.PermReadCosts), costEntryHandlers.GetCostEntries)
+	api.POST("/campaigns/:id/costs", can(models.PermPostCosts), costEntryHandlers.CreateCostEntry)
+	api.DELETE("/campaigns/:id/costs/:entryID", can(models.PermPostCosts), costEntryHandlers.RemoveCostEntry)
+	api.GET("/campaigns/:id/labour-cost", can(models.PermReadCosts), timesheetHandlers.GetLabourCost)
+	api.GET("/campaigns/:id/staff", can(models.PermReadCampaigns), campaignStaffHandlers.GetCampaignStaff)
+	api.POST("/campaigns/:id/staff", can(models.PermManageCampaigns), campaignStaffHandlers.AssignStaff)
+	api.DELETE("/campaigns/:id/staff/:staffID", can(models.PermManageCampaigns), campaignStaffHandlers.RemoveStaff)
+	api.PUT("/campaigns/:id/manager/:managerID", can(models.PermManageManagers), campaignHandlers.AssignManager)
+	api.GET("/campaigns/:id/managers", can(models.PermReadCampaigns), campaignHandlers.GetManagerHistory)
+	api.POST("/campaigns/:id/transitions", campaignHandlers.TransitionCampaign)
+	api.GET("/campaigns/:id/history", can(models.PermReadCampaigns), campaignHandlers.GetCampaignHistory)
+
+	api.GET("/campaigns/client/:clientID", can(models.PermReadCampaigns), campaignHandlers.GetCampaignsByClientID)
+
+	api.GET("/campaign-manager", can(models.PermReadStaff), campaignManagerHandlers.GetAllManagers)
+	api.POST("/campaign-manager", can(models.PermManageManagers), campaignManagerHandlers.CreateManager)
+	api.DELETE("/campaign-manager/:id", can(models.PermManageManagers), campaignManagerHandlers.DeleteManager)
+	//api.PUT("/campaign-managers/:id", campaignManagerHandlers.UpdateManager)
+
+	api.GET("/adverts", can(models.PermReadAdverts), advertHandlers.GetAllAdverts)
+	api.GET("/adverts/:id", can(models.PermReadAdverts), advertHandlers.GetAdvertByID)
+	api.POST("/adverts", can(models.PermManageAdverts), advertHandlers.CreateAdvert)
+	api.DELETE("/adverts/:id", can(models.PermManageAdverts), advertHandlers.RemoveAdvert)
+	api.POST("/adverts/:id/restore", can(models.PermManageAdverts), advertHandlers.RestoreAdvert)
+	api.PUT("/adverts/:id", advertHandlers.UpdateAdvert)
+	api.PATCH("/adverts/:id", advertHandlers.PatchAdvert)
+	api.GET("/adverts/campaign/:campaignID", can(models.PermReadAdverts), advertHandlers.GetAdvertsByCampaign)
+
+	api.GET("/audit", can(models.PermReadAudit), auditHandlers.GetAuditLog)
 
 	srv := &Server{
-		DB:                      sqlxDB,
-		Router:                  router,
+		Config: cfg,
+		DB:     conn,
+		Router: router,
+		HTTPServer: &http.Server{
+			Addr:         cfg.ListenAddr,
+			Handler:      router,
+			ReadTimeout:  cfg.HTTP.Read,
+			WriteTimeout: cfg.HTTP.Write,
+			IdleTimeout:  cfg.HTTP.Idle,
+		},
 		ClientRepo:              clientRepo,
 		ClientService:           clientService,
 		ClientHandlers:          clientHandlers,
@@ -130,21 +246,131 @@ func NewServer(ctx context.Context) (*Server, error) {
 		CampaignRepo:            campaignRepo,
 		CampaignService:         campaignService,
 		CampaignHandlers:        campaignHandlers,
+		CostEntryRepo:           costEntryRepo,
+		CostEntryService:        costEntryService,
+		CostEntryHandlers:       costEntryHandlers,
+		TimesheetRepo:           timesheetRepo,
+		TimesheetService:        timesheetService,
+		TimesheetHandlers:       timesheetHandlers,
+		CampaignStaffRepo:       campaignStaffRepo,
+		CampaignStaffService:    campaignStaffService,
+		CampaignStaffHandlers:   campaignStaffHandlers,
 		CampaignManagerRepo:     campaignManagerRepo,
 		CampaignManagerService:  campaignManagerService,
 		CampaignManagerHandlers: campaignManagerHandlers,
 		AdvertRepo:              advertRepo,
 		AdvertService:           advertService,
 		AdvertHandlers:          advertHandlers,
+		AccountRepo:             accountRepo,
+		AuthService:             authService,
+		AuthHandlers:            authHandlers,
+		Policy:                  policy,
+		ClientAccountRepo:       clientAccountRepo,
+		ClientAuthService:       clientAuthService,
+		PortalService:           portalService,
+		PortalHandlers:          portalHandlers,
+		HealthHandlers:          healthHandlers,
+		AuditRepo:               auditRepo,
+		AuditService:            auditService,
+		AuditHandlers:           auditHandlers,
+		PurgeService:            purgeService,
+	}
+
+	return srv
+}
+
+// newRouter applies the log level and CORS settings. Request logging is only
+// on at debug and info; the level does not reach the log.Printf calls of the
+// handlers, services and repositories.
+func newRouter(cfg config.Config) *gin.Engine {
+	if cfg.LogLevel == config.LogDebug {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
 	}
 
-	return srv, nil
+	router := gin.New()
+	if cfg.LogLevel == config.LogDebug || cfg.LogLevel == config.LogInfo {
+		router.Use(gin.Logger())
+	}
+	router.Use(gin.Recovery())
+	if len(cfg.CORSOrigins) > 0 {
+		router.Use(middleware.CORS(cfg.CORSOrigins))
+	}
+	router.Use(middleware.Errors())
+	router.Use(middleware.Deadline(cfg.HTTP.Request))
+	return router
 }
 
-func (s *Server) Run(addr string) error {
-	return s.Router.Run(addr)
+// Run serves the API on the configured address until ctx is cancelled, then
+// shuts down gracefully. It returns nil after a clean shutdown.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.HTTPServer.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve is Run on an existing listener. The purge job runs alongside and
+// is stopped before the database pool is closed.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.HTTPServer.Serve(listener)
+	}()
+
+	purgeCtx, stopPurge := context.WithCancel(ctx)
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		s.PurgeService.Run(purgeCtx)
+	}()
+	stopJobs := func() {
+		stopPurge()
+		<-purgeDone
+	}
+
+	select {
+	case err := <-serveErr:
+		stopJobs()
+		s.Close()
+		return err
+	case <-ctx.Done():
+	}
+	stopJobs()
+
+	log.Printf("Serve: shutting down, waiting up to %s for active requests", s.Config.HTTP.Shutdown)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.Config.HTTP.Shutdown)
+	defer cancel()
+	err := s.Shutdown(shutdownCtx)
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Serve: server stopped with error: %v", err)
+	}
+	return err
+}
+
+// Shutdown stops accepting connections, waits for active requests until ctx
+// is done, then closes the database pool. Requests still running at the
+// deadline have their connections closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.HTTPServer.Shutdown(ctx)
+	if err != nil {
+		log.Printf("Shutdown: requests did not finish in time: %v", err)
+		s.HTTPServer.Close()
+		err = fmt.Errorf("graceful shutdown incomplete: %w", err)
+	}
+	s.Close()
+	return err
 }
 
+// Close releases the database pool. It is safe to call more than once.
 func (s *Server) Close() {
-	db.CloseDatabase()
+	s.closeOnce.Do(func() {
+		if err := s.DB.Close(); err != nil {
+			log.Printf("Close: Failed to close database: %v", err)
+			return
+		}
+		log.Println("database connection closed")
+	})
 }
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"agate-project/config"
+	"agate-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// noPurge is a purge job that has nothing to do.
+type noPurge struct{}
+
+func (noPurge) Purge(context.Context) (models.PurgeResult, error) { return models.PurgeResult{}, nil }
+func (noPurge) Run(context.Context)                               {}
+
+// newTestServer serves handler on GET /slow. Its database pool points at a
+// port nothing listens on; the tests only check that it gets closed.
+func newTestServer(t *testing.T, handler gin.HandlerFunc, shutdown time.Duration) *Server {
+	t.Helper()
+	connConfig, err := pgx.ParseConfig("postgres://agate@127.0.0.1:1/agate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/slow", handler)
+
+	cfg := config.Config{}
+	cfg.HTTP.Shutdown = shutdown
+	return &Server{
+		Config:       cfg,
+		DB:           sqlx.NewDb(stdlib.OpenDB(*connConfig), "pgx"),
+		Router:       router,
+		HTTPServer:   &http.Server{Handler: router},
+		PurgeService: noPurge{},
+	}
+}
+
+// startServing runs Serve on a local port and returns its address and the
+// channel Serve's result arrives on.
+func startServing(t *testing.T, srv *Server, ctx context.Context) (string, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(ctx, listener)
+	}()
+	return listener.Addr().String(), done
+}
+
+type response struct {
+	status int
+	body   string
+	err    error
+}
+
+func get(url string) <-chan response {
+	result := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			result <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		result <- response{status: resp.StatusCode, body: string(body), err: err}
+	}()
+	return result
+}
+
+// waitForRefusal waits until addr no longer accepts connections.
+func waitForRefusal(t *testing.T, addr string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err != nil {
+			return
+		}
+		conn.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server still accepts connections after shutdown started")
+}
+
+func waitForServe(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+		return nil
+	}
+}
+
+func requireClosedDB(t *testing.T, srv *Server) {
+	t.Helper()
+	if err := srv.DB.Ping(); err == nil || !strings.Contains(err.Error(), "database is closed") {
+		t.Errorf("database pool not closed: ping returned %v", err)
+	}
+}
+
+func TestServeDrainsRequestsOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := newTestServer(t, func(c *gin.Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done")
+	}, 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, done := startServing(t, srv, ctx)
+
+	inFlight := get("http://" + addr + "/slow")
+	<-started
+	cancel()
+
+	waitForRefusal(t, addr)
+	close(release)
+
+	resp := <-inFlight
+	if resp.err != nil || resp.status != http.StatusOK || resp.body != "done" {
+		t.Errorf("in-flight request = %d %q, %v; want 200 \"done\"", resp.status, resp.body, resp.err)
+	}
+	if err := waitForServe(t, done); err != nil {
+		t.Errorf("Serve returned %v, want nil", err)
+	}
+	requireClosedDB(t, srv)
+}
+
+func TestServeClosesRequestsPastShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	srv := newTestServer(t, func(c *gin.Context) {
+		close(started)
+		select {
+		case <-release:
+		case <-c.Request.Context().Done():
+		}
+	}, 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, done := startServing(t, srv, ctx)
+
+	inFlight := get("http://" + addr + "/slow")
+	<-started
+	cancel()
+
+	err := waitForServe(t, done)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Serve returned %v, want the shutdown deadline", err)
+	}
+	if resp := <-inFlight; resp.err == nil {
+		t.Errorf("request past the shutdown timeout completed with %d, want its connection closed", resp.status)
+	}
+	if _, err := net.DialTimeout("tcp", addr, 100*time.Millisecond); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+	requireClosedDB(t, srv)
+}
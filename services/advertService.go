@@ -1,43 +1,55 @@
 package services
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"agate-project/repositories"
+	"context"
 	"fmt"
 	"log"
-	"time"
 )
 
 type AdvertService interface {
-	FetchAllAdverts() ([]models.Advert, error)
-	GetAdvertByID(advertID int) (models.Advert, error)
-	AddAdvert(advert *models.Advert) error
-	RemoveAdvert(advertID int) error
-	UpdateAdvert(advertID int, campaignID int, progress *string, runDate *time.Time) error
-	GetAdvertsByCampaign(campaignID int) ([]models.Advert, error)
+	FetchAllAdverts(ctx context.Context, opts models.ListOptions) (models.Page[models.Advert], error)
+	GetAdvertByID(ctx context.Context, advertID int, includeDeleted bool) (models.Advert, error)
+	AddAdvert(ctx context.Context, advert *models.Advert) error
+	RemoveAdvert(ctx context.Context, advertID int) error
+	RestoreAdvert(ctx context.Context, advertID int) (models.Advert, error)
+	UpdateAdvert(ctx context.Context, advert *models.Advert, mayReschedule bool) error
+	PatchAdvert(ctx context.Context, advertID, version int, patch models.MergePatch) (models.Advert, error)
+	GetAdvertsByCampaign(ctx context.Context, campaignID int) ([]models.Advert, error)
 }
 
 type advertService struct {
-	repo repositories.AdvertRepository
+	repo         repositories.AdvertRepository
+	campaignRepo repositories.CampaignRepository
+	audit        AuditService
+	uow          repositories.UnitOfWork
 }
 
-func NewAdvertService(repo repositories.AdvertRepository) AdvertService {
-	return &advertService{repo: repo}
+func NewAdvertService(repo repositories.AdvertRepository, campaignRepo repositories.CampaignRepository, audit AuditService, uow repositories.UnitOfWork) AdvertService {
+	return &advertService{repo: repo, campaignRepo: campaignRepo, audit: audit, uow: uow}
 }
 
-func (s *advertService) FetchAllAdverts() ([]models.Advert, error) {
-	log.Println("FetchAllAdverts: Fetching all adverts.")
-	adverts, err := s.repo.GetAllAdverts()
+func (s *advertService) FetchAllAdverts(ctx context.Context, opts models.ListOptions) (models.Page[models.Advert], error) {
+	log.Println("FetchAllAdverts: Fetching adverts.")
+	adverts, total, err := s.repo.GetAllAdverts(ctx, opts)
 	if err != nil {
 		log.Printf("FetchAllAdverts: Failed to fetch adverts: %v", err)
-		return nil, fmt.Errorf("fetching adverts failed: %w", err)
+		return models.Page[models.Advert]{}, fmt.Errorf("fetching adverts failed: %w", err)
 	}
-	return adverts, nil
+	return models.NewPage(adverts, total, opts), nil
 }
 
-func (s *advertService) GetAdvertByID(advertID int) (models.Advert, error) {
+// GetAdvertByID returns the advert, or not found if it has been deleted
+// unless includeDeleted is set.
+func (s *advertService) GetAdvertByID(ctx context.Context, advertID int, includeDeleted bool) (models.Advert, error) {
 	log.Printf("GetAdvertByID: Fetching advert with ID %d.", advertID)
-	advert, err := s.repo.GetAdvertById(advertID)
+	get := s.repo.GetAdvertById
+	if includeDeleted {
+		get = s.repo.GetAdvertIncludingDeleted
+	}
+	advert, err := get(ctx, advertID)
 	if err != nil {
 		log.Printf("GetAdvertByID: Failed to fetch advert with ID %d: %v", advertID, err)
 		return advert, fmt.Errorf("fetching advert with id %d failed: %w", advertID, err)
@@ -45,41 +57,163 @@ func (s *advertService) GetAdvertByID(advertID int) (models.Advert, error) {
 	return advert, nil
 }
 
-func (s *advertService) AddAdvert(advert *models.Advert) error {
+func (s *advertService) AddAdvert(ctx context.Context, advert *models.Advert) error {
 	log.Printf("AddAdvert: Adding a new advert for campaign ID %d.", advert.CampaignID)
-	if advert.CampaignID == 0 || advert.Progress == "" {
-		log.Println("AddAdvert: Invalid advert data: campaign ID or progress is missing.")
-		return fmt.Errorf("invalid advert data: campaign ID or progress is missing")
-	}
-
-	if err := s.repo.AddAdvert(advert); err != nil {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.requireCampaign(ctx, advert.CampaignID); err != nil {
+			return err
+		}
+		if err := s.repo.AddAdvert(ctx, advert); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditAdvert, advert.AdvertID, models.AuditCreate, nil, advert)
+	})
+	if err != nil {
 		log.Printf("AddAdvert: Error adding advert: %v", err)
 		return fmt.Errorf("adding advert failed: %w", err)
 	}
 	return nil
 }
 
-func (s *advertService) RemoveAdvert(advertID int) error {
+func (s *advertService) RemoveAdvert(ctx context.Context, advertID int) error {
 	log.Printf("RemoveAdvert: Removing advert with ID %d.", advertID)
-	if err := s.repo.DeleteAdvert(advertID); err != nil {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetAdvertById(ctx, advertID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.DeleteAdvert(ctx, advertID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditAdvert, advertID, models.AuditDelete, before, nil)
+	})
+	if err != nil {
 		log.Printf("RemoveAdvert: Failed to remove advert with ID %d: %v", advertID, err)
 		return fmt.Errorf("failed to remove advert with id %d: %w", advertID, err)
 	}
 	return nil
 }
 
-func (s *advertService) UpdateAdvert(advertID int, campaignID int, progress *string, runDate *time.Time) error {
-	log.Printf("UpdateAdvert: Updating advert with ID %d.", advertID)
-	if err := s.repo.UpdateAdvert(advertID, campaignID, progress, runDate); err != nil {
-		log.Printf("UpdateAdvert: Failed to update advert with ID %d: %v", advertID, err)
-		return fmt.Errorf("failed to update advert with id %d: %w", advertID, err)
+// RestoreAdvert undoes RemoveAdvert. An advert of a deleted campaign cannot
+// be restored on its own; restore the campaign instead.
+func (s *advertService) RestoreAdvert(ctx context.Context, advertID int) (models.Advert, error) {
+	log.Printf("RestoreAdvert: Restoring advert with ID %d.", advertID)
+	var advert models.Advert
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if advert, err = s.repo.GetAdvertIncludingDeleted(ctx, advertID); err != nil {
+			return err
+		}
+		if advert.DeletedAt == nil {
+			return apperrors.Conflict("advert %d is not deleted", advertID)
+		}
+		_, err = s.campaignRepo.GetCampaignByID(ctx, advert.CampaignID)
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return apperrors.Conflict("campaign %d of advert %d is deleted; restore the campaign first", advert.CampaignID, advertID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if advert.Version, err = s.repo.RestoreAdvert(ctx, advertID); err != nil {
+			return err
+		}
+		advert.DeletedAt = nil
+		return s.audit.Record(ctx, models.AuditAdvert, advertID, models.AuditRestore, nil, advert)
+	})
+	if err != nil {
+		log.Printf("RestoreAdvert: Failed to restore advert with ID %d: %v", advertID, err)
+		return models.Advert{}, fmt.Errorf("failed to restore advert with id %d: %w", advertID, err)
+	}
+	return advert, nil
+}
+
+// UpdateAdvert replaces the advert's campaign, progress and run date with
+// those of advert if it is still at advert.Version. Unless mayReschedule is
+// set, only progress may change. On success advert holds the updated record.
+func (s *advertService) UpdateAdvert(ctx context.Context, advert *models.Advert, mayReschedule bool) error {
+	log.Printf("UpdateAdvert: Updating advert with ID %d.", advert.AdvertID)
+	updated, err := s.update(ctx, advert.AdvertID, advert.Version, func(before models.Advert) (models.Advert, error) {
+		rescheduled := !advert.RunDate.Equal(before.RunDate) || advert.CampaignID != before.CampaignID
+		if rescheduled && !mayReschedule {
+			log.Printf("UpdateAdvert: Advert ID %d would be rescheduled without %s", advert.AdvertID, models.PermManageAdverts)
+			return models.Advert{}, missingPermission(models.PermManageAdverts)
+		}
+		after := *advert
+		after.DeletedAt = before.DeletedAt
+		return after, nil
+	})
+	if err != nil {
+		log.Printf("UpdateAdvert: Failed to update advert with ID %d: %v", advert.AdvertID, err)
+		return fmt.Errorf("failed to update advert with id %d: %w", advert.AdvertID, err)
 	}
+	*advert = updated
 	return nil
 }
 
-func (s *advertService) GetAdvertsByCampaign(campaignID int) ([]models.Advert, error) {
+// PatchAdvert applies a merge patch to the advert if it is still at version
+// and returns the updated record.
+func (s *advertService) PatchAdvert(ctx context.Context, advertID, version int, patch models.MergePatch) (models.Advert, error) {
+	log.Printf("PatchAdvert: Patching advert with ID %d.", advertID)
+	advert, err := s.update(ctx, advertID, version, func(before models.Advert) (models.Advert, error) {
+		after := before
+		err := patch.Apply(&after, "advert_id", "version", "deleted_at")
+		return after, err
+	})
+	if err != nil {
+		log.Printf("PatchAdvert: Failed to patch advert with ID %d: %v", advertID, err)
+		return models.Advert{}, fmt.Errorf("failed to patch advert with id %d: %w", advertID, err)
+	}
+	return advert, nil
+}
+
+// update runs change against the advert as it is now, validates the result
+// and writes the fields that changed. An advert can only move to a campaign
+// that exists. Changing nothing is not recorded.
+func (s *advertService) update(ctx context.Context, advertID, version int, change func(before models.Advert) (models.Advert, error)) (models.Advert, error) {
+	var after models.Advert
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetAdvertById(ctx, advertID)
+		if err != nil {
+			return err
+		}
+		if version, err = repositories.RequireVersion("advert", advertID, before.Version, version); err != nil {
+			return err
+		}
+		if after, err = change(before); err != nil {
+			return err
+		}
+		after.AdvertID, after.Version = advertID, version
+		if after.CampaignID != before.CampaignID {
+			if err := s.requireCampaign(ctx, after.CampaignID); err != nil {
+				return err
+			}
+		}
+
+		if after.Version, err = s.repo.UpdateAdvert(ctx, version, before, after); err != nil {
+			return err
+		}
+		if after.Version == version {
+			return nil
+		}
+		return s.audit.Record(ctx, models.AuditAdvert, advertID, models.AuditUpdate, before, after)
+	})
+	return after, err
+}
+
+// requireCampaign fails validation unless the campaign exists and has not
+// been deleted.
+func (s *advertService) requireCampaign(ctx context.Context, campaignID int) error {
+	_, err := s.campaignRepo.GetCampaignByID(ctx, campaignID)
+	if apperrors.KindOf(err) == apperrors.KindNotFound {
+		return apperrors.Validation("campaign %d does not exist", campaignID)
+	}
+	return err
+}
+
+func (s *advertService) GetAdvertsByCampaign(ctx context.Context, campaignID int) ([]models.Advert, error) {
 	log.Printf("GetAdvertsByCampaign: Fetching adverts for campaign ID %d.", campaignID)
-	adverts, err := s.repo.GetAdvertsByCampaign(campaignID)
+	adverts, err := s.repo.GetAdvertsByCampaign(ctx, campaignID)
 	if err != nil {
 		log.Printf("GetAdvertsByCampaign: Failed to fetch adverts for campaign ID %d: %v", campaignID, err)
 		return nil, fmt.Errorf("fetching adverts for campaign id %d failed: %w", campaignID, err)
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/repositories"
+)
+
+// fakeAdverts holds a single advert, as it is in the database.
+type fakeAdverts struct {
+	repositories.AdvertRepository
+	stored  models.Advert
+	updates []models.Advert
+}
+
+func (r *fakeAdverts) GetAdvertById(_ context.Context, advertID int) (models.Advert, error) {
+	if advertID != r.stored.AdvertID {
+		return models.Advert{}, apperrors.NotFound("advert not found")
+	}
+	return r.stored, nil
+}
+
+func (r *fakeAdverts) UpdateAdvert(_ context.Context, version int, _, after models.Advert) (int, error) {
+	if version != r.stored.Version {
+		return 0, apperrors.PreconditionFailed("advert %d is not at version %d", after.AdvertID, version)
+	}
+	r.updates = append(r.updates, after)
+	r.stored = after
+	r.stored.Version = version + 1
+	return r.stored.Version, nil
+}
+
+func day(date string) time.Time {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func newAdvertFixture() (*fakeAdverts, AdvertService) {
+	repo := &fakeAdverts{stored: models.Advert{
+		AdvertID: 7, CampaignID: 3, Progress: models.ProgressPlanned, RunDate: day("2025-03-01"), Version: 2,
+	}}
+	return repo, NewAdvertService(repo, nil, fakeAudit{}, fakeUnitOfWork{})
+}
+
+func TestUpdateAdvertRejectsProgressUpdateOverConcurrentReschedule(t *testing.T) {
+	repo, service := newAdvertFixture()
+	stale := repo.stored
+
+	// A manager moves the run date after the creative read the advert.
+	rescheduled := repo.stored
+	rescheduled.RunDate = day("2025-03-15")
+	if err := service.UpdateAdvert(context.Background(), &rescheduled, true); err != nil {
+		t.Fatalf("reschedule: %v", err)
+	}
+
+	// The creative's replacement still carries the old run date.
+	progressed := stale
+	progressed.Progress = models.ProgressInProduction
+	progressed.Version = AnyVersion
+	err := service.UpdateAdvert(context.Background(), &progressed, false)
+	if apperrors.KindOf(err) != apperrors.KindForbidden {
+		t.Fatalf("UpdateAdvert over a reschedule = %v, want forbidden", err)
+	}
+	if !repo.stored.RunDate.Equal(day("2025-03-15")) || len(repo.updates) != 1 {
+		t.Errorf("stored run date = %s after %d updates, want the reschedule kept", repo.stored.RunDate.Format("2006-01-02"), len(repo.updates))
+	}
+}
+
+func TestUpdateAdvertLetsProgressMoveWithoutReschedule(t *testing.T) {
+	repo, service := newAdvertFixture()
+
+	progressed := repo.stored
+	progressed.Progress = models.ProgressInProduction
+	progressed.Version = AnyVersion
+	if err := service.UpdateAdvert(context.Background(), &progressed, false); err != nil {
+		t.Fatalf("UpdateAdvert = %v, want nil", err)
+	}
+	if repo.stored.Progress != models.ProgressInProduction || progressed.Version != 3 {
+		t.Errorf("stored %+v, returned version %d; want progress moved at version 3", repo.stored, progressed.Version)
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"agate-project/models"
+	"agate-project/repositories"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// AuditService keeps the audit log. Services record each change with the
+// context of the unit of work that makes it, so the change and its entry
+// are committed or rolled back together.
+type AuditService interface {
+	// Record logs a change to an entity. before and after are snapshots of
+	// it, encoded as JSON; before is nil for a create and after for a delete.
+	Record(ctx context.Context, entityType string, entityID int, action models.AuditAction, before, after any) error
+	GetAuditLog(ctx context.Context, opts models.ListOptions) (models.Page[models.AuditEntry], error)
+}
+
+type auditService struct {
+	repo repositories.AuditRepository
+}
+
+func NewAuditService(repo repositories.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+type actorKey struct{}
+
+// WithActor returns a context whose changes are recorded in the audit log as
+// made by staffID.
+func WithActor(ctx context.Context, staffID int) context.Context {
+	return context.WithValue(ctx, actorKey{}, staffID)
+}
+
+func actorFrom(ctx context.Context) *int {
+	if staffID, ok := ctx.Value(actorKey{}).(int); ok {
+		return &staffID
+	}
+	return nil
+}
+
+func (s *auditService) Record(ctx context.Context, entityType string, entityID int, action models.AuditAction, before, after any) error {
+	entry := models.AuditEntry{
+		ActorID:    actorFrom(ctx),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+	}
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return err
+	}
+	if action == models.AuditUpdate {
+		if entry.Before, entry.After, err = diff(entry.Before, entry.After); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.AddAuditEntry(ctx, &entry); err != nil {
+		log.Printf("Record: Error recording %s of %s %d: %v", action, entityType, entityID, err)
+		return fmt.Errorf("recording audit entry failed: %w", err)
+	}
+	return nil
+}
+
+func (s *auditService) GetAuditLog(ctx context.Context, opts models.ListOptions) (models.Page[models.AuditEntry], error) {
+	entries, total, err := s.repo.GetAuditEntries(ctx, opts)
+	if err != nil {
+		log.Printf("GetAuditLog: Error fetching audit entries: %v", err)
+		return models.Page[models.AuditEntry]{}, fmt.Errorf("fetching audit log failed: %w", err)
+	}
+	return models.NewPage(entries, total, opts), nil
+}
+
+func snapshot(value any) (models.JSON, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+	return encoded, nil
+}
+
+// diff reduces two snapshots of an object to the fields whose values differ.
+func diff(before, after models.JSON) (models.JSON, models.JSON, error) {
+	var from, to map[string]json.RawMessage
+	if err := json.Unmarshal(before, &from); err != nil {
+		return nil, nil, fmt.Errorf("failed to compare audit snapshots: %w", err)
+	}
+	if err := json.Unmarshal(after, &to); err != nil {
+		return nil, nil, fmt.Errorf("failed to compare audit snapshots: %w", err)
+	}
+	for field, value := range from {
+		if next, ok := to[field]; ok && bytes.Equal(value, next) {
+			delete(from, field)
+			delete(to, field)
+		}
+	}
+
+	changedBefore, err := json.Marshal(from)
+	if err != nil {
+		return nil, nil, err
+	}
+	changedAfter, err := json.Marshal(to)
+	if err != nil {
+		return nil, nil, err
+	}
+	return changedBefore, changedAfter, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minSigningKeySize = 32
+
+var ErrInvalidCredentials = apperrors.Unauthorized("invalid username or password")
+
+// AuthConfig holds the key used to sign tokens and how long they last.
+type AuthConfig struct {
+	SigningKey []byte
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func DefaultAuthConfig() AuthConfig {
+	return AuthConfig{
+		Issuer:     "agate",
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 30 * 24 * time.Hour,
+	}
+}
+
+func (c AuthConfig) Validate() error {
+	if len(c.SigningKey) < minSigningKeySize {
+		return fmt.Errorf("signing key must be at least %d bytes", minSigningKeySize)
+	}
+	if c.AccessTTL <= 0 || c.RefreshTTL <= 0 {
+		return fmt.Errorf("token lifetimes must be positive")
+	}
+	if c.AccessTTL > c.RefreshTTL {
+		return fmt.Errorf("access token lifetime %s is longer than refresh token lifetime %s", c.AccessTTL, c.RefreshTTL)
+	}
+	return nil
+}
+
+type AuthService interface {
+	Login(ctx context.Context, username, password string) (models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	Authenticate(ctx context.Context, accessToken string) (models.Principal, error)
+	SetCredentials(ctx context.Context, staffID int, username, password string) (models.StaffAccount, error)
+}
+
+type authService struct {
+	repo   repositories.AccountRepository
+	config AuthConfig
+	tokens tokenIssuer
+}
+
+func NewAuthService(repo repositories.AccountRepository, config AuthConfig) AuthService {
+	return &authService{
+		repo:   repo,
+		config: config,
+		tokens: tokenIssuer{config: config, audience: audienceStaff},
+	}
+}
+
+func (s *authService) Login(ctx context.Context, username, password string) (models.TokenPair, error) {
+	account, err := s.repo.GetAccountByUsername(ctx, strings.TrimSpace(username))
+	if err != nil {
+		if apperrors.KindOf(err) != apperrors.KindNotFound {
+			return models.TokenPair{}, fmt.Errorf("login failed: %w", err)
+		}
+		checkPassword("", password)
+		log.Printf("Login: Unknown username %q", username)
+		return models.TokenPair{}, ErrInvalidCredentials
+	}
+
+	if !checkPassword(account.PasswordHash, password) {
+		log.Printf("Login: Wrong password for staff ID %d", account.StaffID)
+		return models.TokenPair{}, ErrInvalidCredentials
+	}
+	if !account.Active {
+		log.Printf("Login: Staff ID %d is inactive", account.StaffID)
+		return models.TokenPair{}, ErrInvalidCredentials
+	}
+
+	session := s.newSession(account.StaffID)
+	if err := s.repo.CreateSession(ctx, &session); err != nil {
+		return models.TokenPair{}, fmt.Errorf("login failed: %w", err)
+	}
+	return s.issueTokens(account, session)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The old session is
+// revoked, so each refresh token works once.
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	claims, err := s.tokens.parse(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	staffID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return models.TokenPair{}, apperrors.Unauthorized("invalid token subject")
+	}
+
+	account, err := s.repo.GetAccountByStaffID(ctx, staffID)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return models.TokenPair{}, apperrors.Unauthorized("account no longer exists")
+		}
+		return models.TokenPair{}, fmt.Errorf("refresh failed: %w", err)
+	}
+	if !account.Active {
+		_ = s.repo.RevokeSession(ctx, claims.ID)
+		return models.TokenPair{}, apperrors.Unauthorized("staff member is inactive")
+	}
+
+	session := s.newSession(staffID)
+	if err := s.repo.RotateSession(ctx, claims.ID, &session); err != nil {
+		log.Printf("Refresh: Failed to rotate session for staff ID %d: %v", staffID, err)
+		return models.TokenPair{}, err
+	}
+	return s.issueTokens(account, session)
+}
+
+// Logout revokes the session behind a refresh token. Access tokens issued for
+// the same session stop working straight away.
+func (s *authService) Logout(ctx context.Context, refreshToken string) error {
+	claims, err := s.tokens.parse(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RevokeSession(ctx, claims.ID); err != nil {
+		return fmt.Errorf("logout failed: %w", err)
+	}
+	return nil
+}
+
+// Authenticate checks an access token and returns who it belongs to. The
+// account is reloaded on every request, so deactivation and role changes
+// apply before the token expires.
+func (s *authService) Authenticate(ctx context.Context, accessToken string) (models.Principal, error) {
+	claims, err := s.tokens.parse(accessToken, tokenTypeAccess)
+	if err != nil {
+		return models.Principal{}, err
+	}
+	staffID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return models.Principal{}, apperrors.Unauthorized("invalid token subject")
+	}
+
+	session, err := s.repo.GetSession(ctx, claims.ID)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return models.Principal{}, apperrors.Unauthorized("session not found")
+		}
+		return models.Principal{}, fmt.Errorf("authentication failed: %w", err)
+	}
+	if session.RevokedAt != nil || session.StaffID != staffID {
+		return models.Principal{}, apperrors.Unauthorized("session has been revoked")
+	}
+
+	// Rol ve aktiflik token'dan değil, hesabın güncel halinden okunur.
+	account, err := s.repo.GetAccountByStaffID(ctx, staffID)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return models.Principal{}, apperrors.Unauthorized("account no longer exists")
+		}
+		return models.Principal{}, fmt.Errorf("authentication failed: %w", err)
+	}
+	if !account.Active {
+		_ = s.repo.RevokeSession(ctx, session.SessionID)
+		return models.Principal{}, apperrors.Unauthorized("staff member is inactive")
+	}
+
+	return models.Principal{StaffID: staffID, Role: account.Role, SessionID: session.SessionID}, nil
+}
+
+// SetCredentials sets a staff member's username and password and signs out
+// every session opened with the old credentials.
+func (s *authService) SetCredentials(ctx context.Context, staffID int, username, password string) (models.StaffAccount, error) {
+	username = strings.TrimSpace(username)
+	if staffID <= 0 {
+		return models.StaffAccount{}, apperrors.BadRequest("invalid staff ID")
+	}
+	if username == "" {
+		return models.StaffAccount{}, apperrors.Validation("username is required")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return models.StaffAccount{}, err
+	}
+
+	account := models.StaffAccount{StaffID: staffID, Username: username, PasswordHash: hash}
+	if err := s.repo.SaveAccount(ctx, &account); err != nil {
+		log.Printf("SetCredentials: Failed to save account for staff ID %d: %v", staffID, err)
+		return models.StaffAccount{}, err
+	}
+	if err := s.repo.RevokeStaffSessions(ctx, staffID); err != nil {
+		log.Printf("SetCredentials: Failed to revoke sessions for staff ID %d: %v", staffID, err)
+		return models.StaffAccount{}, err
+	}
+	return account, nil
+}
+
+func (s *authService) newSession(staffID int) models.AuthSession {
+	return models.AuthSession{
+		SessionID: newSessionID(),
+		StaffID:   staffID,
+		ExpiresAt: time.Now().Add(s.config.RefreshTTL),
+	}
+}
+
+func (s *authService) issueTokens(account models.StaffAccount, session models.AuthSession) (models.TokenPair, error) {
+	claims := tokenClaims{Role: account.Role}
+	claims.Subject = strconv.Itoa(account.StaffID)
+	return s.tokens.issue(claims, session.SessionID, session.ExpiresAt)
+}
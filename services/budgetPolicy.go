@@ -0,0 +1,85 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"fmt"
+)
+
+var ErrBudgetExceeded = apperrors.Validation("campaign would exceed its approved budget")
+
+// BudgetThresholds are percentages of a campaign's approved budget. Spending
+// past Warning is flagged, spending past HardStop is rejected.
+type BudgetThresholds struct {
+	Warning  float64
+	HardStop float64
+}
+
+func DefaultBudgetThresholds() BudgetThresholds {
+	return BudgetThresholds{Warning: 80, HardStop: 100}
+}
+
+func (t BudgetThresholds) Validate() error {
+	if t.Warning <= 0 || t.HardStop <= 0 {
+		return fmt.Errorf("budget thresholds must be positive")
+	}
+	if t.Warning > t.HardStop {
+		return fmt.Errorf("budget warning threshold %.2f%% is above hard stop %.2f%%", t.Warning, t.HardStop)
+	}
+	return nil
+}
+
+// Evaluate fills in the derived figures of a campaign budget.
+// A campaign without an approved budget (0) is never flagged.
+func (t BudgetThresholds) Evaluate(budget models.CampaignBudget) models.CampaignBudget {
+	budget.ApplyCurrency()
+	budget.Remaining = models.NewMoney(budget.Budget.Amount-budget.ActualCost.Amount, budget.Currency)
+	budget.PercentBurned = 0
+	budget.Status = models.BudgetOK
+	if budget.Budget.IsZero() {
+		return budget
+	}
+
+	budget.PercentBurned = budget.ActualCost.Percent(budget.Budget)
+	if hardStop, err := budget.Budget.Percentage(t.HardStop); err == nil && budget.ActualCost.Amount > hardStop.Amount {
+		budget.Status = models.BudgetExceeded
+	} else if warning, err := budget.Budget.Percentage(t.Warning); err == nil && budget.ActualCost.Amount >= warning.Amount {
+		budget.Status = models.BudgetWarning
+	}
+	return budget
+}
+
+// Check rejects estimated or actual costs past the hard stop threshold. The
+// limit is the threshold share of the budget rounded to the nearest minor
+// unit, half away from zero.
+func (t BudgetThresholds) Check(budget, estimatedCost, actualCost models.Money) error {
+	if budget.IsZero() {
+		return nil
+	}
+
+	limit, err := budget.Percentage(t.HardStop)
+	if err != nil {
+		return err
+	}
+	if cmp, err := actualCost.Cmp(limit); err != nil {
+		return err
+	} else if cmp > 0 {
+		return fmt.Errorf("%w: actual cost %s is over the limit of %s %s", ErrBudgetExceeded, actualCost, limit, limit.Currency)
+	}
+	if cmp, err := estimatedCost.Cmp(limit); err != nil {
+		return err
+	} else if cmp > 0 {
+		return fmt.Errorf("%w: estimated cost %s is over the limit of %s %s", ErrBudgetExceeded, estimatedCost, limit, limit.Currency)
+	}
+	return nil
+}
+
+// validateAmounts rejects negative amounts and bad currency codes.
+func validateAmounts(amounts ...models.Money) error {
+	for _, amount := range amounts {
+		if err := amount.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"agate-project/models"
+)
+
+func TestCheckRoundsHardStopToNearestMinorUnit(t *testing.T) {
+	// 50% of 10.01 is 5.005, so the limit rounds up to 5.01.
+	thresholds := BudgetThresholds{Warning: 40, HardStop: 50}
+	budget := models.NewMoney(1001, "GBP")
+
+	if err := thresholds.Check(budget, models.NewMoney(0, "GBP"), models.NewMoney(501, "GBP")); err != nil {
+		t.Errorf("actual cost at the limit: %v, want nil", err)
+	}
+	if err := thresholds.Check(budget, models.NewMoney(0, "GBP"), models.NewMoney(502, "GBP")); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("actual cost one unit past the limit: %v, want ErrBudgetExceeded", err)
+	}
+	if err := thresholds.Check(budget, models.NewMoney(502, "GBP"), models.NewMoney(0, "GBP")); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("estimated cost one unit past the limit: %v, want ErrBudgetExceeded", err)
+	}
+
+	evaluated := thresholds.Evaluate(models.CampaignBudget{Budget: budget, ActualCost: models.NewMoney(501, "GBP"), Currency: "GBP"})
+	if evaluated.Status != models.BudgetWarning {
+		t.Errorf("status at the limit = %s, want %s", evaluated.Status, models.BudgetWarning)
+	}
+}
+
+func TestCheckKeepsLargeBudgetsExact(t *testing.T) {
+	// 2^53 + 1 minor units has no exact float64.
+	budget := models.NewMoney(1<<53+1, "GBP")
+	thresholds := BudgetThresholds{Warning: 80, HardStop: 100}
+
+	if err := thresholds.Check(budget, budget, budget); err != nil {
+		t.Errorf("spending the whole budget: %v, want nil", err)
+	}
+	over := models.NewMoney(1<<53+2, "GBP")
+	if err := thresholds.Check(budget, budget, over); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("spending one unit more: %v, want ErrBudgetExceeded", err)
+	}
+}
+
+func TestCheckTakesFractionalPercentagesExactly(t *testing.T) {
+	// 33.3% of 1000.00 is exactly 333.00.
+	thresholds := BudgetThresholds{Warning: 20, HardStop: 33.3}
+	budget := models.NewMoney(100000, "GBP")
+
+	if err := thresholds.Check(budget, models.NewMoney(0, "GBP"), models.NewMoney(33300, "GBP")); err != nil {
+		t.Errorf("actual cost at the limit: %v, want nil", err)
+	}
+	if err := thresholds.Check(budget, models.NewMoney(0, "GBP"), models.NewMoney(33301, "GBP")); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("actual cost one unit past the limit: %v, want ErrBudgetExceeded", err)
+	}
+}
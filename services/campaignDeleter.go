@@ -0,0 +1,39 @@
+package services
+
+import (
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+)
+
+// campaignDeleter permanently deletes a campaign together with the records
+// that refer to it. It does not start a transaction; callers run it inside a
+// unit of work so a failure part way leaves nothing deleted.
+type campaignDeleter struct {
+	campaignRepo      repositories.CampaignRepository
+	advertRepo        repositories.AdvertRepository
+	costEntryRepo     repositories.CostEntryRepository
+	timesheetRepo     repositories.TimesheetRepository
+	campaignStaffRepo repositories.CampaignStaffRepository
+}
+
+// deleteCampaign removes dependents before the campaign. Cost entries go
+// before adverts because they may refer to one.
+func (d campaignDeleter) deleteCampaign(ctx context.Context, campaignID int) error {
+	log.Printf("deleteCampaign: Deleting campaign ID %d and its records.", campaignID)
+	steps := []func(context.Context, int) error{
+		d.costEntryRepo.DeleteCostEntriesByCampaign,
+		d.timesheetRepo.DeleteTimesheetsByCampaign,
+		d.campaignStaffRepo.RemoveAssignmentsByCampaign,
+		d.advertRepo.PurgeAdvertsByCampaign,
+		d.campaignRepo.DeleteCampaignHistory,
+		d.campaignRepo.PurgeCampaign,
+	}
+	for _, step := range steps {
+		if err := step(ctx, campaignID); err != nil {
+			return fmt.Errorf("failed to delete campaign %d: %w", campaignID, err)
+		}
+	}
+	return nil
+}
@@ -1,53 +1,139 @@
 package services
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"agate-project/repositories"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 )
 
+var ErrInvalidManager = apperrors.Validation("invalid campaign manager")
+
 type CampaignService interface {
-	CreateCampaign(campaign models.Campaign) error
-	GetCampaignByID(campaignID int) (models.Campaign, error)
-	UpdateCampaign(campaign models.Campaign) error
-	RemoveCampaign(campaignID int) error
-	AssignManager(campaignID, managerID int) error
-	FetchAllCampaigns() ([]models.Campaign, error)
-	//CheckBudget(campaignID int) (float64, error)
-	GetCampaignsByClientID(clientID int) ([]models.Campaign, error)
+	CreateCampaign(ctx context.Context, campaign *models.Campaign, adverts []models.Advert) error
+	GetCampaignByID(ctx context.Context, campaignID int, includeDeleted bool) (models.Campaign, error)
+	UpdateCampaign(ctx context.Context, campaign *models.Campaign) error
+	PatchCampaign(ctx context.Context, campaignID, version int, patch models.MergePatch) (models.Campaign, error)
+	RemoveCampaign(ctx context.Context, campaignID int) error
+	RestoreCampaign(ctx context.Context, campaignID int) (models.Campaign, error)
+	AssignManager(ctx context.Context, campaignID, managerID int) (models.CampaignManagerAssignment, error)
+	GetManagerHistory(ctx context.Context, campaignID int) ([]models.CampaignManagerAssignment, error)
+	FetchAllCampaigns(ctx context.Context, opts models.ListOptions) (models.Page[models.Campaign], error)
+	CheckBudget(ctx context.Context, campaignID int) (models.CampaignBudget, error)
+	GetCampaignsByClientID(ctx context.Context, clientID int) ([]models.Campaign, error)
+	TransitionCampaign(ctx context.Context, campaignID int, to models.CampaignState, changedBy int) (models.CampaignStateHistory, error)
+	GetCampaignHistory(ctx context.Context, campaignID int) ([]models.CampaignStateHistory, error)
 }
 
 type campaignService struct {
-	repo repositories.CampaignRepository
+	repo        repositories.CampaignRepository
+	clientRepo  repositories.ClientRepository
+	managerRepo repositories.CampaignManagerRepository
+	staffRepo   repositories.StaffRepository
+	advertRepo  repositories.AdvertRepository
+	trash       softDeleter
+	audit       AuditService
+	uow         repositories.UnitOfWork
+	thresholds  BudgetThresholds
 }
 
-func NewCampaignService(repo repositories.CampaignRepository) CampaignService {
-	return &campaignService{repo: repo}
+func NewCampaignService(repo repositories.CampaignRepository, clientRepo repositories.ClientRepository, managerRepo repositories.CampaignManagerRepository, staffRepo repositories.StaffRepository, advertRepo repositories.AdvertRepository, audit AuditService, uow repositories.UnitOfWork, thresholds BudgetThresholds) CampaignService {
+	return &campaignService{
+		repo:        repo,
+		clientRepo:  clientRepo,
+		managerRepo: managerRepo,
+		staffRepo:   staffRepo,
+		advertRepo:  advertRepo,
+		trash:       softDeleter{campaignRepo: repo, advertRepo: advertRepo, audit: audit},
+		audit:       audit,
+		uow:         uow,
+		thresholds:  thresholds,
+	}
 }
 
-func (s *campaignService) CreateCampaign(campaign models.Campaign) error {
+// CreateCampaign creates a campaign together with its initial adverts, if
+// any. Either the campaign and all of its adverts are created or nothing is.
+// On success campaign and adverts carry their new IDs. New campaigns are
+// not started; later states are reached only through transitions.
+func (s *campaignService) CreateCampaign(ctx context.Context, campaign *models.Campaign, adverts []models.Advert) error {
 	log.Println("CreateCampaign: Attempting to create a new campaign.")
-	if err := s.repo.CreateCampaign(campaign); err != nil {
+	if campaign.CurrentState == "" {
+		campaign.CurrentState = models.StateNotStarted
+	}
+	if campaign.CurrentState != models.StateNotStarted {
+		log.Printf("CreateCampaign: Refusing to create a campaign in state %q.", campaign.CurrentState)
+		return apperrors.InvalidFields([]apperrors.FieldError{
+			{Field: "current_state", Message: fmt.Sprintf("must be %q for a new campaign", models.StateNotStarted)},
+		})
+	}
+	campaign.CompletionStatus = completionStatusFor(campaign.CurrentState)
+	// actual cost her zaman maliyet kayıtlarının toplamıdır
+	campaign.ActualCost = models.Money{}
+
+	if err := campaign.ResolveCurrency(); err != nil {
+		log.Printf("CreateCampaign: Invalid campaign amounts: %v", err)
+		return err
+	}
+	if err := validateAmounts(campaign.Budget, campaign.EstimatedCost); err != nil {
+		log.Printf("CreateCampaign: Invalid campaign amounts: %v", err)
+		return err
+	}
+
+	if err := s.thresholds.Check(campaign.Budget, campaign.EstimatedCost, campaign.ActualCost); err != nil {
+		log.Printf("CreateCampaign: Budget check failed: %v", err)
+		return err
+	}
+
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.requireClient(ctx, campaign.ClientID); err != nil {
+			return err
+		}
+		if err := s.repo.CreateCampaign(ctx, campaign); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditCampaign, campaign.CampaignID, models.AuditCreate, nil, campaign); err != nil {
+			return err
+		}
+		for i := range adverts {
+			adverts[i].CampaignID = campaign.CampaignID
+			if err := s.advertRepo.AddAdvert(ctx, &adverts[i]); err != nil {
+				return err
+			}
+			if err := s.audit.Record(ctx, models.AuditAdvert, adverts[i].AdvertID, models.AuditCreate, nil, adverts[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		log.Printf("CreateCampaign: Error creating campaign: %v", err)
 		return fmt.Errorf("failed to create campaign: %w", err)
 	}
 	return nil
 }
 
-func (s *campaignService) FetchAllCampaigns() ([]models.Campaign, error) {
-	log.Println("FetchAllCampaigns: Fetching all campaigns.")
-	campaigns, err := s.repo.GetAllCampaigns()
+func (s *campaignService) FetchAllCampaigns(ctx context.Context, opts models.ListOptions) (models.Page[models.Campaign], error) {
+	log.Println("FetchAllCampaigns: Fetching campaigns.")
+	campaigns, total, err := s.repo.GetAllCampaigns(ctx, opts)
 	if err != nil {
 		log.Printf("FetchAllCampaigns: Error fetching campaigns: %v", err)
-		return nil, fmt.Errorf("fetching campaigns failed: %w", err)
+		return models.Page[models.Campaign]{}, fmt.Errorf("fetching campaigns failed: %w", err)
 	}
-	return campaigns, nil
+	return models.NewPage(campaigns, total, opts), nil
 }
 
-func (s *campaignService) GetCampaignByID(campaignID int) (models.Campaign, error) {
+// GetCampaignByID returns the campaign, or not found if it has been deleted
+// unless includeDeleted is set.
+func (s *campaignService) GetCampaignByID(ctx context.Context, campaignID int, includeDeleted bool) (models.Campaign, error) {
 	log.Printf("GetCampaignByID: Fetching campaign with ID %d.", campaignID)
-	campaign, err := s.repo.GetCampaignByID(campaignID)
+	get := s.repo.GetCampaignByID
+	if includeDeleted {
+		get = s.repo.GetCampaignIncludingDeleted
+	}
+	campaign, err := get(ctx, campaignID)
 	if err != nil {
 		log.Printf("GetCampaignByID: Error fetching campaign by ID: %v", err)
 		return campaign, fmt.Errorf("failed to fetch campaign by ID: %w", err)
@@ -55,50 +141,309 @@ func (s *campaignService) GetCampaignByID(campaignID int) (models.Campaign, erro
 	return campaign, nil
 }
 
-func (s *campaignService) UpdateCampaign(campaign models.Campaign) error {
+// UpdateCampaign replaces the campaign's details with those of campaign if
+// it is still at campaign.Version. Its state, manager and actual cost are
+// kept. On success campaign holds the updated record.
+func (s *campaignService) UpdateCampaign(ctx context.Context, campaign *models.Campaign) error {
 	log.Printf("UpdateCampaign: Attempting to update campaign with ID %d.", campaign.CampaignID)
-	if err := s.repo.UpdateCampaign(campaign); err != nil {
+	updated, err := s.update(ctx, campaign.CampaignID, campaign.Version, func(before models.Campaign) (models.Campaign, error) {
+		after := *campaign
+		after.ActualCost = before.ActualCost
+		after.CompletionStatus = before.CompletionStatus
+		after.CurrentState = before.CurrentState
+		after.ManagerID = before.ManagerID
+		after.DeletedAt = before.DeletedAt
+		return after, nil
+	})
+	if err != nil {
 		log.Printf("UpdateCampaign: Error updating campaign with ID %d: %v", campaign.CampaignID, err)
 		return fmt.Errorf("failed to update campaign: %w", err)
 	}
+	*campaign = updated
 	return nil
 }
 
-func (s *campaignService) RemoveCampaign(campaignID int) error {
+// PatchCampaign applies a merge patch to the campaign if it is still at
+// version and returns the updated record.
+func (s *campaignService) PatchCampaign(ctx context.Context, campaignID, version int, patch models.MergePatch) (models.Campaign, error) {
+	log.Printf("PatchCampaign: Attempting to patch campaign with ID %d.", campaignID)
+	campaign, err := s.update(ctx, campaignID, version, func(before models.Campaign) (models.Campaign, error) {
+		after := before
+		err := patch.Apply(&after, "campaign_id", "actual_cost", "completion_status", "current_state", "manager_id", "version", "deleted_at")
+		return after, err
+	})
+	if err != nil {
+		log.Printf("PatchCampaign: Error patching campaign with ID %d: %v", campaignID, err)
+		return models.Campaign{}, fmt.Errorf("failed to patch campaign: %w", err)
+	}
+	return campaign, nil
+}
+
+// update runs change against the campaign as it is now, validates the result
+// and writes the fields that changed. Amounts without a currency take the
+// campaign's. Changing nothing is not recorded.
+func (s *campaignService) update(ctx context.Context, campaignID, version int, change func(before models.Campaign) (models.Campaign, error)) (models.Campaign, error) {
+	var after models.Campaign
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetCampaignByID(ctx, campaignID)
+		if err != nil {
+			return err
+		}
+		if version, err = repositories.RequireVersion("campaign", campaignID, before.Version, version); err != nil {
+			return err
+		}
+		if after, err = change(before); err != nil {
+			return err
+		}
+		after.CampaignID, after.Version, after.Currency = campaignID, version, before.Currency
+		if err := s.validateCampaign(&after); err != nil {
+			return err
+		}
+		if err := s.requireClient(ctx, after.ClientID); err != nil {
+			return err
+		}
+
+		if after.Version, err = s.repo.UpdateCampaign(ctx, version, before, after); err != nil {
+			return err
+		}
+		if after.Version == version {
+			return nil
+		}
+		return s.audit.Record(ctx, models.AuditCampaign, campaignID, models.AuditUpdate, before, after)
+	})
+	return after, err
+}
+
+// validateCampaign checks the campaign's amounts against each other and the
+// budget thresholds and settles the currency of its amounts.
+func (s *campaignService) validateCampaign(campaign *models.Campaign) error {
+	if err := campaign.ResolveCurrency(); err != nil {
+		return err
+	}
+	if err := validateAmounts(campaign.Budget, campaign.EstimatedCost); err != nil {
+		return err
+	}
+	return s.thresholds.Check(campaign.Budget, campaign.EstimatedCost, campaign.ActualCost)
+}
+
+// RemoveCampaign soft-deletes a campaign and its adverts. Its costs,
+// timesheets, staff and history are kept until the campaign is purged.
+func (s *campaignService) RemoveCampaign(ctx context.Context, campaignID int) error {
 	log.Printf("RemoveCampaign: Removing campaign with ID %d.", campaignID)
-	if err := s.repo.DeleteCampaign(campaignID); err != nil {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		campaign, err := s.repo.GetCampaignForUpdate(ctx, campaignID)
+		if err != nil {
+			return err
+		}
+		return s.trash.deleteCampaign(ctx, campaign)
+	})
+	if err != nil {
 		log.Printf("RemoveCampaign: Failed to remove campaign with ID %d: %v", campaignID, err)
 		return fmt.Errorf("failed to remove campaign with id %d: %w", campaignID, err)
 	}
 	return nil
 }
 
-func (s *campaignService) AssignManager(campaignID, managerID int) error {
+// RestoreCampaign undoes RemoveCampaign, bringing back the adverts deleted
+// with the campaign. A campaign of a deleted client cannot be restored on
+// its own; restore the client instead.
+func (s *campaignService) RestoreCampaign(ctx context.Context, campaignID int) (models.Campaign, error) {
+	log.Printf("RestoreCampaign: Restoring campaign with ID %d.", campaignID)
+	var campaign models.Campaign
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		deleted, err := s.repo.GetCampaignIncludingDeleted(ctx, campaignID)
+		if err != nil {
+			return err
+		}
+		if deleted.DeletedAt == nil {
+			return apperrors.Conflict("campaign %d is not deleted", campaignID)
+		}
+		_, err = s.clientRepo.GetClientByID(ctx, deleted.ClientID)
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return apperrors.Conflict("client %d of campaign %d is deleted; restore the client first", deleted.ClientID, campaignID)
+		}
+		if err != nil {
+			return err
+		}
+		campaign, err = s.trash.restoreCampaign(ctx, deleted)
+		return err
+	})
+	if err != nil {
+		log.Printf("RestoreCampaign: Failed to restore campaign with ID %d: %v", campaignID, err)
+		return models.Campaign{}, fmt.Errorf("failed to restore campaign with id %d: %w", campaignID, err)
+	}
+	return campaign, nil
+}
+
+// requireClient fails validation unless the client exists and has not been
+// deleted.
+func (s *campaignService) requireClient(ctx context.Context, clientID int) error {
+	_, err := s.clientRepo.GetClientByID(ctx, clientID)
+	if apperrors.KindOf(err) == apperrors.KindNotFound {
+		log.Printf("requireClient: Client with ID %d does not exist.", clientID)
+		return apperrors.Validation("client %d does not exist", clientID)
+	}
+	return err
+}
+
+// AssignManager assigns a manager to a campaign. The campaign must exist and
+// the manager must be a campaign manager whose staff record is active
+func (s *campaignService) AssignManager(ctx context.Context, campaignID, managerID int) (models.CampaignManagerAssignment, error) {
 	log.Printf("AssignManager: Assigning manager with ID %d to campaign with ID %d.", managerID, campaignID)
-	if err := s.repo.AssignManager(campaignID, managerID); err != nil {
-		log.Printf("AssignManager: Error assigning manager with ID %d to campaign with ID %d: %v", managerID, campaignID, err)
-		return fmt.Errorf("failed to assign manager to campaign: %w", err)
+	var assignment models.CampaignManagerAssignment
+	// Kampanya satırı kilitli tutulur; eşzamanlı atamalar sırayla kontrol edilir.
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		campaign, err := s.repo.GetCampaignForUpdate(ctx, campaignID)
+		if err != nil {
+			log.Printf("AssignManager: Error fetching campaign with ID %d: %v", campaignID, err)
+			return fmt.Errorf("failed to fetch campaign by ID: %w", err)
+		}
+
+		manager, err := s.managerRepo.GetCampaignManagerByID(ctx, managerID)
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			log.Printf("AssignManager: Manager with ID %d does not exist.", managerID)
+			return fmt.Errorf("%w: manager %d does not exist", ErrInvalidManager, managerID)
+		}
+		if err != nil {
+			log.Printf("AssignManager: Error fetching manager with ID %d: %v", managerID, err)
+			return fmt.Errorf("failed to fetch manager: %w", err)
+		}
+
+		staff, err := s.staffRepo.GetStaffByID(ctx, manager.StaffID)
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			log.Printf("AssignManager: Staff record %d of manager with ID %d does not exist.", manager.StaffID, managerID)
+			return fmt.Errorf("%w: manager %d has no staff record", ErrInvalidManager, managerID)
+		}
+		if err != nil {
+			log.Printf("AssignManager: Error fetching staff with ID %d: %v", manager.StaffID, err)
+			return fmt.Errorf("failed to fetch manager staff record: %w", err)
+		}
+		if !staff.Active {
+			log.Printf("AssignManager: Staff record %d of manager with ID %d is inactive.", manager.StaffID, managerID)
+			return fmt.Errorf("%w: manager %d is inactive", ErrInvalidManager, managerID)
+		}
+
+		if campaign.ManagerID == managerID {
+			log.Printf("AssignManager: Manager with ID %d already manages campaign with ID %d.", managerID, campaignID)
+			return fmt.Errorf("%w: manager %d already manages campaign %d", ErrInvalidManager, managerID, campaignID)
+		}
+
+		assignment, err = s.repo.AssignManager(ctx, campaignID, managerID)
+		if err != nil {
+			log.Printf("AssignManager: Error assigning manager with ID %d to campaign with ID %d: %v", managerID, campaignID, err)
+			return fmt.Errorf("failed to assign manager to campaign: %w", err)
+		}
+		updated, err := s.repo.GetCampaignByID(ctx, campaignID)
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditCampaign, campaignID, models.AuditUpdate, campaign, updated)
+	})
+	if err != nil {
+		return models.CampaignManagerAssignment{}, err
 	}
-	return nil
+	return assignment, nil
 }
 
-// CheckBudget checks the budget of a campaign
-/*func (s *campaignService) CheckBudget(campaignID int) (float64, error) {
-	budgetDifference, err := s.repo.CheckBudget(campaignID)
+// GetManagerHistory fetches every manager change recorded for a campaign
+func (s *campaignService) GetManagerHistory(ctx context.Context, campaignID int) ([]models.CampaignManagerAssignment, error) {
+	log.Printf("GetManagerHistory: Fetching manager history for campaign ID %d.", campaignID)
+	if _, err := s.repo.GetCampaignByID(ctx, campaignID); err != nil {
+		log.Printf("GetManagerHistory: Error fetching campaign with ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to fetch campaign by ID: %w", err)
+	}
+
+	history, err := s.repo.GetManagerHistory(ctx, campaignID)
+	if err != nil {
+		log.Printf("GetManagerHistory: Error fetching manager history for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to fetch manager history: %w", err)
+	}
+	return history, nil
+}
+
+// CheckBudget reports how much of its approved budget a campaign has used
+func (s *campaignService) CheckBudget(ctx context.Context, campaignID int) (models.CampaignBudget, error) {
+	log.Printf("CheckBudget: Checking budget for campaign with ID %d.", campaignID)
+	budget, err := s.repo.CheckBudget(ctx, campaignID)
 	if err != nil {
-		log.Printf("Error checking budget for campaign: %v", err)
-		return 0, fmt.Errorf("failed to check budget for campaign: %w", err)
+		log.Printf("CheckBudget: Error checking budget for campaign with ID %d: %v", campaignID, err)
+		return budget, fmt.Errorf("failed to check budget for campaign: %w", err)
+	}
+
+	budget = s.thresholds.Evaluate(budget)
+	if budget.Status != models.BudgetOK {
+		log.Printf("CheckBudget: Campaign with ID %d has used %.2f%% of its budget.", campaignID, budget.PercentBurned)
 	}
-	return budgetDifference, nil
-}*/
+	return budget, nil
+}
 
 // GetCampaignsByClientID fetches all campaigns for a specific client
-func (s *campaignService) GetCampaignsByClientID(clientID int) ([]models.Campaign, error) {
+func (s *campaignService) GetCampaignsByClientID(ctx context.Context, clientID int) ([]models.Campaign, error) {
 	log.Printf("GetCampaignsByClientID: Fetching campaigns for client ID %d.", clientID)
-	campaigns, err := s.repo.GetCampaignsByClientID(clientID)
+	campaigns, err := s.repo.GetCampaignsByClientID(ctx, clientID)
 	if err != nil {
 		log.Printf("GetCampaignsByClientID: Error fetching campaigns for client ID %d: %v", clientID, err)
 		return nil, fmt.Errorf("failed to fetch campaigns for client ID %d: %w", clientID, err)
 	}
 	return campaigns, nil
 }
+
+// TransitionCampaign moves a campaign to a new state if the state machine allows it
+func (s *campaignService) TransitionCampaign(ctx context.Context, campaignID int, to models.CampaignState, changedBy int) (models.CampaignStateHistory, error) {
+	log.Printf("TransitionCampaign: Moving campaign with ID %d to %q.", campaignID, to)
+	if !isKnownCampaignState(to) {
+		log.Printf("TransitionCampaign: Unknown campaign state %q.", to)
+		return models.CampaignStateHistory{}, fmt.Errorf("%w: %q", ErrUnknownCampaignState, to)
+	}
+
+	var history models.CampaignStateHistory
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		campaign, err := s.repo.GetCampaignByID(ctx, campaignID)
+		if err != nil {
+			log.Printf("TransitionCampaign: Error fetching campaign with ID %d: %v", campaignID, err)
+			return fmt.Errorf("failed to fetch campaign by ID: %w", err)
+		}
+
+		from := campaign.CurrentState
+		if !canTransition(from, to) {
+			log.Printf("TransitionCampaign: Campaign with ID %d cannot move from %q to %q.", campaignID, from, to)
+			return fmt.Errorf("%w: %q to %q", ErrInvalidStateTransition, from, to)
+		}
+
+		history, err = s.repo.TransitionCampaignState(ctx, campaignID, from, to, completionStatusFor(to), changedBy)
+		if errors.Is(err, repositories.ErrCampaignStateChanged) {
+			log.Printf("TransitionCampaign: Campaign with ID %d changed state during transition.", campaignID)
+			return fmt.Errorf("%w: %v", ErrInvalidStateTransition, err)
+		}
+		if err != nil {
+			log.Printf("TransitionCampaign: Error moving campaign with ID %d to %q: %v", campaignID, to, err)
+			return fmt.Errorf("failed to transition campaign: %w", err)
+		}
+
+		updated, err := s.repo.GetCampaignByID(ctx, campaignID)
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditCampaign, campaignID, models.AuditUpdate, campaign, updated)
+	})
+	if err != nil {
+		return models.CampaignStateHistory{}, err
+	}
+	return history, nil
+}
+
+// GetCampaignHistory fetches the state changes recorded for a campaign
+func (s *campaignService) GetCampaignHistory(ctx context.Context, campaignID int) ([]models.CampaignStateHistory, error) {
+	log.Printf("GetCampaignHistory: Fetching state history for campaign ID %d.", campaignID)
+	if _, err := s.repo.GetCampaignByID(ctx, campaignID); err != nil {
+		log.Printf("GetCampaignHistory: Error fetching campaign with ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to fetch campaign by ID: %w", err)
+	}
+
+	history, err := s.repo.GetCampaignStateHistory(ctx, campaignID)
+	if err != nil {
+		log.Printf("GetCampaignHistory: Error fetching state history for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to fetch campaign history: %w", err)
+	}
+	return history, nil
+}
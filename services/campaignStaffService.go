@@ -0,0 +1,124 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+)
+
+var (
+	ErrAlreadyAssigned = apperrors.Conflict("staff member is already assigned to this campaign")
+	ErrStaffOverbooked = apperrors.Conflict("staff member is at their concurrent campaign limit")
+	ErrCampaignClosed  = apperrors.Conflict("campaign is completed or cancelled")
+	ErrStaffInactive   = apperrors.Validation("staff member is inactive")
+)
+
+// DefaultMaxConcurrentCampaigns is how many open campaigns a staff member
+// may be assigned to at once unless configured otherwise.
+const DefaultMaxConcurrentCampaigns = 5
+
+type CampaignStaffService interface {
+	AssignStaff(ctx context.Context, assignment *models.CampaignStaff) error
+	RemoveStaff(ctx context.Context, campaignID, staffID int) error
+	GetCampaignStaff(ctx context.Context, campaignID int) ([]models.CampaignStaff, error)
+}
+
+type campaignStaffService struct {
+	repo          repositories.CampaignStaffRepository
+	campaignRepo  repositories.CampaignRepository
+	staffRepo     repositories.StaffRepository
+	uow           repositories.UnitOfWork
+	maxConcurrent int
+}
+
+func NewCampaignStaffService(repo repositories.CampaignStaffRepository, campaignRepo repositories.CampaignRepository, staffRepo repositories.StaffRepository, uow repositories.UnitOfWork, maxConcurrent int) CampaignStaffService {
+	return &campaignStaffService{
+		repo:          repo,
+		campaignRepo:  campaignRepo,
+		staffRepo:     staffRepo,
+		uow:           uow,
+		maxConcurrent: maxConcurrent,
+	}
+}
+
+// AssignStaff puts an active staff member on a campaign in the given role,
+// refusing if they are already on as many open campaigns as they are
+// allowed. The staff member is locked while their assignments are counted,
+// so concurrent assignments cannot overbook them.
+func (s *campaignStaffService) AssignStaff(ctx context.Context, assignment *models.CampaignStaff) error {
+	log.Printf("AssignStaff: Assigning staff ID %d to campaign ID %d as %q.", assignment.StaffID, assignment.CampaignID, assignment.Role)
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		return s.assignStaff(ctx, assignment)
+	})
+}
+
+func (s *campaignStaffService) assignStaff(ctx context.Context, assignment *models.CampaignStaff) error {
+	campaign, err := s.campaignRepo.GetCampaignByID(ctx, assignment.CampaignID)
+	if err != nil {
+		log.Printf("AssignStaff: Error fetching campaign with ID %d: %v", assignment.CampaignID, err)
+		return fmt.Errorf("failed to fetch campaign: %w", err)
+	}
+	if campaign.CurrentState == models.StateCompleted || campaign.CurrentState == models.StateCancelled {
+		log.Printf("AssignStaff: Campaign with ID %d is %s.", assignment.CampaignID, campaign.CurrentState)
+		return fmt.Errorf("%w: campaign %d is %s", ErrCampaignClosed, assignment.CampaignID, campaign.CurrentState)
+	}
+
+	staff, err := s.staffRepo.GetStaffForUpdate(ctx, assignment.StaffID)
+	if err != nil {
+		log.Printf("AssignStaff: Error fetching staff with ID %d: %v", assignment.StaffID, err)
+		return fmt.Errorf("failed to fetch staff: %w", err)
+	}
+	if !staff.Active {
+		log.Printf("AssignStaff: Staff ID %d is inactive.", assignment.StaffID)
+		return fmt.Errorf("%w: staff %d", ErrStaffInactive, assignment.StaffID)
+	}
+
+	exists, err := s.repo.AssignmentExists(ctx, assignment.CampaignID, assignment.StaffID)
+	if err != nil {
+		return fmt.Errorf("failed to check existing assignment: %w", err)
+	}
+	if exists {
+		log.Printf("AssignStaff: Staff ID %d is already on campaign ID %d.", assignment.StaffID, assignment.CampaignID)
+		return ErrAlreadyAssigned
+	}
+
+	active, err := s.repo.CountActiveAssignments(ctx, assignment.StaffID)
+	if err != nil {
+		return fmt.Errorf("failed to check staff capacity: %w", err)
+	}
+	if active >= s.maxConcurrent {
+		log.Printf("AssignStaff: Staff ID %d is already on %d open campaigns.", assignment.StaffID, active)
+		return fmt.Errorf("%w: %d of %d", ErrStaffOverbooked, active, s.maxConcurrent)
+	}
+
+	if err := s.repo.AddAssignment(ctx, assignment); err != nil {
+		log.Printf("AssignStaff: Error assigning staff: %v", err)
+		return fmt.Errorf("assigning staff failed: %w", err)
+	}
+	return nil
+}
+
+func (s *campaignStaffService) RemoveStaff(ctx context.Context, campaignID, staffID int) error {
+	if err := s.repo.RemoveAssignment(ctx, campaignID, staffID); err != nil {
+		log.Printf("RemoveStaff: Error removing staff ID %d from campaign ID %d: %v", staffID, campaignID, err)
+		return fmt.Errorf("failed to remove staff from campaign: %w", err)
+	}
+	return nil
+}
+
+func (s *campaignStaffService) GetCampaignStaff(ctx context.Context, campaignID int) ([]models.CampaignStaff, error) {
+	if _, err := s.campaignRepo.GetCampaignByID(ctx, campaignID); err != nil {
+		log.Printf("GetCampaignStaff: Error fetching campaign with ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to fetch campaign: %w", err)
+	}
+
+	assignments, err := s.repo.GetStaffByCampaign(ctx, campaignID)
+	if err != nil {
+		log.Printf("GetCampaignStaff: Error fetching staff for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("fetching campaign staff failed: %w", err)
+	}
+	return assignments, nil
+}
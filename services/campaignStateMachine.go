@@ -0,0 +1,39 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+)
+
+var (
+	ErrUnknownCampaignState   = apperrors.Validation("unknown campaign state")
+	ErrInvalidStateTransition = apperrors.Conflict("invalid campaign state transition")
+)
+
+// campaignTransitions lists the states each campaign state may move to.
+// Completed and cancelled are terminal.
+var campaignTransitions = map[models.CampaignState][]models.CampaignState{
+	models.StateNotStarted: {models.StateInProgress, models.StateCancelled},
+	models.StateInProgress: {models.StateCompleted, models.StateCancelled},
+	models.StateCompleted:  {},
+	models.StateCancelled:  {},
+}
+
+func isKnownCampaignState(state models.CampaignState) bool {
+	_, ok := campaignTransitions[state]
+	return ok
+}
+
+func canTransition(from, to models.CampaignState) bool {
+	for _, next := range campaignTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// completionStatusFor keeps CompletionStatus in line with the campaign state.
+func completionStatusFor(state models.CampaignState) bool {
+	return state == models.StateCompleted
+}
@@ -3,56 +3,68 @@ package services
 import (
 	"agate-project/models"
 	"agate-project/repositories"
+	"context"
 	"fmt"
 	"log"
 )
 
 type CampaignManagerService interface {
-	GetAllCampaignManager() ([]models.CampaignManager, error)
-	AddCampaignManager(campaignManager *models.CampaignManager) error
-	DeleteCampaignManager(managerID int) error
-	//AssignStaffToManager(staffID int, managerID int) error
+	GetAllCampaignManager(ctx context.Context, opts models.ListOptions) (models.Page[models.CampaignManager], error)
+	AddCampaignManager(ctx context.Context, campaignManager *models.CampaignManager) error
+	DeleteCampaignManager(ctx context.Context, managerID int) error
 }
 
 type campaignManagerService struct {
-	repo repositories.CampaignManagerRepository
+	repo  repositories.CampaignManagerRepository
+	audit AuditService
+	uow   repositories.UnitOfWork
 }
 
-func NewCampaignManagerService(repo repositories.CampaignManagerRepository) CampaignManagerService {
+func NewCampaignManagerService(repo repositories.CampaignManagerRepository, audit AuditService, uow repositories.UnitOfWork) CampaignManagerService {
 	return &campaignManagerService{
-		repo: repo,
+		repo:  repo,
+		audit: audit,
+		uow:   uow,
 	}
 }
 
-func (c *campaignManagerService) GetAllCampaignManager() ([]models.CampaignManager, error) {
-	campaignManager, err := c.repo.GetAllCampaignManager()
+func (c *campaignManagerService) GetAllCampaignManager(ctx context.Context, opts models.ListOptions) (models.Page[models.CampaignManager], error) {
+	campaignManager, total, err := c.repo.GetAllCampaignManager(ctx, opts)
 	if err != nil {
 		log.Printf("GetAllCampaignManager: Error fetching campaign managers: %v", err)
-		return nil, fmt.Errorf("error fetching campaign managers: %w", err)
+		return models.Page[models.CampaignManager]{}, fmt.Errorf("error fetching campaign managers: %w", err)
 	}
-	return campaignManager, nil
+	return models.NewPage(campaignManager, total, opts), nil
 }
 
-func (c *campaignManagerService) AddCampaignManager(campaignManager *models.CampaignManager) error {
-	if err := c.repo.AddCampaignManager(campaignManager); err != nil {
+func (c *campaignManagerService) AddCampaignManager(ctx context.Context, campaignManager *models.CampaignManager) error {
+	err := c.uow.Do(ctx, func(ctx context.Context) error {
+		if err := c.repo.AddCampaignManager(ctx, campaignManager); err != nil {
+			return err
+		}
+		return c.audit.Record(ctx, models.AuditManager, campaignManager.ManagerID, models.AuditCreate, nil, campaignManager)
+	})
+	if err != nil {
 		log.Printf("AddCampaignManager: Error adding campaign manager: %v", err)
 		return fmt.Errorf("error adding campaign manager: %w", err)
 	}
 	return nil
 }
 
-func (c *campaignManagerService) DeleteCampaignManager(managerID int) error {
-	if err := c.repo.DeleteCampaignManager(managerID); err != nil {
+func (c *campaignManagerService) DeleteCampaignManager(ctx context.Context, managerID int) error {
+	err := c.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := c.repo.GetCampaignManagerByID(ctx, managerID)
+		if err != nil {
+			return err
+		}
+		if err := c.repo.DeleteCampaignManager(ctx, managerID); err != nil {
+			return err
+		}
+		return c.audit.Record(ctx, models.AuditManager, managerID, models.AuditDelete, before, nil)
+	})
+	if err != nil {
 		log.Printf("DeleteCampaignManager: Error deleting campaign manager with ID %d: %v", managerID, err)
 		return fmt.Errorf("error deleting campaign manager with id %d: %w", managerID, err)
 	}
 	return nil
 }
-
-/*func (c *campaignManagerService) AssignStaffToManager(staffID int, managerID int) error {
-	if err := c.repo.AssignStaffToManager(staffID, managerID); err != nil {
-		log.Printf("AssignStaffToManager: Error assigning staff with ID %d to manager with ID %d: %v", staffID, managerID, err)
-		return fmt.Errorf("error assigning staff with id %d to manager with id %d: %w", staffID, managerID, err)
-	}
-	return nil
-}*/
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClientAuthService logs client portal users in. It mirrors AuthService but
+// issues portal tokens, which the staff API does not accept.
+type ClientAuthService interface {
+	Login(ctx context.Context, username, password string) (models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	Authenticate(ctx context.Context, accessToken string) (models.ClientPrincipal, error)
+	AddAccount(ctx context.Context, clientID int, username, password string) (models.ClientAccount, error)
+	GetAccounts(ctx context.Context, clientID int) ([]models.ClientAccount, error)
+}
+
+type clientAuthService struct {
+	repo       repositories.ClientAccountRepository
+	clientRepo repositories.ClientRepository
+	config     AuthConfig
+	tokens     tokenIssuer
+}
+
+func NewClientAuthService(repo repositories.ClientAccountRepository, clientRepo repositories.ClientRepository, config AuthConfig) ClientAuthService {
+	return &clientAuthService{
+		repo:       repo,
+		clientRepo: clientRepo,
+		config:     config,
+		tokens:     tokenIssuer{config: config, audience: audiencePortal},
+	}
+}
+
+func (s *clientAuthService) Login(ctx context.Context, username, password string) (models.TokenPair, error) {
+	account, err := s.repo.GetClientAccountByUsername(ctx, strings.TrimSpace(username))
+	if err != nil {
+		if apperrors.KindOf(err) != apperrors.KindNotFound {
+			return models.TokenPair{}, fmt.Errorf("login failed: %w", err)
+		}
+		checkPassword("", password)
+		log.Printf("Login: Unknown portal username %q", username)
+		return models.TokenPair{}, ErrInvalidCredentials
+	}
+	if !checkPassword(account.PasswordHash, password) {
+		log.Printf("Login: Wrong password for client user ID %d", account.ClientUserID)
+		return models.TokenPair{}, ErrInvalidCredentials
+	}
+
+	session := s.newSession(account.ClientUserID)
+	if err := s.repo.CreateSession(ctx, &session); err != nil {
+		return models.TokenPair{}, fmt.Errorf("login failed: %w", err)
+	}
+	return s.issueTokens(account, session)
+}
+
+func (s *clientAuthService) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	claims, err := s.tokens.parse(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	clientUserID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return models.TokenPair{}, apperrors.Unauthorized("invalid token subject")
+	}
+
+	account, err := s.repo.GetClientAccountByID(ctx, clientUserID)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return models.TokenPair{}, apperrors.Unauthorized("account no longer exists")
+		}
+		return models.TokenPair{}, fmt.Errorf("refresh failed: %w", err)
+	}
+
+	session := s.newSession(clientUserID)
+	if err := s.repo.RotateSession(ctx, claims.ID, &session); err != nil {
+		log.Printf("Refresh: Failed to rotate session for client user ID %d: %v", clientUserID, err)
+		return models.TokenPair{}, err
+	}
+	return s.issueTokens(account, session)
+}
+
+func (s *clientAuthService) Logout(ctx context.Context, refreshToken string) error {
+	claims, err := s.tokens.parse(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RevokeSession(ctx, claims.ID); err != nil {
+		return fmt.Errorf("logout failed: %w", err)
+	}
+	return nil
+}
+
+func (s *clientAuthService) Authenticate(ctx context.Context, accessToken string) (models.ClientPrincipal, error) {
+	claims, err := s.tokens.parse(accessToken, tokenTypeAccess)
+	if err != nil {
+		return models.ClientPrincipal{}, err
+	}
+	clientUserID, err := strconv.Atoi(claims.Subject)
+	if err != nil || claims.ClientID <= 0 {
+		return models.ClientPrincipal{}, apperrors.Unauthorized("invalid token subject")
+	}
+
+	session, err := s.repo.GetSession(ctx, claims.ID)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return models.ClientPrincipal{}, apperrors.Unauthorized("session not found")
+		}
+		return models.ClientPrincipal{}, fmt.Errorf("authentication failed: %w", err)
+	}
+	if session.RevokedAt != nil || session.ClientUserID != clientUserID {
+		return models.ClientPrincipal{}, apperrors.Unauthorized("session has been revoked")
+	}
+
+	return models.ClientPrincipal{ClientUserID: clientUserID, ClientID: claims.ClientID, SessionID: session.SessionID}, nil
+}
+
+func (s *clientAuthService) AddAccount(ctx context.Context, clientID int, username, password string) (models.ClientAccount, error) {
+	username = strings.TrimSpace(username)
+	if clientID <= 0 {
+		return models.ClientAccount{}, apperrors.BadRequest("invalid client ID")
+	}
+	if username == "" {
+		return models.ClientAccount{}, apperrors.Validation("username is required")
+	}
+	if _, err := s.clientRepo.GetClientByID(ctx, clientID); err != nil {
+		log.Printf("AddAccount: Failed to find client with ID %d: %v", clientID, err)
+		return models.ClientAccount{}, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return models.ClientAccount{}, err
+	}
+
+	account := models.ClientAccount{ClientID: clientID, Username: username, PasswordHash: hash}
+	if err := s.repo.AddClientAccount(ctx, &account); err != nil {
+		log.Printf("AddAccount: Failed to add account for client ID %d: %v", clientID, err)
+		return models.ClientAccount{}, err
+	}
+	return account, nil
+}
+
+func (s *clientAuthService) GetAccounts(ctx context.Context, clientID int) ([]models.ClientAccount, error) {
+	if clientID <= 0 {
+		return nil, apperrors.BadRequest("invalid client ID")
+	}
+	accounts, err := s.repo.GetClientAccountsByClient(ctx, clientID)
+	if err != nil {
+		log.Printf("GetAccounts: Error fetching accounts for client ID %d: %v", clientID, err)
+		return nil, fmt.Errorf("fetching client accounts failed: %w", err)
+	}
+	return accounts, nil
+}
+
+func (s *clientAuthService) newSession(clientUserID int) models.ClientSession {
+	return models.ClientSession{
+		SessionID:    newSessionID(),
+		ClientUserID: clientUserID,
+		ExpiresAt:    time.Now().Add(s.config.RefreshTTL),
+	}
+}
+
+func (s *clientAuthService) issueTokens(account models.ClientAccount, session models.ClientSession) (models.TokenPair, error) {
+	claims := tokenClaims{ClientID: account.ClientID}
+	claims.Subject = strconv.Itoa(account.ClientUserID)
+	return s.tokens.issue(claims, session.SessionID, session.ExpiresAt)
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"agate-project/apperrors"
+	"context"
 	"fmt"
 	"log"
 
@@ -9,37 +11,67 @@ import (
 )
 
 type ClientService interface {
-	FetchAllClients() ([]models.Client, error)
-	AddNewClient(client *models.Client) error
-	RemoveClient(clientID int) error
-	UpdateClient(clientID int, name *string, address *string, contactDetails *string) error
-	GetClientByID(clientID int) (models.Client, error)
+	FetchAllClients(ctx context.Context, opts models.ListOptions) (models.Page[models.Client], error)
+	AddNewClient(ctx context.Context, client *models.Client) error
+	RemoveClient(ctx context.Context, clientID int, strategy models.DeleteStrategy, dryRun bool) (models.ClientDeletion, error)
+	RestoreClient(ctx context.Context, clientID int) (models.Client, error)
+	UpdateClient(ctx context.Context, client *models.Client) error
+	PatchClient(ctx context.Context, clientID, version int, patch models.MergePatch) (models.Client, error)
+	GetClientByID(ctx context.Context, clientID int, includeDeleted bool) (models.Client, error)
 }
 
 type clientService struct {
-	repo repositories.ClientRepository
+	repo         repositories.ClientRepository
+	campaignRepo repositories.CampaignRepository
+	campaigns    campaignDeleter
+	trash        softDeleter
+	audit        AuditService
+	uow          repositories.UnitOfWork
 }
 
-func NewClientService(repo repositories.ClientRepository) ClientService {
-	return &clientService{repo: repo}
+func NewClientService(repo repositories.ClientRepository, campaignRepo repositories.CampaignRepository, advertRepo repositories.AdvertRepository, costEntryRepo repositories.CostEntryRepository, timesheetRepo repositories.TimesheetRepository, campaignStaffRepo repositories.CampaignStaffRepository, audit AuditService, uow repositories.UnitOfWork) ClientService {
+	return &clientService{
+		repo:         repo,
+		campaignRepo: campaignRepo,
+		campaigns: campaignDeleter{
+			campaignRepo:      campaignRepo,
+			advertRepo:        advertRepo,
+			costEntryRepo:     costEntryRepo,
+			timesheetRepo:     timesheetRepo,
+			campaignStaffRepo: campaignStaffRepo,
+		},
+		trash: softDeleter{
+			campaignRepo: campaignRepo,
+			advertRepo:   advertRepo,
+			audit:        audit,
+		},
+		audit: audit,
+		uow:   uow,
+	}
 }
 
-func (s *clientService) FetchAllClients() ([]models.Client, error) {
-	clients, err := s.repo.GetAllClients()
+func (s *clientService) FetchAllClients(ctx context.Context, opts models.ListOptions) (models.Page[models.Client], error) {
+	clients, total, err := s.repo.GetAllClients(ctx, opts)
 	if err != nil {
 		log.Printf("FetchAllClients: Error fetching clients: %v", err)
-		return nil, fmt.Errorf("fetching clients failed: %w", err)
+		return models.Page[models.Client]{}, fmt.Errorf("fetching clients failed: %w", err)
 	}
-	return clients, nil
+	return models.NewPage(clients, total, opts), nil
 }
 
-func (s *clientService) GetClientByID(clientID int) (models.Client, error) {
+// GetClientByID returns the client, or not found if it has been deleted
+// unless includeDeleted is set.
+func (s *clientService) GetClientByID(ctx context.Context, clientID int, includeDeleted bool) (models.Client, error) {
 	if clientID <= 0 {
 		log.Printf("GetClientByID: Invalid client ID: %d", clientID)
-		return models.Client{}, fmt.Errorf("invalid client ID")
+		return models.Client{}, apperrors.BadRequest("invalid client ID")
 	}
 
-	client, err := s.repo.GetClientByID(clientID)
+	get := s.repo.GetClientByID
+	if includeDeleted {
+		get = s.repo.GetClientIncludingDeleted
+	}
+	client, err := get(ctx, clientID)
 	if err != nil {
 		log.Printf("GetClientByID: Error fetching client with ID %d: %v", clientID, err)
 		return models.Client{}, fmt.Errorf("failed to fetch client with ID %d: %w", clientID, err)
@@ -48,32 +80,207 @@ func (s *clientService) GetClientByID(clientID int) (models.Client, error) {
 	return client, nil
 }
 
-func (s *clientService) AddNewClient(client *models.Client) error {
-	if err := s.repo.AddClient(client); err != nil {
+func (s *clientService) AddNewClient(ctx context.Context, client *models.Client) error {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.AddClient(ctx, client); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditClient, client.ClientID, models.AuditCreate, nil, client)
+	})
+	if err != nil {
 		log.Printf("AddNewClient: Error adding client: %v", err)
 		return fmt.Errorf("adding client failed: %w", err)
 	}
 	return nil
 }
 
-func (s *clientService) RemoveClient(clientID int) error {
-	if err := s.repo.RemoveClient(clientID); err != nil {
+// RemoveClient deletes a client with the given strategy, archive if none is
+// given, and reports the records it touched. A dry run makes the same checks
+// and counts but changes nothing, so it fails wherever the real deletion
+// would. Either the whole deletion happens or none of it does.
+func (s *clientService) RemoveClient(ctx context.Context, clientID int, strategy models.DeleteStrategy, dryRun bool) (models.ClientDeletion, error) {
+	if clientID <= 0 {
+		log.Printf("RemoveClient: Invalid client ID: %d", clientID)
+		return models.ClientDeletion{}, apperrors.BadRequest("invalid client ID")
+	}
+	if strategy == "" {
+		strategy = models.DeleteArchive
+	}
+	if !strategy.Valid() {
+		log.Printf("RemoveClient: Unknown delete strategy %q.", strategy)
+		return models.ClientDeletion{}, apperrors.BadRequest("strategy must be restrict, cascade or archive")
+	}
+
+	deletion := models.ClientDeletion{ClientID: clientID, Strategy: strategy, DryRun: dryRun}
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		client, err := s.repo.GetClientByID(ctx, clientID)
+		if err != nil {
+			return err
+		}
+		if deletion.Impact, err = s.repo.GetDeletionImpact(ctx, clientID, strategy == models.DeleteCascade); err != nil {
+			return err
+		}
+		if strategy == models.DeleteRestrict && deletion.Impact.Campaigns > 0 {
+			return apperrors.Conflict("client %d still has %d campaign(s); delete them first or use strategy cascade or archive", clientID, deletion.Impact.Campaigns)
+		}
+		if dryRun {
+			return nil
+		}
+
+		if strategy == models.DeleteCascade {
+			return s.purgeClient(ctx, client)
+		}
+		return s.archiveClient(ctx, client)
+	})
+	if err != nil {
 		log.Printf("RemoveClient: Error removing client with ID %d: %v", clientID, err)
-		return fmt.Errorf("failed to remove client with ID %d: %w", clientID, err)
+		return models.ClientDeletion{}, fmt.Errorf("failed to remove client with ID %d: %w", clientID, err)
 	}
-	return nil
+	return deletion, nil
+}
+
+// archiveClient soft-deletes the client together with its campaigns and
+// their adverts. The client's portal users can no longer log in.
+func (s *clientService) archiveClient(ctx context.Context, client models.Client) error {
+	campaigns, err := s.campaignRepo.GetCampaignsByClientID(ctx, client.ClientID)
+	if err != nil {
+		return err
+	}
+	for _, campaign := range campaigns {
+		if err := s.trash.deleteCampaign(ctx, campaign); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.RemoveClient(ctx, client.ClientID); err != nil {
+		return err
+	}
+	return s.audit.Record(ctx, models.AuditClient, client.ClientID, models.AuditDelete, client, nil)
+}
+
+// purgeClient permanently deletes the client, all of its campaigns including
+// deleted ones, everything recorded against them and its portal accounts.
+func (s *clientService) purgeClient(ctx context.Context, client models.Client) error {
+	campaigns, err := s.campaignRepo.GetCampaignsByClientIncludingDeleted(ctx, client.ClientID)
+	if err != nil {
+		return err
+	}
+	for _, campaign := range campaigns {
+		if err := s.campaigns.deleteCampaign(ctx, campaign.CampaignID); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditCampaign, campaign.CampaignID, models.AuditPurge, campaign, nil); err != nil {
+			return err
+		}
+	}
+	// portal hesapları veritabanında cascade ile silinir
+	if err := s.repo.PurgeClient(ctx, client.ClientID); err != nil {
+		return err
+	}
+	return s.audit.Record(ctx, models.AuditClient, client.ClientID, models.AuditPurge, client, nil)
 }
 
-func (s *clientService) UpdateClient(clientID int, name *string, address *string, contactDetails *string) error {
+// RestoreClient undoes RemoveClient, bringing back the campaigns and adverts
+// deleted with the client. Campaigns deleted before the client stay deleted.
+func (s *clientService) RestoreClient(ctx context.Context, clientID int) (models.Client, error) {
 	if clientID <= 0 {
-		log.Printf("UpdateClient: Invalid client ID: %d", clientID)
-		return fmt.Errorf("invalid client ID")
+		log.Printf("RestoreClient: Invalid client ID: %d", clientID)
+		return models.Client{}, apperrors.BadRequest("invalid client ID")
 	}
 
-	if err := s.repo.UpdateClient(clientID, name, address, contactDetails); err != nil {
-		log.Printf("UpdateClient: Error updating client with ID %d: %v", clientID, err)
-		return fmt.Errorf("failed to update client with ID %d: %w", clientID, err)
+	var client models.Client
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if client, err = s.repo.GetClientIncludingDeleted(ctx, clientID); err != nil {
+			return err
+		}
+		if client.DeletedAt == nil {
+			return apperrors.Conflict("client %d is not deleted", clientID)
+		}
+		campaigns, err := s.campaignRepo.GetCampaignsDeletedWith(ctx, clientID, *client.DeletedAt)
+		if err != nil {
+			return err
+		}
+
+		if client.Version, err = s.repo.RestoreClient(ctx, clientID); err != nil {
+			return err
+		}
+		client.DeletedAt = nil
+		if err := s.audit.Record(ctx, models.AuditClient, clientID, models.AuditRestore, nil, client); err != nil {
+			return err
+		}
+		for _, campaign := range campaigns {
+			if _, err := s.trash.restoreCampaign(ctx, campaign); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("RestoreClient: Error restoring client with ID %d: %v", clientID, err)
+		return models.Client{}, fmt.Errorf("failed to restore client with ID %d: %w", clientID, err)
 	}
+	return client, nil
+}
 
+// UpdateClient replaces the client's details with those of client if it is
+// still at client.Version. On success client holds the updated record.
+func (s *clientService) UpdateClient(ctx context.Context, client *models.Client) error {
+	updated, err := s.update(ctx, client.ClientID, client.Version, func(before models.Client) (models.Client, error) {
+		after := *client
+		after.DeletedAt = before.DeletedAt
+		return after, nil
+	})
+	if err != nil {
+		log.Printf("UpdateClient: Error updating client with ID %d: %v", client.ClientID, err)
+		return fmt.Errorf("failed to update client with ID %d: %w", client.ClientID, err)
+	}
+	*client = updated
 	return nil
 }
+
+// PatchClient applies a merge patch to the client if it is still at version
+// and returns the updated record.
+func (s *clientService) PatchClient(ctx context.Context, clientID, version int, patch models.MergePatch) (models.Client, error) {
+	client, err := s.update(ctx, clientID, version, func(before models.Client) (models.Client, error) {
+		after := before
+		err := patch.Apply(&after, "client_id", "version", "deleted_at")
+		return after, err
+	})
+	if err != nil {
+		log.Printf("PatchClient: Error patching client with ID %d: %v", clientID, err)
+		return models.Client{}, fmt.Errorf("failed to patch client with ID %d: %w", clientID, err)
+	}
+	return client, nil
+}
+
+// update runs change against the client as it is now, validates the result
+// and writes the fields that changed. Changing nothing is not recorded.
+func (s *clientService) update(ctx context.Context, clientID, version int, change func(before models.Client) (models.Client, error)) (models.Client, error) {
+	if clientID <= 0 {
+		return models.Client{}, apperrors.BadRequest("invalid client ID")
+	}
+
+	var after models.Client
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetClientByID(ctx, clientID)
+		if err != nil {
+			return err
+		}
+		if version, err = repositories.RequireVersion("client", clientID, before.Version, version); err != nil {
+			return err
+		}
+		if after, err = change(before); err != nil {
+			return err
+		}
+		after.ClientID, after.Version = clientID, version
+
+		if after.Version, err = s.repo.UpdateClient(ctx, version, before, after); err != nil {
+			return err
+		}
+		if after.Version == version {
+			return nil
+		}
+		return s.audit.Record(ctx, models.AuditClient, clientID, models.AuditUpdate, before, after)
+	})
+	return after, err
+}
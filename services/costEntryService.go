@@ -0,0 +1,155 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrInvalidCostEntry = apperrors.Validation("invalid cost entry")
+
+type CostEntryService interface {
+	AddCostEntry(ctx context.Context, entry *models.CostEntry) (models.CampaignBudget, error)
+	GetCostEntriesByCampaign(ctx context.Context, campaignID int) ([]models.CostEntry, error)
+	RemoveCostEntry(ctx context.Context, campaignID, entryID int) error
+}
+
+type costEntryService struct {
+	repo         repositories.CostEntryRepository
+	campaignRepo repositories.CampaignRepository
+	advertRepo   repositories.AdvertRepository
+	uow          repositories.UnitOfWork
+	thresholds   BudgetThresholds
+}
+
+func NewCostEntryService(repo repositories.CostEntryRepository, campaignRepo repositories.CampaignRepository, advertRepo repositories.AdvertRepository, uow repositories.UnitOfWork, thresholds BudgetThresholds) CostEntryService {
+	return &costEntryService{
+		repo:         repo,
+		campaignRepo: campaignRepo,
+		advertRepo:   advertRepo,
+		uow:          uow,
+		thresholds:   thresholds,
+	}
+}
+
+// AddCostEntry records an expense against a campaign, rejecting it if the
+// campaign would go past its hard-stop budget threshold. The campaign is
+// locked from the check until the entry is written, so concurrent postings
+// are checked one after another. It returns the campaign's budget with the
+// entry counted, flagged if spending has reached the warning threshold.
+func (s *costEntryService) AddCostEntry(ctx context.Context, entry *models.CostEntry) (models.CampaignBudget, error) {
+	log.Printf("AddCostEntry: Adding a cost entry for campaign ID %d.", entry.CampaignID)
+	if entry.EntryDate.IsZero() {
+		entry.EntryDate = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	var budget models.CampaignBudget
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		budget, err = s.addCostEntry(ctx, entry)
+		return err
+	})
+	if err != nil {
+		return models.CampaignBudget{}, err
+	}
+	if budget.Status == models.BudgetWarning {
+		log.Printf("AddCostEntry: Campaign ID %d has used %.2f%% of its budget.", entry.CampaignID, budget.PercentBurned)
+	}
+	return budget, nil
+}
+
+func (s *costEntryService) addCostEntry(ctx context.Context, entry *models.CostEntry) (models.CampaignBudget, error) {
+	budget, err := s.campaignRepo.GetBudgetForUpdate(ctx, entry.CampaignID)
+	if err != nil {
+		log.Printf("AddCostEntry: Error fetching budget for campaign ID %d: %v", entry.CampaignID, err)
+		return budget, fmt.Errorf("failed to fetch campaign budget: %w", err)
+	}
+
+	if entry.Amount.Currency == "" {
+		entry.Amount.Currency = budget.Currency
+	}
+	actualCost, err := budget.ActualCost.Add(entry.Amount)
+	if err != nil {
+		log.Printf("AddCostEntry: Cost entry currency does not match campaign ID %d: %v", entry.CampaignID, err)
+		return budget, err
+	}
+	entry.Currency = entry.Amount.Currency
+	if err := entry.Amount.Validate(); err != nil {
+		log.Printf("AddCostEntry: Invalid cost entry amount: %v", err)
+		return budget, err
+	}
+
+	if entry.AdvertID != nil {
+		advert, err := s.advertRepo.GetAdvertById(ctx, *entry.AdvertID)
+		if err != nil {
+			log.Printf("AddCostEntry: Error fetching advert with ID %d: %v", *entry.AdvertID, err)
+			return budget, fmt.Errorf("failed to fetch advert: %w", err)
+		}
+		if advert.CampaignID != entry.CampaignID {
+			log.Printf("AddCostEntry: Advert with ID %d does not belong to campaign ID %d.", advert.AdvertID, entry.CampaignID)
+			return budget, fmt.Errorf("%w: advert %d does not belong to campaign %d", ErrInvalidCostEntry, advert.AdvertID, entry.CampaignID)
+		}
+	}
+
+	if err := s.thresholds.Check(budget.Budget, budget.EstimatedCost, actualCost); err != nil {
+		log.Printf("AddCostEntry: Cost of %s rejected for campaign ID %d: %v", entry.Amount, entry.CampaignID, err)
+		return budget, err
+	}
+
+	if err := s.repo.AddCostEntry(ctx, entry); err != nil {
+		log.Printf("AddCostEntry: Error adding cost entry: %v", err)
+		return budget, fmt.Errorf("adding cost entry failed: %w", err)
+	}
+	budget.ActualCost = actualCost
+	return s.thresholds.Evaluate(budget), nil
+}
+
+func (s *costEntryService) GetCostEntriesByCampaign(ctx context.Context, campaignID int) ([]models.CostEntry, error) {
+	if _, err := s.campaignRepo.CheckBudget(ctx, campaignID); err != nil {
+		log.Printf("GetCostEntriesByCampaign: Error fetching campaign with ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("failed to fetch campaign: %w", err)
+	}
+
+	entries, err := s.repo.GetCostEntriesByCampaign(ctx, campaignID)
+	if err != nil {
+		log.Printf("GetCostEntriesByCampaign: Error fetching cost entries for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("fetching cost entries failed: %w", err)
+	}
+	return entries, nil
+}
+
+// RemoveCostEntry deletes a cost entry of a campaign. Like AddCostEntry it
+// holds the campaign's lock, so the campaign's actual cost is recalculated
+// after any posting that is under way.
+func (s *costEntryService) RemoveCostEntry(ctx context.Context, campaignID, entryID int) error {
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		return s.removeCostEntry(ctx, campaignID, entryID)
+	})
+}
+
+func (s *costEntryService) removeCostEntry(ctx context.Context, campaignID, entryID int) error {
+	if _, err := s.campaignRepo.GetBudgetForUpdate(ctx, campaignID); err != nil {
+		log.Printf("RemoveCostEntry: Error fetching budget for campaign ID %d: %v", campaignID, err)
+		return fmt.Errorf("failed to fetch campaign budget: %w", err)
+	}
+
+	entry, err := s.repo.GetCostEntryByID(ctx, entryID)
+	if err != nil {
+		log.Printf("RemoveCostEntry: Error fetching cost entry with ID %d: %v", entryID, err)
+		return fmt.Errorf("failed to fetch cost entry: %w", err)
+	}
+	if entry.CampaignID != campaignID {
+		log.Printf("RemoveCostEntry: Cost entry with ID %d does not belong to campaign ID %d.", entryID, campaignID)
+		return apperrors.NotFound("cost entry %d not found on campaign %d", entryID, campaignID)
+	}
+
+	if err := s.repo.DeleteCostEntry(ctx, entryID); err != nil {
+		log.Printf("RemoveCostEntry: Error removing cost entry with ID %d: %v", entryID, err)
+		return fmt.Errorf("failed to remove cost entry with id %d: %w", entryID, err)
+	}
+	return nil
+}
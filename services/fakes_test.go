@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+
+	"agate-project/models"
+)
+
+// fakeUnitOfWork runs fn straight away; the fake repositories have no
+// transactions to join.
+type fakeUnitOfWork struct{}
+
+func (fakeUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// fakeAudit accepts every record.
+type fakeAudit struct {
+	AuditService
+}
+
+func (fakeAudit) Record(context.Context, string, int, models.AuditAction, any, any) error {
+	return nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+)
+
+// rolePermissions is what each role may do. Directors may do everything.
+var rolePermissions = map[models.Role][]models.Permission{
+	models.RoleAccountManager: {
+		models.PermReadClients, models.PermManageClients,
+		models.PermReadStaff,
+		models.PermReadGrades,
+		models.PermReadCampaigns, models.PermManageCampaigns,
+		models.PermReadCosts,
+		models.PermLogTime,
+		models.PermManageManagers,
+		models.PermReadAdverts, models.PermManageAdverts, models.PermUpdateAdvertProgress,
+	},
+	models.RoleCampaignManager: {
+		models.PermReadClients,
+		models.PermReadStaff,
+		models.PermReadCampaigns, models.PermManageCampaigns, models.PermTransitionCampaigns,
+		models.PermReadCosts,
+		models.PermLogTime,
+		models.PermReadAdverts, models.PermManageAdverts, models.PermUpdateAdvertProgress,
+	},
+	models.RoleCreative: {
+		models.PermReadClients,
+		models.PermReadCampaigns,
+		models.PermLogTime,
+		models.PermReadAdverts, models.PermUpdateAdvertProgress,
+	},
+	models.RoleFinance: {
+		models.PermReadClients,
+		models.PermReadStaff,
+		models.PermReadGrades, models.PermManageGrades,
+		models.PermReadCampaigns,
+		models.PermReadCosts, models.PermPostCosts,
+		models.PermLogTime,
+		models.PermReadAdverts,
+	},
+	models.RoleClientViewer: {
+		models.PermReadClients,
+		models.PermReadCampaigns,
+		models.PermReadAdverts,
+	},
+}
+
+func missingPermission(permission models.Permission) error {
+	return apperrors.Forbidden("missing permission %q", permission)
+}
+
+// Policy decides whether a caller may perform an action. Handlers consult it
+// before calling into the other services.
+type Policy interface {
+	Allowed(principal models.Principal, permission models.Permission) bool
+	Require(principal models.Principal, permission models.Permission) error
+	RequireSelfOr(principal models.Principal, staffID int, permission models.Permission) error
+	RequireCampaignManager(ctx context.Context, principal models.Principal, campaignID int) error
+}
+
+type policy struct {
+	campaignRepo repositories.CampaignRepository
+	managerRepo  repositories.CampaignManagerRepository
+}
+
+func NewPolicy(campaignRepo repositories.CampaignRepository, managerRepo repositories.CampaignManagerRepository) Policy {
+	return &policy{
+		campaignRepo: campaignRepo,
+		managerRepo:  managerRepo,
+	}
+}
+
+func (p *policy) Allowed(principal models.Principal, permission models.Permission) bool {
+	role := models.ParseRole(principal.Role)
+	if role == models.RoleDirector {
+		return true
+	}
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *policy) Require(principal models.Principal, permission models.Permission) error {
+	if !p.Allowed(principal, permission) {
+		log.Printf("Require: Staff ID %d (%q) lacks %s", principal.StaffID, principal.Role, permission)
+		return missingPermission(permission)
+	}
+	return nil
+}
+
+// RequireSelfOr lets staff act on their own records, and anyone holding
+// permission act on everyone's.
+func (p *policy) RequireSelfOr(principal models.Principal, staffID int, permission models.Permission) error {
+	if principal.StaffID == staffID {
+		return nil
+	}
+	return p.Require(principal, permission)
+}
+
+// RequireCampaignManager allows the campaign's assigned manager, or anyone who
+// may transition any campaign.
+func (p *policy) RequireCampaignManager(ctx context.Context, principal models.Principal, campaignID int) error {
+	if p.Allowed(principal, models.PermTransitionAnyCampaign) {
+		return nil
+	}
+	if err := p.Require(principal, models.PermTransitionCampaigns); err != nil {
+		return err
+	}
+
+	campaign, err := p.campaignRepo.GetCampaignByID(ctx, campaignID)
+	if err != nil {
+		return fmt.Errorf("failed to check campaign ownership: %w", err)
+	}
+	if campaign.ManagerID != 0 {
+		manager, err := p.managerRepo.GetCampaignManagerByID(ctx, campaign.ManagerID)
+		if err != nil && apperrors.KindOf(err) != apperrors.KindNotFound {
+			return fmt.Errorf("failed to check campaign ownership: %w", err)
+		}
+		if err == nil && manager.StaffID == principal.StaffID {
+			return nil
+		}
+	}
+
+	log.Printf("RequireCampaignManager: Staff ID %d does not manage campaign ID %d", principal.StaffID, campaignID)
+	return missingPermission(models.PermTransitionAnyCampaign)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+)
+
+// PortalService serves the client portal. Every method takes the caller's
+// client ID and only returns that client's records, as portal models that
+// leave out internal costs, notes and staffing.
+type PortalService interface {
+	GetCampaigns(ctx context.Context, clientID int) ([]models.PortalCampaign, error)
+	GetCampaign(ctx context.Context, clientID, campaignID int) (models.PortalCampaign, error)
+	GetCampaignAdverts(ctx context.Context, clientID, campaignID int) ([]models.PortalAdvert, error)
+	GetSchedule(ctx context.Context, clientID int) ([]models.PortalAdvert, error)
+}
+
+type portalService struct {
+	campaignRepo repositories.CampaignRepository
+	advertRepo   repositories.AdvertRepository
+}
+
+func NewPortalService(campaignRepo repositories.CampaignRepository, advertRepo repositories.AdvertRepository) PortalService {
+	return &portalService{
+		campaignRepo: campaignRepo,
+		advertRepo:   advertRepo,
+	}
+}
+
+func (s *portalService) GetCampaigns(ctx context.Context, clientID int) ([]models.PortalCampaign, error) {
+	campaigns, err := s.campaignRepo.GetCampaignsByClientID(ctx, clientID)
+	if err != nil {
+		log.Printf("GetCampaigns: Error fetching campaigns for client ID %d: %v", clientID, err)
+		return nil, fmt.Errorf("fetching campaigns failed: %w", err)
+	}
+
+	result := make([]models.PortalCampaign, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		result = append(result, models.NewPortalCampaign(campaign))
+	}
+	return result, nil
+}
+
+func (s *portalService) GetCampaign(ctx context.Context, clientID, campaignID int) (models.PortalCampaign, error) {
+	campaign, err := s.ownCampaign(ctx, clientID, campaignID)
+	if err != nil {
+		return models.PortalCampaign{}, err
+	}
+	return models.NewPortalCampaign(campaign), nil
+}
+
+func (s *portalService) GetCampaignAdverts(ctx context.Context, clientID, campaignID int) ([]models.PortalAdvert, error) {
+	if _, err := s.ownCampaign(ctx, clientID, campaignID); err != nil {
+		return nil, err
+	}
+	adverts, err := s.advertRepo.GetAdvertsByCampaign(ctx, campaignID)
+	if err != nil {
+		log.Printf("GetCampaignAdverts: Error fetching adverts for campaign ID %d: %v", campaignID, err)
+		return nil, fmt.Errorf("fetching adverts failed: %w", err)
+	}
+	return portalAdverts(adverts), nil
+}
+
+// GetSchedule returns the client's adverts across all its campaigns in run
+// date order.
+func (s *portalService) GetSchedule(ctx context.Context, clientID int) ([]models.PortalAdvert, error) {
+	adverts, err := s.advertRepo.GetAdvertsByClient(ctx, clientID)
+	if err != nil {
+		log.Printf("GetSchedule: Error fetching adverts for client ID %d: %v", clientID, err)
+		return nil, fmt.Errorf("fetching schedule failed: %w", err)
+	}
+	return portalAdverts(adverts), nil
+}
+
+// ownCampaign loads a campaign and hides it unless it belongs to the client.
+// Other clients' campaigns are reported as not found rather than forbidden
+// so their IDs are not revealed.
+func (s *portalService) ownCampaign(ctx context.Context, clientID, campaignID int) (models.Campaign, error) {
+	campaign, err := s.campaignRepo.GetCampaignByID(ctx, campaignID)
+	if err != nil {
+		log.Printf("ownCampaign: Error fetching campaign ID %d: %v", campaignID, err)
+		return models.Campaign{}, fmt.Errorf("fetching campaign failed: %w", err)
+	}
+	if campaign.ClientID != clientID {
+		log.Printf("ownCampaign: Campaign ID %d does not belong to client ID %d", campaignID, clientID)
+		return models.Campaign{}, apperrors.NotFound("campaign not found")
+	}
+	return campaign, nil
+}
+
+func portalAdverts(adverts []models.Advert) []models.PortalAdvert {
+	result := make([]models.PortalAdvert, 0, len(adverts))
+	for _, advert := range adverts {
+		result = append(result, models.NewPortalAdvert(advert))
+	}
+	return result
+}
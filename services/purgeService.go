@@ -0,0 +1,166 @@
+package services
+
+import (
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RetentionConfig sets how long deleted clients, campaigns and adverts are
+// kept before they are purged, and how often the purge runs. An Interval of
+// 0 turns the background purge off.
+type RetentionConfig struct {
+	Period   time.Duration
+	Interval time.Duration
+}
+
+func DefaultRetentionConfig() RetentionConfig {
+	return RetentionConfig{Period: 30 * 24 * time.Hour, Interval: time.Hour}
+}
+
+func (c RetentionConfig) Validate() error {
+	if c.Period <= 0 {
+		return fmt.Errorf("retention period must be positive")
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("purge interval must not be negative")
+	}
+	return nil
+}
+
+// PurgeService permanently removes what has been soft-deleted for longer
+// than the retention period.
+type PurgeService interface {
+	Purge(ctx context.Context) (models.PurgeResult, error)
+	// Run purges every Interval until ctx is done.
+	Run(ctx context.Context)
+}
+
+type purgeService struct {
+	clientRepo   repositories.ClientRepository
+	campaignRepo repositories.CampaignRepository
+	advertRepo   repositories.AdvertRepository
+	campaigns    campaignDeleter
+	audit        AuditService
+	uow          repositories.UnitOfWork
+	config       RetentionConfig
+}
+
+func NewPurgeService(clientRepo repositories.ClientRepository, campaignRepo repositories.CampaignRepository, advertRepo repositories.AdvertRepository, costEntryRepo repositories.CostEntryRepository, timesheetRepo repositories.TimesheetRepository, campaignStaffRepo repositories.CampaignStaffRepository, audit AuditService, uow repositories.UnitOfWork, config RetentionConfig) PurgeService {
+	return &purgeService{
+		clientRepo:   clientRepo,
+		campaignRepo: campaignRepo,
+		advertRepo:   advertRepo,
+		campaigns: campaignDeleter{
+			campaignRepo:      campaignRepo,
+			advertRepo:        advertRepo,
+			costEntryRepo:     costEntryRepo,
+			timesheetRepo:     timesheetRepo,
+			campaignStaffRepo: campaignStaffRepo,
+		},
+		audit:  audit,
+		uow:    uow,
+		config: config,
+	}
+}
+
+// Purge removes adverts, then campaigns with everything recorded against
+// them, then clients. Each record is purged in its own unit of work, so one
+// that fails is logged, left for the next run and does not hold up the rest.
+// Children are always deleted with or before their parent, so by the time a
+// client is due its campaigns and adverts are gone.
+func (s *purgeService) Purge(ctx context.Context) (models.PurgeResult, error) {
+	cutoff := time.Now().Add(-s.config.Period)
+	log.Printf("Purge: Purging records deleted before %s.", cutoff.Format(time.RFC3339))
+
+	var result models.PurgeResult
+	var errs []error
+
+	adverts, err := s.advertRepo.GetDeletedAdverts(ctx, cutoff)
+	if err != nil {
+		return result, fmt.Errorf("purge failed: %w", err)
+	}
+	for _, advert := range adverts {
+		err := s.uow.Do(ctx, func(ctx context.Context) error {
+			if err := s.advertRepo.PurgeAdvert(ctx, advert.AdvertID); err != nil {
+				return err
+			}
+			return s.audit.Record(ctx, models.AuditAdvert, advert.AdvertID, models.AuditPurge, advert, nil)
+		})
+		if err != nil {
+			log.Printf("Purge: Failed to purge advert ID %d: %v", advert.AdvertID, err)
+			errs = append(errs, err)
+			continue
+		}
+		result.Adverts++
+	}
+
+	campaigns, err := s.campaignRepo.GetDeletedCampaigns(ctx, cutoff)
+	if err != nil {
+		return result, fmt.Errorf("purge failed: %w", errors.Join(append(errs, err)...))
+	}
+	for _, campaign := range campaigns {
+		err := s.uow.Do(ctx, func(ctx context.Context) error {
+			if err := s.campaigns.deleteCampaign(ctx, campaign.CampaignID); err != nil {
+				return err
+			}
+			return s.audit.Record(ctx, models.AuditCampaign, campaign.CampaignID, models.AuditPurge, campaign, nil)
+		})
+		if err != nil {
+			log.Printf("Purge: Failed to purge campaign ID %d: %v", campaign.CampaignID, err)
+			errs = append(errs, err)
+			continue
+		}
+		result.Campaigns++
+	}
+
+	clients, err := s.clientRepo.GetDeletedClients(ctx, cutoff)
+	if err != nil {
+		return result, fmt.Errorf("purge failed: %w", errors.Join(append(errs, err)...))
+	}
+	for _, client := range clients {
+		err := s.uow.Do(ctx, func(ctx context.Context) error {
+			if err := s.clientRepo.PurgeClient(ctx, client.ClientID); err != nil {
+				return err
+			}
+			return s.audit.Record(ctx, models.AuditClient, client.ClientID, models.AuditPurge, client, nil)
+		})
+		if err != nil {
+			log.Printf("Purge: Failed to purge client ID %d: %v", client.ClientID, err)
+			errs = append(errs, err)
+			continue
+		}
+		result.Clients++
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return result, fmt.Errorf("purge incomplete: %w", err)
+	}
+	return result, nil
+}
+
+func (s *purgeService) Run(ctx context.Context) {
+	if s.config.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		result, err := s.Purge(ctx)
+		if err != nil {
+			log.Printf("Run: Purge failed: %v", err)
+		} else if result != (models.PurgeResult{}) {
+			log.Printf("Run: Purged %d client(s), %d campaign(s) and %d advert(s).", result.Clients, result.Campaigns, result.Adverts)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
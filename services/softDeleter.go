@@ -0,0 +1,68 @@
+package services
+
+import (
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+)
+
+// softDeleter marks campaigns and their adverts deleted and brings them
+// back, recording each in the audit log. Like campaignDeleter it runs inside
+// the caller's unit of work. Everything deleted in one unit of work shares
+// its deleted_at, the transaction's start time, so restoring a campaign
+// brings back only the adverts deleted with it and not those deleted on
+// their own before.
+type softDeleter struct {
+	campaignRepo repositories.CampaignRepository
+	advertRepo   repositories.AdvertRepository
+	audit        AuditService
+}
+
+func (d softDeleter) deleteCampaign(ctx context.Context, campaign models.Campaign) error {
+	log.Printf("deleteCampaign: Soft-deleting campaign ID %d and its adverts.", campaign.CampaignID)
+	adverts, err := d.advertRepo.GetAdvertsByCampaign(ctx, campaign.CampaignID)
+	if err != nil {
+		return fmt.Errorf("failed to delete campaign %d: %w", campaign.CampaignID, err)
+	}
+	if err := d.advertRepo.DeleteAdvertsByCampaign(ctx, campaign.CampaignID); err != nil {
+		return fmt.Errorf("failed to delete campaign %d: %w", campaign.CampaignID, err)
+	}
+	for _, advert := range adverts {
+		if err := d.audit.Record(ctx, models.AuditAdvert, advert.AdvertID, models.AuditDelete, advert, nil); err != nil {
+			return err
+		}
+	}
+	if err := d.campaignRepo.DeleteCampaign(ctx, campaign.CampaignID); err != nil {
+		return fmt.Errorf("failed to delete campaign %d: %w", campaign.CampaignID, err)
+	}
+	return d.audit.Record(ctx, models.AuditCampaign, campaign.CampaignID, models.AuditDelete, campaign, nil)
+}
+
+// restoreCampaign undoes deleteCampaign. campaign is the deleted campaign as
+// read, and is returned as restored.
+func (d softDeleter) restoreCampaign(ctx context.Context, campaign models.Campaign) (models.Campaign, error) {
+	log.Printf("restoreCampaign: Restoring campaign ID %d and its adverts.", campaign.CampaignID)
+	deletedAt := *campaign.DeletedAt
+	version, err := d.campaignRepo.RestoreCampaign(ctx, campaign.CampaignID)
+	if err != nil {
+		return campaign, fmt.Errorf("failed to restore campaign %d: %w", campaign.CampaignID, err)
+	}
+	campaign.Version = version
+	campaign.DeletedAt = nil
+	if err := d.audit.Record(ctx, models.AuditCampaign, campaign.CampaignID, models.AuditRestore, nil, campaign); err != nil {
+		return campaign, err
+	}
+
+	adverts, err := d.advertRepo.RestoreAdvertsDeletedWith(ctx, campaign.CampaignID, deletedAt)
+	if err != nil {
+		return campaign, fmt.Errorf("failed to restore campaign %d: %w", campaign.CampaignID, err)
+	}
+	for _, advert := range adverts {
+		if err := d.audit.Record(ctx, models.AuditAdvert, advert.AdvertID, models.AuditRestore, nil, advert); err != nil {
+			return campaign, err
+		}
+	}
+	return campaign, nil
+}
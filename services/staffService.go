@@ -1,39 +1,54 @@
 package services
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"agate-project/repositories"
+	"context"
 	"fmt"
 	"log"
+	"time"
 )
 
 type StaffService interface {
-	FetchAllStaff() ([]models.Staff, error)
-	AddStaff(staff *models.Staff) error
-	RemoveStaff(staffID int) error
-	UpdateStaff(staffID int, updatedDetails *models.Staff) error
-	GetStaffByID(staffID int) (models.Staff, error)
+	FetchAllStaff(ctx context.Context, opts models.ListOptions) (models.Page[models.Staff], error)
+	AddStaff(ctx context.Context, staff *models.Staff) error
+	RemoveStaff(ctx context.Context, staffID int) error
+	UpdateStaff(ctx context.Context, staffID int, updatedDetails *models.Staff) error
+	PatchStaff(ctx context.Context, staffID int, patch models.MergePatch) (models.Staff, error)
+	GetStaffByID(ctx context.Context, staffID int) (models.Staff, error)
+	SetStaffActive(ctx context.Context, staffID int, active bool) error
+	Promote(ctx context.Context, staffID, gradeID int, effectiveDate time.Time) (models.StaffGradeChange, error)
+	GetGradeHistory(ctx context.Context, staffID int) ([]models.StaffGradeChange, error)
 }
 
 type staffService struct {
-	repo repositories.StaffRepository
+	repo      repositories.StaffRepository
+	gradeRepo repositories.StaffGradeRepository
+	audit     AuditService
+	uow       repositories.UnitOfWork
 }
 
-func NewStaffService(repo repositories.StaffRepository) StaffService {
-	return &staffService{repo: repo}
+func NewStaffService(repo repositories.StaffRepository, gradeRepo repositories.StaffGradeRepository, audit AuditService, uow repositories.UnitOfWork) StaffService {
+	return &staffService{
+		repo:      repo,
+		gradeRepo: gradeRepo,
+		audit:     audit,
+		uow:       uow,
+	}
 }
 
-func (s *staffService) FetchAllStaff() ([]models.Staff, error) {
-	staff, err := s.repo.GetAllStaff()
+func (s *staffService) FetchAllStaff(ctx context.Context, opts models.ListOptions) (models.Page[models.Staff], error) {
+	staff, total, err := s.repo.GetAllStaff(ctx, opts)
 	if err != nil {
 		log.Printf("FetchAllStaff: Error fetching staff: %v", err)
-		return nil, fmt.Errorf("fetching staff failed: %w", err)
+		return models.Page[models.Staff]{}, fmt.Errorf("fetching staff failed: %w", err)
 	}
-	return staff, nil
+	return models.NewPage(staff, total, opts), nil
 }
 
-func (s *staffService) GetStaffByID(staffID int) (models.Staff, error) {
-	staff, err := s.repo.GetStaffByID(staffID)
+func (s *staffService) GetStaffByID(ctx context.Context, staffID int) (models.Staff, error) {
+	staff, err := s.repo.GetStaffByID(ctx, staffID)
 	if err != nil {
 		log.Printf("GetStaffByID: Failed to retrieve staff with ID %d: %v", staffID, err)
 		return staff, fmt.Errorf("failed to retrieve staff by ID: %w", err)
@@ -41,36 +56,236 @@ func (s *staffService) GetStaffByID(staffID int) (models.Staff, error) {
 	return staff, nil
 }
 
-func (s *staffService) AddStaff(staff *models.Staff) error {
-	if err := s.repo.AddStaff(staff); err != nil {
+func (s *staffService) AddStaff(ctx context.Context, staff *models.Staff) error {
+	// yeni personel her zaman aktif başlar
+	staff.Active = true
+	if staff.StartingGradeID == 0 {
+		staff.StartingGradeID = staff.GradeID
+	}
+	if staff.StartDate.IsZero() {
+		staff.StartDate = time.Now()
+	}
+	staff.StartDate = staff.StartDate.UTC().Truncate(24 * time.Hour)
+
+	if err := s.requireGrade(ctx, staff.StartingGradeID); err != nil {
+		log.Printf("AddStaff: Invalid starting grade %d: %v", staff.StartingGradeID, err)
+		return err
+	}
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.AddStaff(ctx, staff); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditStaff, staff.StaffID, models.AuditCreate, nil, staff)
+	})
+	if err != nil {
 		log.Printf("AddStaff: Error adding staff: %v", err)
 		return fmt.Errorf("adding staff failed: %w", err)
 	}
 	return nil
 }
 
-func (s *staffService) RemoveStaff(staffID int) error {
+func (s *staffService) RemoveStaff(ctx context.Context, staffID int) error {
 	if staffID <= 0 {
 		log.Printf("RemoveStaff: Invalid staff ID: %d", staffID)
-		return fmt.Errorf("invalid staff ID")
+		return apperrors.BadRequest("invalid staff ID")
 	}
 
-	if err := s.repo.RemoveStaff(staffID); err != nil {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetStaffByID(ctx, staffID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.RemoveStaff(ctx, staffID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditStaff, staffID, models.AuditDelete, before, nil)
+	})
+	if err != nil {
 		log.Printf("RemoveStaff: Error removing staff with ID %d: %v", staffID, err)
 		return fmt.Errorf("failed to remove staff with ID %d: %w", staffID, err)
 	}
 	return nil
 }
 
-func (s *staffService) UpdateStaff(staffID int, updatedDetails *models.Staff) error {
+// UpdateStaff replaces the staff member's name and role with those of
+// updatedDetails. Grades may be left out but not changed.
+func (s *staffService) UpdateStaff(ctx context.Context, staffID int, updatedDetails *models.Staff) error {
+	updated, err := s.update(ctx, staffID, func(before models.Staff) (models.Staff, error) {
+		after := before
+		after.Name, after.Role = updatedDetails.Name, updatedDetails.Role
+		if updatedDetails.GradeID != 0 {
+			after.GradeID = updatedDetails.GradeID
+		}
+		if updatedDetails.StartingGradeID != 0 {
+			after.StartingGradeID = updatedDetails.StartingGradeID
+		}
+		return after, nil
+	})
+	if err != nil {
+		log.Printf("UpdateStaff: Error updating staff with ID %d: %v", staffID, err)
+		return fmt.Errorf("failed to update staff with ID %d: %w", staffID, err)
+	}
+	*updatedDetails = updated
+	return nil
+}
+
+// PatchStaff applies a merge patch to the staff member and returns the
+// updated record.
+func (s *staffService) PatchStaff(ctx context.Context, staffID int, patch models.MergePatch) (models.Staff, error) {
+	staff, err := s.update(ctx, staffID, func(before models.Staff) (models.Staff, error) {
+		after := before
+		err := patch.Apply(&after, "staff_id", "start_date", "active")
+		return after, err
+	})
+	if err != nil {
+		log.Printf("PatchStaff: Error patching staff with ID %d: %v", staffID, err)
+		return models.Staff{}, fmt.Errorf("failed to patch staff with ID %d: %w", staffID, err)
+	}
+	return staff, nil
+}
+
+// update runs change against the staff member as they are now, validates the
+// result and writes the fields that changed. Changing nothing is not
+// recorded.
+func (s *staffService) update(ctx context.Context, staffID int, change func(before models.Staff) (models.Staff, error)) (models.Staff, error) {
 	if staffID <= 0 {
-		log.Printf("UpdateStaff: Invalid staff ID: %d", staffID)
-		return fmt.Errorf("invalid staff ID")
+		return models.Staff{}, apperrors.BadRequest("invalid staff ID")
 	}
 
-	if err := s.repo.UpdateStaff(staffID, updatedDetails); err != nil {
-		log.Printf("UpdateStaff: Error updating staff with ID %d: %v", staffID, err)
-		return fmt.Errorf("failed to update staff with ID %d: %w", staffID, err)
+	var after models.Staff
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetStaffByID(ctx, staffID)
+		if err != nil {
+			return err
+		}
+		if after, err = change(before); err != nil {
+			return err
+		}
+		after.StaffID = staffID
+		if after.GradeID != before.GradeID || after.StartingGradeID != before.StartingGradeID {
+			return apperrors.Validation("grade changes must be recorded as promotions")
+		}
+
+		if err := s.repo.UpdateStaff(ctx, before, after); err != nil {
+			return err
+		}
+		if after.Name == before.Name && after.Role == before.Role {
+			return nil
+		}
+		return s.audit.Record(ctx, models.AuditStaff, staffID, models.AuditUpdate, before, after)
+	})
+	return after, err
+}
+
+func (s *staffService) SetStaffActive(ctx context.Context, staffID int, active bool) error {
+	if staffID <= 0 {
+		log.Printf("SetStaffActive: Invalid staff ID: %d", staffID)
+		return apperrors.BadRequest("invalid staff ID")
+	}
+
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetStaffByID(ctx, staffID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.SetStaffActive(ctx, staffID, active); err != nil {
+			return err
+		}
+		after, err := s.repo.GetStaffByID(ctx, staffID)
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditStaff, staffID, models.AuditUpdate, before, after)
+	})
+	if err != nil {
+		log.Printf("SetStaffActive: Error updating status of staff with ID %d: %v", staffID, err)
+		return fmt.Errorf("failed to update status of staff with ID %d: %w", staffID, err)
+	}
+	return nil
+}
+
+// Promote moves a staff member to a new grade from effectiveDate, which
+// defaults to today and may be in the future. It must fall after their start
+// date, and they must not already hold the grade on that day.
+func (s *staffService) Promote(ctx context.Context, staffID, gradeID int, effectiveDate time.Time) (models.StaffGradeChange, error) {
+	if staffID <= 0 {
+		log.Printf("Promote: Invalid staff ID: %d", staffID)
+		return models.StaffGradeChange{}, apperrors.BadRequest("invalid staff ID")
+	}
+	if effectiveDate.IsZero() {
+		effectiveDate = time.Now()
+	}
+	effectiveDate = effectiveDate.UTC().Truncate(24 * time.Hour)
+
+	staff, err := s.repo.GetStaffByID(ctx, staffID)
+	if err != nil {
+		log.Printf("Promote: Failed to retrieve staff with ID %d: %v", staffID, err)
+		return models.StaffGradeChange{}, fmt.Errorf("promotion failed: %w", err)
+	}
+	if err := s.requireGrade(ctx, gradeID); err != nil {
+		log.Printf("Promote: Invalid grade %d: %v", gradeID, err)
+		return models.StaffGradeChange{}, err
+	}
+	if !effectiveDate.After(staff.StartDate) {
+		log.Printf("Promote: Effective date %s is not after start date of staff with ID %d", effectiveDate.Format(time.DateOnly), staffID)
+		return models.StaffGradeChange{}, apperrors.Validation("effective date must be after the start date %s", staff.StartDate.Format(time.DateOnly))
+	}
+
+	held, err := s.repo.GetGradeOn(ctx, staffID, effectiveDate)
+	if err != nil {
+		log.Printf("Promote: Failed to resolve grade of staff with ID %d: %v", staffID, err)
+		return models.StaffGradeChange{}, fmt.Errorf("promotion failed: %w", err)
+	}
+	if held == gradeID {
+		return models.StaffGradeChange{}, apperrors.Validation("staff already holds grade %d on %s", gradeID, effectiveDate.Format(time.DateOnly))
+	}
+
+	change := models.StaffGradeChange{
+		StaffID:       staffID,
+		GradeID:       gradeID,
+		EffectiveDate: effectiveDate,
+		Reason:        models.GradeReasonPromotion,
+	}
+	// terfi, personelin o tarihteki derecesinin değişimi olarak kaydedilir
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.AddGradeChange(ctx, &change); err != nil {
+			return err
+		}
+		before := map[string]any{"grade_id": held}
+		after := map[string]any{"grade_id": gradeID, "effective_date": change.EffectiveDate}
+		return s.audit.Record(ctx, models.AuditStaff, staffID, models.AuditUpdate, before, after)
+	})
+	if err != nil {
+		log.Printf("Promote: Error promoting staff with ID %d: %v", staffID, err)
+		return models.StaffGradeChange{}, fmt.Errorf("promotion failed: %w", err)
+	}
+	return change, nil
+}
+
+func (s *staffService) GetGradeHistory(ctx context.Context, staffID int) ([]models.StaffGradeChange, error) {
+	if _, err := s.repo.GetStaffByID(ctx, staffID); err != nil {
+		log.Printf("GetGradeHistory: Failed to retrieve staff with ID %d: %v", staffID, err)
+		return nil, fmt.Errorf("fetching grade history failed: %w", err)
+	}
+	history, err := s.repo.GetGradeHistory(ctx, staffID)
+	if err != nil {
+		log.Printf("GetGradeHistory: Error fetching grade history for staff with ID %d: %v", staffID, err)
+		return nil, fmt.Errorf("fetching grade history failed: %w", err)
+	}
+	return history, nil
+}
+
+// requireGrade reports a missing grade as a validation error, since it comes
+// from the request body rather than the path.
+func (s *staffService) requireGrade(ctx context.Context, gradeID int) error {
+	if gradeID <= 0 {
+		return apperrors.Validation("grade_id is required")
+	}
+	if _, err := s.gradeRepo.GetStaffGradeById(ctx, gradeID); err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return apperrors.Validation("grade %d does not exist", gradeID)
+		}
+		return err
 	}
 	return nil
 }
@@ -1,43 +1,69 @@
 package services
 
 import (
+	"agate-project/apperrors"
 	"agate-project/models"
 	"agate-project/repositories"
+	"context"
 	"fmt"
 	"log"
 )
 
 type StaffGradeService interface {
-	FetchAllGrades() ([]models.StaffGrade, error)
-	AddGrade(staffGrade *models.StaffGrade) error
-	RemoveGrade(gradeID int) error
-	UpdateGrade(gradeID int, gradeName *string, payRate *int) error
+	FetchAllGrades(ctx context.Context, opts models.ListOptions) (models.Page[models.StaffGrade], error)
+	GetGrade(ctx context.Context, gradeID int) (models.StaffGrade, error)
+	AddGrade(ctx context.Context, staffGrade *models.StaffGrade) error
+	RemoveGrade(ctx context.Context, gradeID int) error
+	UpdateGrade(ctx context.Context, grade *models.StaffGrade) error
+	PatchGrade(ctx context.Context, gradeID, version int, patch models.MergePatch) (models.StaffGrade, error)
 }
 
 type staffGradeService struct {
-	repo repositories.StaffGradeRepository
+	repo  repositories.StaffGradeRepository
+	audit AuditService
+	uow   repositories.UnitOfWork
 }
 
-func NewStaffGradeService(repo repositories.StaffGradeRepository) StaffGradeService {
-	return &staffGradeService{repo: repo}
+func NewStaffGradeService(repo repositories.StaffGradeRepository, audit AuditService, uow repositories.UnitOfWork) StaffGradeService {
+	return &staffGradeService{repo: repo, audit: audit, uow: uow}
 }
 
-func (s *staffGradeService) FetchAllGrades() ([]models.StaffGrade, error) {
-	grades, err := s.repo.GetAllStaffGrades()
+func (s *staffGradeService) FetchAllGrades(ctx context.Context, opts models.ListOptions) (models.Page[models.StaffGrade], error) {
+	grades, total, err := s.repo.GetAllStaffGrades(ctx, opts)
 	if err != nil {
 		log.Printf("FetchAllGrades: Error fetching grades: %v", err)
-		return nil, fmt.Errorf("fetching grades failed: %w", err)
+		return models.Page[models.StaffGrade]{}, fmt.Errorf("fetching grades failed: %w", err)
 	}
-	return grades, nil
+	return models.NewPage(grades, total, opts), nil
 }
 
-func (s *staffGradeService) AddGrade(staffGrade *models.StaffGrade) error {
-	if staffGrade.GradeName == "" || staffGrade.PayRate <= 0 {
-		log.Println("AddGrade: Invalid grade data: name or pay rate is missing.")
-		return fmt.Errorf("invalid grade data: name or pay rate is missing")
+func (s *staffGradeService) GetGrade(ctx context.Context, gradeID int) (models.StaffGrade, error) {
+	if gradeID <= 0 {
+		log.Printf("GetGrade: Invalid grade ID: %d", gradeID)
+		return models.StaffGrade{}, apperrors.BadRequest("invalid grade ID")
+	}
+
+	grade, err := s.repo.GetStaffGradeById(ctx, gradeID)
+	if err != nil {
+		log.Printf("GetGrade: Error fetching grade with ID %d: %v", gradeID, err)
+		return models.StaffGrade{}, fmt.Errorf("failed to fetch grade with ID %d: %w", gradeID, err)
+	}
+	return grade, nil
+}
+
+func (s *staffGradeService) AddGrade(ctx context.Context, staffGrade *models.StaffGrade) error {
+	if err := validateGrade(staffGrade); err != nil {
+		log.Printf("AddGrade: Invalid grade data: %v", err)
+		return err
 	}
 
-	if err := s.repo.AddStaffGrade(staffGrade); err != nil {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.repo.AddStaffGrade(ctx, staffGrade); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditGrade, staffGrade.GradeID, models.AuditCreate, nil, staffGrade)
+	})
+	if err != nil {
 		log.Printf("AddGrade: Error adding grade: %v", err)
 		return fmt.Errorf("adding grade failed: %w", err)
 	}
@@ -45,13 +71,23 @@ func (s *staffGradeService) AddGrade(staffGrade *models.StaffGrade) error {
 	return nil
 }
 
-func (s *staffGradeService) RemoveGrade(gradeID int) error {
+func (s *staffGradeService) RemoveGrade(ctx context.Context, gradeID int) error {
 	if gradeID <= 0 {
 		log.Printf("RemoveGrade: Invalid grade ID: %d", gradeID)
-		return fmt.Errorf("invalid grade ID")
+		return apperrors.BadRequest("invalid grade ID")
 	}
 
-	if err := s.repo.DeleteStaffGrade(gradeID); err != nil {
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetStaffGradeById(ctx, gradeID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.DeleteStaffGrade(ctx, gradeID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, models.AuditGrade, gradeID, models.AuditDelete, before, nil)
+	})
+	if err != nil {
 		log.Printf("RemoveGrade: Error removing grade with ID %d: %v", gradeID, err)
 		return fmt.Errorf("failed to remove grade with ID %d: %w", gradeID, err)
 	}
@@ -59,17 +95,75 @@ func (s *staffGradeService) RemoveGrade(gradeID int) error {
 	return nil
 }
 
-// *int çünkü güncellemek istemezsek nil olarak gönderilir
-func (s *staffGradeService) UpdateGrade(gradeID int, gradeName *string, payRate *int) error {
-	if gradeID <= 0 {
-		log.Printf("UpdateGrade: Invalid grade ID: %d", gradeID)
-		return fmt.Errorf("invalid grade ID")
+// UpdateGrade replaces the grade's name and pay rate with those of grade if
+// it is still at grade.Version. On success grade holds the updated record.
+func (s *staffGradeService) UpdateGrade(ctx context.Context, grade *models.StaffGrade) error {
+	updated, err := s.update(ctx, grade.GradeID, grade.Version, func(before models.StaffGrade) (models.StaffGrade, error) {
+		return *grade, nil
+	})
+	if err != nil {
+		log.Printf("UpdateGrade: Error updating grade with ID %d: %v", grade.GradeID, err)
+		return fmt.Errorf("failed to update grade with ID %d: %w", grade.GradeID, err)
 	}
+	*grade = updated
+	return nil
+}
 
-	if err := s.repo.UpdateStaffGrade(gradeID, gradeName, payRate); err != nil {
-		log.Printf("UpdateGrade: Error updating grade with ID %d: %v", gradeID, err)
-		return fmt.Errorf("failed to update grade with ID %d: %w", gradeID, err)
+// PatchGrade applies a merge patch to the grade if it is still at version and
+// returns the updated record.
+func (s *staffGradeService) PatchGrade(ctx context.Context, gradeID, version int, patch models.MergePatch) (models.StaffGrade, error) {
+	grade, err := s.update(ctx, gradeID, version, func(before models.StaffGrade) (models.StaffGrade, error) {
+		after := before
+		err := patch.Apply(&after, "grade_id", "version")
+		return after, err
+	})
+	if err != nil {
+		log.Printf("PatchGrade: Error patching grade with ID %d: %v", gradeID, err)
+		return models.StaffGrade{}, fmt.Errorf("failed to patch grade with ID %d: %w", gradeID, err)
 	}
+	return grade, nil
+}
 
-	return nil
+// update runs change against the grade as it is now, validates the result
+// and writes the fields that changed. A pay rate without a currency keeps
+// the grade's. Changing nothing is not recorded.
+func (s *staffGradeService) update(ctx context.Context, gradeID, version int, change func(before models.StaffGrade) (models.StaffGrade, error)) (models.StaffGrade, error) {
+	if gradeID <= 0 {
+		return models.StaffGrade{}, apperrors.BadRequest("invalid grade ID")
+	}
+
+	var after models.StaffGrade
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetStaffGradeById(ctx, gradeID)
+		if err != nil {
+			return err
+		}
+		if version, err = repositories.RequireVersion("staff grade", gradeID, before.Version, version); err != nil {
+			return err
+		}
+		if after, err = change(before); err != nil {
+			return err
+		}
+		after.GradeID, after.Version, after.Currency = gradeID, version, before.Currency
+		if err := validateGrade(&after); err != nil {
+			return err
+		}
+
+		if after.Version, err = s.repo.UpdateStaffGrade(ctx, version, before, after); err != nil {
+			return err
+		}
+		if after.Version == version {
+			return nil
+		}
+		return s.audit.Record(ctx, models.AuditGrade, gradeID, models.AuditUpdate, before, after)
+	})
+	return after, err
+}
+
+// validateGrade settles the currency of the grade's pay rate and checks it.
+func validateGrade(grade *models.StaffGrade) error {
+	if err := grade.ResolveCurrency(); err != nil {
+		return err
+	}
+	return validateAmounts(grade.PayRate)
 }
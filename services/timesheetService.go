@@ -0,0 +1,182 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"agate-project/repositories"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrInvalidTimesheet   = apperrors.Validation("invalid timesheet")
+	ErrDuplicateTimesheet = apperrors.Conflict("timesheet already logged for this staff member, campaign and day")
+)
+
+const maxHoursPerDay = 24
+
+type TimesheetService interface {
+	LogTimesheet(ctx context.Context, timesheet *models.Timesheet) error
+	GetTimesheetsByStaff(ctx context.Context, staffID int) ([]models.Timesheet, error)
+	GetLabourCost(ctx context.Context, campaignID int) (models.LabourCost, error)
+}
+
+type timesheetService struct {
+	repo         repositories.TimesheetRepository
+	staffRepo    repositories.StaffRepository
+	gradeRepo    repositories.StaffGradeRepository
+	campaignRepo repositories.CampaignRepository
+	uow          repositories.UnitOfWork
+}
+
+func NewTimesheetService(repo repositories.TimesheetRepository, staffRepo repositories.StaffRepository, gradeRepo repositories.StaffGradeRepository, campaignRepo repositories.CampaignRepository, uow repositories.UnitOfWork) TimesheetService {
+	return &timesheetService{
+		repo:         repo,
+		staffRepo:    staffRepo,
+		gradeRepo:    gradeRepo,
+		campaignRepo: campaignRepo,
+		uow:          uow,
+	}
+}
+
+// LogTimesheet records hours worked on a campaign and costs them at the
+// pay rate of the grade the staff member held on the day worked. The staff
+// member is locked from counting the day's hours until the entry is written,
+// so concurrent entries cannot together pass the daily limit.
+func (s *timesheetService) LogTimesheet(ctx context.Context, timesheet *models.Timesheet) error {
+	log.Printf("LogTimesheet: Logging %.2f hours for staff ID %d on campaign ID %d.", timesheet.Hours, timesheet.StaffID, timesheet.CampaignID)
+	timesheet.WorkDate = timesheet.WorkDate.UTC().Truncate(24 * time.Hour)
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		return s.logTimesheet(ctx, timesheet)
+	})
+}
+
+func (s *timesheetService) logTimesheet(ctx context.Context, timesheet *models.Timesheet) error {
+	if _, err := s.staffRepo.GetStaffForUpdate(ctx, timesheet.StaffID); err != nil {
+		log.Printf("LogTimesheet: Error fetching staff with ID %d: %v", timesheet.StaffID, err)
+		return fmt.Errorf("failed to fetch staff: %w", err)
+	}
+
+	campaign, err := s.campaignRepo.CheckBudget(ctx, timesheet.CampaignID)
+	if err != nil {
+		log.Printf("LogTimesheet: Error fetching campaign with ID %d: %v", timesheet.CampaignID, err)
+		return fmt.Errorf("failed to fetch campaign: %w", err)
+	}
+
+	exists, err := s.repo.TimesheetExists(ctx, timesheet.StaffID, timesheet.CampaignID, timesheet.WorkDate)
+	if err != nil {
+		return fmt.Errorf("failed to check existing timesheets: %w", err)
+	}
+	if exists {
+		log.Printf("LogTimesheet: Staff ID %d already logged time on campaign ID %d for %s.", timesheet.StaffID, timesheet.CampaignID, timesheet.WorkDate.Format(time.DateOnly))
+		return ErrDuplicateTimesheet
+	}
+
+	logged, err := s.repo.GetHoursForStaffOnDate(ctx, timesheet.StaffID, timesheet.WorkDate)
+	if err != nil {
+		return fmt.Errorf("failed to check logged hours: %w", err)
+	}
+	if logged+timesheet.Hours > maxHoursPerDay {
+		log.Printf("LogTimesheet: Staff ID %d would log %.2f hours on %s.", timesheet.StaffID, logged+timesheet.Hours, timesheet.WorkDate.Format(time.DateOnly))
+		return fmt.Errorf("%w: staff already logged %.2f hours that day", ErrInvalidTimesheet, logged)
+	}
+
+	grade, err := s.gradeOn(ctx, timesheet.StaffID, timesheet.WorkDate)
+	if err != nil {
+		log.Printf("LogTimesheet: Error resolving grade for staff ID %d: %v", timesheet.StaffID, err)
+		return err
+	}
+	if grade.Currency != campaign.Currency {
+		log.Printf("LogTimesheet: Grade ID %d is paid in %s but campaign ID %d is in %s.", grade.GradeID, grade.Currency, timesheet.CampaignID, campaign.Currency)
+		return fmt.Errorf("%w: %s and %s", models.ErrCurrencyMismatch, grade.Currency, campaign.Currency)
+	}
+
+	timesheet.GradeID = grade.GradeID
+	timesheet.HourlyRate = grade.PayRate
+	timesheet.Cost, err = grade.PayRate.Multiply(timesheet.Hours)
+	if err != nil {
+		return err
+	}
+	timesheet.Currency = grade.Currency
+
+	if err := s.repo.AddTimesheet(ctx, timesheet); err != nil {
+		log.Printf("LogTimesheet: Error adding timesheet: %v", err)
+		return fmt.Errorf("logging timesheet failed: %w", err)
+	}
+	return nil
+}
+
+// gradeOn returns the grade a staff member held on the given day, so time
+// logged for a past day is costed at the rate in force then.
+func (s *timesheetService) gradeOn(ctx context.Context, staffID int, day time.Time) (models.StaffGrade, error) {
+	gradeID, err := s.staffRepo.GetGradeOn(ctx, staffID, day)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return models.StaffGrade{}, fmt.Errorf("%w: staff %d had not started on %s", ErrInvalidTimesheet, staffID, day.Format(time.DateOnly))
+		}
+		return models.StaffGrade{}, fmt.Errorf("failed to fetch staff grade: %w", err)
+	}
+
+	grade, err := s.gradeRepo.GetStaffGradeById(ctx, gradeID)
+	if err != nil {
+		return models.StaffGrade{}, fmt.Errorf("failed to fetch staff grade: %w", err)
+	}
+	return grade, nil
+}
+
+func (s *timesheetService) GetTimesheetsByStaff(ctx context.Context, staffID int) ([]models.Timesheet, error) {
+	timesheets, err := s.repo.GetTimesheetsByStaff(ctx, staffID)
+	if err != nil {
+		log.Printf("GetTimesheetsByStaff: Error fetching timesheets for staff ID %d: %v", staffID, err)
+		return nil, fmt.Errorf("fetching timesheets failed: %w", err)
+	}
+	return timesheets, nil
+}
+
+// GetLabourCost rolls a campaign's timesheets up by staff member and by grade
+func (s *timesheetService) GetLabourCost(ctx context.Context, campaignID int) (models.LabourCost, error) {
+	campaign, err := s.campaignRepo.CheckBudget(ctx, campaignID)
+	if err != nil {
+		log.Printf("GetLabourCost: Error fetching campaign with ID %d: %v", campaignID, err)
+		return models.LabourCost{}, fmt.Errorf("failed to fetch campaign: %w", err)
+	}
+
+	lines, err := s.repo.GetLabourCostByCampaign(ctx, campaignID)
+	if err != nil {
+		log.Printf("GetLabourCost: Error fetching labour cost for campaign ID %d: %v", campaignID, err)
+		return models.LabourCost{}, fmt.Errorf("fetching labour cost failed: %w", err)
+	}
+
+	labour := models.LabourCost{
+		CampaignID: campaignID,
+		TotalCost:  models.NewMoney(0, campaign.Currency),
+		ByStaff:    lines,
+		ByGrade:    []models.GradeLabourCost{},
+	}
+	byGrade := map[int]int{}
+	for _, line := range lines {
+		total, err := labour.TotalCost.Add(line.Cost)
+		if err != nil {
+			return models.LabourCost{}, err
+		}
+		labour.TotalCost = total
+		labour.TotalHours += line.Hours
+
+		i, ok := byGrade[line.GradeID]
+		if !ok {
+			i = len(labour.ByGrade)
+			byGrade[line.GradeID] = i
+			labour.ByGrade = append(labour.ByGrade, models.GradeLabourCost{
+				GradeID:   line.GradeID,
+				GradeName: line.GradeName,
+				Cost:      models.NewMoney(0, line.Currency),
+			})
+		}
+		labour.ByGrade[i].Hours += line.Hours
+		labour.ByGrade[i].Cost.Amount += line.Cost.Amount
+	}
+	return labour, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"agate-project/apperrors"
+	"agate-project/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+
+	// Staff and client portal tokens are signed with the same key but carry
+	// different audiences, so one can never be used in place of the other.
+	audienceStaff  = "staff"
+	audiencePortal = "portal"
+)
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Type     string `json:"typ"`
+	Role     string `json:"role,omitempty"`
+	ClientID int    `json:"client_id,omitempty"`
+}
+
+// tokenIssuer signs and verifies the access/refresh token pairs of one
+// audience.
+type tokenIssuer struct {
+	config   AuthConfig
+	audience string
+}
+
+// issue signs an access and a refresh token for a session. claims supplies
+// the subject and any audience-specific fields.
+func (t tokenIssuer) issue(claims tokenClaims, sessionID string, sessionExpiresAt time.Time) (models.TokenPair, error) {
+	now := time.Now()
+	access, err := t.sign(claims, sessionID, tokenTypeAccess, now, now.Add(t.config.AccessTTL))
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	refresh, err := t.sign(claims, sessionID, tokenTypeRefresh, now, sessionExpiresAt)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	return models.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(t.config.AccessTTL.Seconds()),
+	}, nil
+}
+
+func (t tokenIssuer) sign(claims tokenClaims, sessionID, tokenType string, issuedAt, expiresAt time.Time) (string, error) {
+	claims.Issuer = t.config.Issuer
+	claims.Audience = jwt.ClaimStrings{t.audience}
+	claims.ID = sessionID
+	claims.IssuedAt = jwt.NewNumericDate(issuedAt)
+	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	claims.Type = tokenType
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.config.SigningKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// parse verifies a token's signature, issuer, audience, expiry and type.
+func (t tokenIssuer) parse(token, tokenType string) (*tokenClaims, error) {
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return t.config.SigningKey, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(t.config.Issuer),
+		jwt.WithAudience(t.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, apperrors.Unauthorized("token has expired")
+		}
+		return nil, apperrors.Unauthorized("invalid token")
+	}
+	if claims.Type != tokenType {
+		return nil, apperrors.Unauthorized("expected a %s token", tokenType)
+	}
+	return claims, nil
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
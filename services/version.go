@@ -0,0 +1,7 @@
+package services
+
+import "agate-project/repositories"
+
+// AnyVersion asks an update to apply to a record at whatever version it is,
+// as If-Match: * does.
+const AnyVersion = repositories.AnyVersion